@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `usage:
+  cloud-app                                                     start the service
+  cloud-app apikey create [-admin] [-tenant <tenant>] <name>    create an API key and print it
+  cloud-app apikey list                                         list API keys
+  cloud-app apikey revoke <name>                                revoke an API key`
+
+// runCommand executes an administrative command given on the command line instead of starting the servers.
+func runCommand(keys *auth.APIKeyStore, args []string) error {
+	if len(args) < 2 || args[0] != "apikey" {
+		return errors.New(usage)
+	}
+
+	switch args[1] {
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		admin := fs.Bool("admin", false, "allow the key to use the admin API")
+		tenant := fs.String("tenant", "", "confine the key to the services of the tenant")
+		if err := fs.Parse(args[2:]); err != nil || fs.NArg() != 1 {
+			return errors.New(usage)
+		}
+		key, err := keys.Create(fs.Arg(0), *admin, *tenant)
+		if err != nil {
+			return err
+		}
+		fmt.Println(key.Key)
+
+	case "list":
+		list, err := keys.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tADMIN\tTENANT\tCREATED\tREVOKED")
+		for _, k := range list {
+			revoked := "-"
+			if k.RevokedAt != nil {
+				revoked = k.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			tenant := "-"
+			if len(k.Tenant) > 0 {
+				tenant = k.Tenant
+			}
+			fmt.Fprintf(w, "%s\t%v\t%s\t%s\t%s\n", k.Name, k.Admin, tenant, k.CreatedAt.Format("2006-01-02 15:04:05"), revoked)
+		}
+		w.Flush()
+
+	case "revoke":
+		if len(args) != 3 {
+			return errors.New(usage)
+		}
+		return keys.Revoke(args[2])
+
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	"sync"
+	"time"
+)
+
+// FlagEvaluator evaluates the flags of a service in an environment without
+// calling the server, from the ruleset ListFlags returned last. It gives the
+// values EvaluateFlags would give for that ruleset.
+type FlagEvaluator struct {
+	svc         ConfigService
+	service     string
+	environment string
+
+	mu      sync.RWMutex
+	flags   []flags.Flag
+	updated time.Time
+}
+
+// NewFlagEvaluator loads the ruleset of the service and refreshes it every
+// interval until ctx is done, not at all if interval is 0. When a refresh
+// fails the last ruleset stays in use.
+func NewFlagEvaluator(ctx context.Context, svc ConfigService, service, environment string, interval time.Duration) (*FlagEvaluator, error) {
+	e := &FlagEvaluator{svc: svc, service: service, environment: environment}
+	if err := e.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go func() {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					_ = e.Refresh(ctx)
+				}
+			}
+		}()
+	}
+	return e, nil
+}
+
+// Refresh replaces the cached ruleset by the current one.
+func (e *FlagEvaluator) Refresh(ctx context.Context) error {
+	list, err := e.svc.ListFlags(ctx, ConfigRequest{Service: e.service, Environment: e.environment})
+	if err != nil {
+		return err
+	}
+	ruleset := make([]flags.Flag, len(list))
+	for i, f := range list {
+		ruleset[i] = f.Flag
+	}
+	e.mu.Lock()
+	e.flags, e.updated = ruleset, time.Now()
+	e.mu.Unlock()
+	return nil
+}
+
+// Updated returns when the cached ruleset was loaded.
+func (e *FlagEvaluator) Updated() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.updated
+}
+
+// Evaluate returns the values of the named flags for the client, of all the
+// flags if no names are given.
+func (e *FlagEvaluator) Evaluate(c FlagContext, names ...string) []FlagResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return flags.EvaluateAll(e.flags, c, names)
+}
+
+// Bool returns the value of a boolean flag for the client, or def if the flag
+// does not exist or its value is not a boolean.
+func (e *FlagEvaluator) Bool(name string, c FlagContext, def bool) bool {
+	var v *bool
+	if err := json.Unmarshal(e.Evaluate(c, name)[0].Value, &v); err != nil || v == nil {
+		return def
+	}
+	return *v
+}
@@ -6,23 +6,67 @@ import (
 	"errors"
 	"fmt"
 	pb "github.com/tonx22/gocloudcamp/pb"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"os"
+	"time"
 )
 
 type ConfigService interface {
 	SetConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
 	GetConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
+	GetKey(ctx context.Context, r KeyRequest) (*KeyRequest, error)
 	UpdConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
 	DelConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
+	ListDeleted(ctx context.Context, r ConfigRequest) ([]DeletedVersion, error)
+	RestoreConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
+	PatchConfig(ctx context.Context, r PatchRequest) (*ConfigRequest, error)
+	ValidateConfig(ctx context.Context, r ConfigRequest) (*ValidationResult, error)
+	SearchConfigs(ctx context.Context, r SearchRequest) ([]SearchResult, error)
+	ListEnvironments(ctx context.Context, r ConfigRequest) ([]Environment, error)
+	CopyConfig(ctx context.Context, r CopyRequest) (*ConfigRequest, error)
+	PromoteConfig(ctx context.Context, r PromoteRequest) (*ConfigRequest, error)
+	ListPromotions(ctx context.Context, r ConfigRequest) ([]Promotion, error)
+	ListActivations(ctx context.Context, r ConfigRequest) ([]Activation, error)
+	CancelActivation(ctx context.Context, r Activation) (*Activation, error)
+	ListGuards(ctx context.Context, r ConfigRequest) ([]Guard, error)
+	ReportHealth(ctx context.Context, r HealthReport) (*Guard, error)
+
+	PinConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
+	SetRetention(ctx context.Context, r RetentionPolicy) (*RetentionPolicy, error)
+	GetRetention(ctx context.Context, r ConfigRequest) (*RetentionPolicy, error)
+	DelRetention(ctx context.Context, r ConfigRequest) (*RetentionPolicy, error)
+	RetentionReport(ctx context.Context, r ConfigRequest) ([]ExpiredVersion, error)
+
+	SetCanary(ctx context.Context, r Canary) (*Canary, error)
+	GetCanary(ctx context.Context, r ConfigRequest) (*Canary, error)
+	PromoteCanary(ctx context.Context, r ConfigRequest) (*ConfigRequest, error)
+	AbortCanary(ctx context.Context, r ConfigRequest) (*Canary, error)
+
+	SetFlag(ctx context.Context, r Flag) (*Flag, error)
+	ListFlags(ctx context.Context, r ConfigRequest) ([]Flag, error)
+	DelFlag(ctx context.Context, r Flag) (*Flag, error)
+	EvaluateFlags(ctx context.Context, r FlagsRequest) ([]FlagResult, error)
+
+	SetSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error)
+	GetSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error)
+	DelSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error)
+	ListSchemas(ctx context.Context, r SchemaRequest) ([]SchemaRequest, error)
+	SetCompatibility(ctx context.Context, r SchemaRequest) (*SchemaRequest, error)
 }
 
 type configService struct {
 	GRPCClient pb.ConfigSvcClient
 }
 
-func NewGRPCClient() (*configService, error) {
+func NewGRPCClient(opt ...Option) (*configService, error) {
+	var o clientOptions
+	for _, f := range opt {
+		f(&o)
+	}
+
 	defaultHost, ok := os.LookupEnv("GRPC_HOST")
 	if !ok {
 		defaultHost = "localhost"
@@ -32,8 +76,20 @@ func NewGRPCClient() (*configService, error) {
 		defaultPort = "50051"
 	}
 
+	creds, err := o.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+
 	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts = append(opts, grpc.WithTransportCredentials(creds))
+	if len(o.token) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: o.token, secure: o.tls}))
+	}
+	if len(o.clientID) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(clientIDCredentials(o.clientID)))
+	}
+	opts = append(opts, o.dialOptions...)
 
 	serverAddr := fmt.Sprintf("%s:%s", defaultHost, defaultPort)
 	conn, err := grpc.Dial(serverAddr, opts...)
@@ -68,6 +124,8 @@ func (svc configService) UpdConfig(ctx context.Context, r ConfigRequest) (*Confi
 	return res, err
 }
 
+// DelConfig moves the version to the trash, or deletes it permanently if
+// r.Purge is set.
 func (svc configService) DelConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error) {
 	res, err := svc.processGRPCRequest(ctx, r, "delConfig")
 	if err != nil {
@@ -76,6 +134,472 @@ func (svc configService) DelConfig(ctx context.Context, r ConfigRequest) (*Confi
 	return res, err
 }
 
+// ListDeleted returns the versions of the service in the trash of the
+// environment.
+func (svc configService) ListDeleted(ctx context.Context, r ConfigRequest) ([]DeletedVersion, error) {
+	resp, err := svc.GRPCClient.ListDeleted(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]DeletedVersion, 0, len(resp.Versions))
+	for _, v := range resp.Versions {
+		deletedAt, err := time.Parse(time.RFC3339, v.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		purgeAt, err := time.Parse(time.RFC3339, v.PurgeAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, DeletedVersion{Service: v.Service, Environment: v.Environment, Version: v.Version,
+			DeletedBy: v.DeletedBy, DeletedAt: deletedAt, PurgeAt: purgeAt})
+	}
+	return list, nil
+}
+
+// RestoreConfig takes version r.Version out of the trash, unused.
+func (svc configService) RestoreConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error) {
+	resp, err := svc.GRPCClient.RestoreConfig(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment, Version: r.Version})
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: resp.Version, Used: resp.Used}, nil
+}
+
+// GetKey returns the part of a config addressed by a dotted path like key4.A
+// or a JSONPath like key5[?(@.E>10)].
+func (svc configService) GetKey(ctx context.Context, r KeyRequest) (*KeyRequest, error) {
+	req := pb.KeyRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, Path: r.Path, Reveal: r.Reveal}
+	resp, err := svc.GRPCClient.GetKey(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyRequest{Service: resp.Service, Environment: resp.Environment, Version: resp.Version, Path: resp.Path, Value: resp.Value, Variant: resp.Variant}, nil
+}
+
+// SearchConfigs finds the used configs having the key r.Path, equal to r.Value if it is set.
+func (svc configService) SearchConfigs(ctx context.Context, r SearchRequest) ([]SearchResult, error) {
+	resp, err := svc.GRPCClient.SearchConfigs(ctx, &pb.SearchRequest{Path: r.Path, Value: r.Value, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(resp.Results))
+	for _, e := range resp.Results {
+		results = append(results, SearchResult{Service: e.Service, Environment: e.Environment, Version: e.Version, Value: e.Value})
+	}
+	return results, nil
+}
+
+// ListEnvironments returns the environments the service has configs in.
+func (svc configService) ListEnvironments(ctx context.Context, r ConfigRequest) ([]Environment, error) {
+	resp, err := svc.GRPCClient.ListEnvironments(ctx, &pb.ConfigRequest{Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Environment, 0, len(resp.Environments))
+	for _, e := range resp.Environments {
+		list = append(list, Environment{Service: e.Service, Environment: e.Environment, Version: e.Version, Versions: e.Versions})
+	}
+	return list, nil
+}
+
+// CopyConfig stores a version of a config as the new used version of another
+// environment of the service.
+func (svc configService) CopyConfig(ctx context.Context, r CopyRequest) (*ConfigRequest, error) {
+	req := pb.CopyRequest{Service: r.Service, From: r.From, To: r.To, Version: r.Version}
+	resp, err := svc.GRPCClient.CopyConfig(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGRPCResponse(ctx, resp)
+}
+
+// PatchConfig applies a merge patch or JSON Patch operations to the used
+// version of the service and returns the new version.
+func (svc configService) PatchConfig(ctx context.Context, r PatchRequest) (*ConfigRequest, error) {
+	req := pb.PatchRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, Type: r.Type, Patch: r.Patch}
+	resp, err := svc.GRPCClient.PatchConfig(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGRPCResponse(ctx, resp)
+}
+
+// ValidateConfig checks r against the schema of the service without storing it.
+func (svc configService) ValidateConfig(ctx context.Context, r ConfigRequest) (*ValidationResult, error) {
+	req, err := encodeGRPCRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := svc.GRPCClient.ValidateConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res := ValidationResult{Valid: resp.Valid}
+	for _, f := range resp.Errors {
+		res.Errors = append(res.Errors, FieldError{Path: f.Path, Message: f.Message})
+	}
+	return &res, nil
+}
+
+// PromoteConfig copies a version of a config into another service as its new
+// version, the used one if r.Activate is set.
+func (svc configService) PromoteConfig(ctx context.Context, r PromoteRequest) (*ConfigRequest, error) {
+	req := pb.PromoteRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, Target: r.Target, TargetEnvironment: r.TargetEnvironment, Activate: r.Activate}
+	resp, err := svc.GRPCClient.PromoteConfig(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGRPCResponse(ctx, resp)
+}
+
+// ListPromotions returns the versions of the service that were promoted or
+// copied from another config, newest first.
+func (svc configService) ListPromotions(ctx context.Context, r ConfigRequest) ([]Promotion, error) {
+	resp, err := svc.GRPCClient.ListPromotions(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Promotion, 0, len(resp.Promotions))
+	for _, p := range resp.Promotions {
+		promotedAt, err := time.Parse(time.RFC3339, p.PromotedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Promotion{Service: p.Service, Environment: p.Environment, Version: p.Version, Used: p.Used,
+			SourceService: p.SourceService, SourceEnvironment: p.SourceEnvironment, SourceVersion: p.SourceVersion,
+			PromotedBy: p.PromotedBy, PromotedAt: promotedAt})
+	}
+	return list, nil
+}
+
+// ListActivations returns the activations scheduled for the service in the
+// environment, pending and past ones, the latest first.
+func (svc configService) ListActivations(ctx context.Context, r ConfigRequest) ([]Activation, error) {
+	resp, err := svc.GRPCClient.ListActivations(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Activation, 0, len(resp.Activations))
+	for _, a := range resp.Activations {
+		activation, err := decodeActivation(a)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *activation)
+	}
+	return list, nil
+}
+
+// CancelActivation cancels the pending activation with the ID of r of its service.
+func (svc configService) CancelActivation(ctx context.Context, r Activation) (*Activation, error) {
+	resp, err := svc.GRPCClient.CancelActivation(ctx, &pb.Activation{Id: r.ID, Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	return decodeActivation(resp)
+}
+
+func decodeActivation(a *pb.Activation) (*Activation, error) {
+	activation := Activation{ID: a.Id, Service: a.Service, Environment: a.Environment, Version: a.Version, Status: a.Status, Error: a.Error, CreatedBy: a.CreatedBy}
+	var err error
+	if activation.ActivateAt, err = time.Parse(time.RFC3339, a.ActivateAt); err != nil {
+		return nil, err
+	}
+	if activation.CreatedAt, err = time.Parse(time.RFC3339, a.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(a.DoneAt) > 0 {
+		doneAt, err := time.Parse(time.RFC3339, a.DoneAt)
+		if err != nil {
+			return nil, err
+		}
+		activation.DoneAt = &doneAt
+	}
+	return &activation, nil
+}
+
+// ListGuards returns the guarded activations of the service in the
+// environment, the latest first.
+func (svc configService) ListGuards(ctx context.Context, r ConfigRequest) ([]Guard, error) {
+	resp, err := svc.GRPCClient.ListGuards(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Guard, 0, len(resp.Guards))
+	for _, g := range resp.Guards {
+		guard, err := decodeGuard(g)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *guard)
+	}
+	return list, nil
+}
+
+// ReportHealth ends the guard watching the service in the environment: a
+// healthy report lets the activation pass, a failure rolls it back.
+func (svc configService) ReportHealth(ctx context.Context, r HealthReport) (*Guard, error) {
+	resp, err := svc.GRPCClient.ReportHealth(ctx, &pb.HealthReport{Service: r.Service, Environment: r.Environment, Healthy: r.Healthy, Reason: r.Reason})
+	if err != nil {
+		return nil, err
+	}
+	return decodeGuard(resp)
+}
+
+func decodeGuard(g *pb.Guard) (*Guard, error) {
+	guard := Guard{ID: g.Id, Service: g.Service, Environment: g.Environment, Version: g.Version, PreviousVersion: g.PreviousVersion,
+		HealthURL: g.HealthUrl, Status: g.Status, Reason: g.Reason, CreatedBy: g.CreatedBy}
+	var err error
+	if guard.WatchUntil, err = time.Parse(time.RFC3339, g.WatchUntil); err != nil {
+		return nil, err
+	}
+	if guard.CreatedAt, err = time.Parse(time.RFC3339, g.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(g.DoneAt) > 0 {
+		doneAt, err := time.Parse(time.RFC3339, g.DoneAt)
+		if err != nil {
+			return nil, err
+		}
+		guard.DoneAt = &doneAt
+	}
+	return &guard, nil
+}
+
+// PinConfig pins version r.Version of the config so that retention never
+// removes it, or unpins it if r.Pinned is false.
+func (svc configService) PinConfig(ctx context.Context, r ConfigRequest) (*ConfigRequest, error) {
+	resp, err := svc.GRPCClient.PinConfig(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, Pinned: r.Pinned})
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: resp.Version, Used: resp.Used, Pinned: resp.Pinned}, nil
+}
+
+// SetRetention sets the retention policy of the service.
+func (svc configService) SetRetention(ctx context.Context, r RetentionPolicy) (*RetentionPolicy, error) {
+	resp, err := svc.GRPCClient.SetRetention(ctx, &pb.RetentionPolicy{Service: r.Service, KeepLast: r.KeepLast, KeepDays: r.KeepDays})
+	if err != nil {
+		return nil, err
+	}
+	return decodeRetention(resp)
+}
+
+// GetRetention returns the retention policy applying to the service.
+func (svc configService) GetRetention(ctx context.Context, r ConfigRequest) (*RetentionPolicy, error) {
+	resp, err := svc.GRPCClient.GetRetention(ctx, &pb.ConfigRequest{Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	return decodeRetention(resp)
+}
+
+// DelRetention deletes the retention policy of the service and returns the
+// global one applying to it again.
+func (svc configService) DelRetention(ctx context.Context, r ConfigRequest) (*RetentionPolicy, error) {
+	resp, err := svc.GRPCClient.DelRetention(ctx, &pb.ConfigRequest{Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	return decodeRetention(resp)
+}
+
+// RetentionReport returns the versions of the service retention would remove
+// now, without removing them.
+func (svc configService) RetentionReport(ctx context.Context, r ConfigRequest) ([]ExpiredVersion, error) {
+	resp, err := svc.GRPCClient.RetentionReport(ctx, &pb.ConfigRequest{Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ExpiredVersion, 0, len(resp.Versions))
+	for _, v := range resp.Versions {
+		createdAt, err := time.Parse(time.RFC3339, v.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ExpiredVersion{Service: v.Service, Environment: v.Environment, Version: v.Version, CreatedAt: createdAt})
+	}
+	return list, nil
+}
+
+func decodeRetention(p *pb.RetentionPolicy) (*RetentionPolicy, error) {
+	policy := RetentionPolicy{Service: p.Service, KeepLast: p.KeepLast, KeepDays: p.KeepDays, Global: p.Global, UpdatedBy: p.UpdatedBy}
+	if len(p.UpdatedAt) > 0 {
+		updatedAt, err := time.Parse(time.RFC3339, p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		policy.UpdatedAt = &updatedAt
+	}
+	return &policy, nil
+}
+
+// SetCanary serves version r.Version instead of the used one to r.Percentage
+// percent of the clients reading the used version of the service.
+func (svc configService) SetCanary(ctx context.Context, r Canary) (*Canary, error) {
+	resp, err := svc.GRPCClient.SetCanary(ctx, &pb.Canary{Service: r.Service, Environment: r.Environment, Version: r.Version, Percentage: r.Percentage})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCanary(resp)
+}
+
+// GetCanary returns the canary of the service in the environment.
+func (svc configService) GetCanary(ctx context.Context, r ConfigRequest) (*Canary, error) {
+	resp, err := svc.GRPCClient.GetCanary(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCanary(resp)
+}
+
+// PromoteCanary makes the canary version the used one for all clients.
+func (svc configService) PromoteCanary(ctx context.Context, r ConfigRequest) (*ConfigRequest, error) {
+	resp, err := svc.GRPCClient.PromoteCanary(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: resp.Version, Used: resp.Used}, nil
+}
+
+// AbortCanary stops serving the canary of the service in the environment.
+func (svc configService) AbortCanary(ctx context.Context, r ConfigRequest) (*Canary, error) {
+	resp, err := svc.GRPCClient.AbortCanary(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCanary(resp)
+}
+
+func decodeCanary(c *pb.Canary) (*Canary, error) {
+	createdAt, err := time.Parse(time.RFC3339, c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &Canary{Service: c.Service, Environment: c.Environment, Version: c.Version, Percentage: c.Percentage, CreatedBy: c.CreatedBy, CreatedAt: createdAt}, nil
+}
+
+// SetFlag creates the flag of the service in the environment or replaces its
+// value and rules.
+func (svc configService) SetFlag(ctx context.Context, r Flag) (*Flag, error) {
+	rules, err := json.Marshal(r.Rules)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := svc.GRPCClient.SetFlag(ctx, &pb.Flag{Service: r.Service, Environment: r.Environment, Name: r.Name, Enabled: r.Enabled, Default: r.Default, Rules: rules})
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlag(resp)
+}
+
+// ListFlags returns the flags of the service in the environment by name.
+func (svc configService) ListFlags(ctx context.Context, r ConfigRequest) ([]Flag, error) {
+	resp, err := svc.GRPCClient.ListFlags(ctx, &pb.ConfigRequest{Service: r.Service, Environment: r.Environment})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Flag, 0, len(resp.Flags))
+	for _, f := range resp.Flags {
+		flag, err := decodeFlag(f)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *flag)
+	}
+	return list, nil
+}
+
+// DelFlag deletes the flag of the service in the environment.
+func (svc configService) DelFlag(ctx context.Context, r Flag) (*Flag, error) {
+	resp, err := svc.GRPCClient.DelFlag(ctx, &pb.Flag{Service: r.Service, Environment: r.Environment, Name: r.Name})
+	if err != nil {
+		return nil, err
+	}
+	return &Flag{Service: resp.Service, Environment: resp.Environment, Flag: flags.Flag{Name: resp.Name}}, nil
+}
+
+// EvaluateFlags returns the values of the flags of the service for the client
+// described by r, see FlagEvaluator for evaluating them locally.
+func (svc configService) EvaluateFlags(ctx context.Context, r FlagsRequest) ([]FlagResult, error) {
+	resp, err := svc.GRPCClient.EvaluateFlags(ctx, &pb.FlagsRequest{Service: r.Service, Environment: r.Environment,
+		ClientId: r.ClientID, Attributes: r.Attributes, Flags: r.Flags})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]FlagResult, 0, len(resp.Values))
+	for _, v := range resp.Values {
+		results = append(results, FlagResult{Name: v.Name, Value: v.Value, Rule: int(v.Rule), Reason: v.Reason})
+	}
+	return results, nil
+}
+
+func decodeFlag(f *pb.Flag) (*Flag, error) {
+	flag := Flag{Service: f.Service, Environment: f.Environment, Flag: flags.Flag{Name: f.Name, Enabled: f.Enabled, Default: f.Default}, UpdatedBy: f.UpdatedBy}
+	if err := json.Unmarshal(f.Rules, &flag.Rules); err != nil {
+		return nil, err
+	}
+	var err error
+	if flag.UpdatedAt, err = time.Parse(time.RFC3339, f.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+func (svc configService) SetSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error) {
+	return svc.processSchemaRequest(ctx, r, "setSchema")
+}
+
+func (svc configService) GetSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error) {
+	return svc.processSchemaRequest(ctx, r, "getSchema")
+}
+
+func (svc configService) DelSchema(ctx context.Context, r SchemaRequest) (*SchemaRequest, error) {
+	return svc.processSchemaRequest(ctx, r, "delSchema")
+}
+
+// ListSchemas returns all schema versions of the service, oldest first.
+func (svc configService) ListSchemas(ctx context.Context, r SchemaRequest) ([]SchemaRequest, error) {
+	resp, err := svc.GRPCClient.ListSchemas(ctx, &pb.SchemaRequest{Service: r.Service})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]SchemaRequest, 0, len(resp.Schemas))
+	for _, e := range resp.Schemas {
+		list = append(list, SchemaRequest{Service: e.Service, Schema: e.Schema, Version: e.Version})
+	}
+	return list, nil
+}
+
+// SetCompatibility sets the compatibility mode checked when new schema versions are registered.
+func (svc configService) SetCompatibility(ctx context.Context, r SchemaRequest) (*SchemaRequest, error) {
+	return svc.processSchemaRequest(ctx, r, "setCompatibility")
+}
+
+func (svc configService) processSchemaRequest(ctx context.Context, r SchemaRequest, method string) (*SchemaRequest, error) {
+	req := pb.SchemaRequest{Service: r.Service, Schema: r.Schema, Version: r.Version, Compatibility: r.Compatibility}
+
+	var resp *pb.SchemaRequest
+	var err error
+	switch method {
+	case "setSchema":
+		resp, err = svc.GRPCClient.SetSchema(ctx, &req)
+	case "getSchema":
+		resp, err = svc.GRPCClient.GetSchema(ctx, &req)
+	case "delSchema":
+		resp, err = svc.GRPCClient.DelSchema(ctx, &req)
+	case "setCompatibility":
+		resp, err = svc.GRPCClient.SetCompatibility(ctx, &req)
+	default:
+		return nil, errors.New("unknown method")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaRequest{Service: resp.Service, Schema: resp.Schema, Version: resp.Version, Compatibility: resp.Compatibility}, nil
+}
+
 func (svc configService) processGRPCRequest(ctx context.Context, r ConfigRequest, method string) (*ConfigRequest, error) {
 	req, err := encodeGRPCRequest(ctx, r)
 	if err != nil {
@@ -108,24 +632,347 @@ func (svc configService) processGRPCRequest(ctx context.Context, r ConfigRequest
 
 func encodeGRPCRequest(_ context.Context, request interface{}) (*pb.ConfigRequest, error) {
 	r := request.(ConfigRequest)
-	req := pb.ConfigRequest{Service: r.Service, Version: r.Version, Used: r.Used}
-	req.Data, _ = json.Marshal(r.Data)
+	req := pb.ConfigRequest{Service: r.Service, Version: r.Version, Used: r.Used, Reveal: r.Reveal, Format: r.Format, Parent: r.Parent, Raw: r.Raw, Environment: r.Environment, Unresolved: r.Unresolved,
+		Purge: r.Purge}
+	if r.ActivateAt != nil {
+		req.ActivateAt = r.ActivateAt.Format(time.RFC3339)
+	}
+	if r.Watch > 0 {
+		req.Watch, req.HealthUrl = r.Watch.String(), r.HealthURL
+	}
+	if r.RawData != nil {
+		req.Data = r.RawData
+	} else {
+		req.Data, _ = json.Marshal(r.Data)
+	}
 	return &req, nil
 }
 
 func decodeGRPCResponse(_ context.Context, grpcResp interface{}) (*ConfigRequest, error) {
 	r := grpcResp.(*pb.ConfigRequest)
-	resp := ConfigRequest{Service: r.Service, Version: r.Version, Used: r.Used}
-	err := json.Unmarshal(r.Data, &resp.Data)
+	resp := ConfigRequest{Service: r.Service, Version: r.Version, Used: r.Used, SchemaVersion: r.SchemaVersion, RawData: r.Data, Format: r.Format, Parent: r.Parent, Provenance: r.Provenance, Environment: r.Environment,
+		Variant: r.Variant}
+	if len(r.ActivateAt) > 0 {
+		at, err := time.Parse(time.RFC3339, r.ActivateAt)
+		if err != nil {
+			return nil, err
+		}
+		resp.ActivateAt = &at
+	}
+	if len(r.Format) > 0 && r.Format != FormatJSON {
+		// Exported data is only available in RawData.
+		return &resp, nil
+	}
+	if len(r.Data) == 0 || r.Data[0] != '[' {
+		err := json.Unmarshal(r.Data, &resp.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	// The array form [{"key1": ...}, {"key2": ...}] is merged into Data, its
+	// order is kept in RawData only.
+	var items []map[string]interface{}
+	err := json.Unmarshal(r.Data, &items)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data = make(map[string]interface{})
+	for _, item := range items {
+		for k, v := range item {
+			resp.Data[k] = v
+		}
+	}
 	return &resp, nil
 }
 
 type ConfigRequest struct {
 	Service string
-	Data    map[string]interface{}
+	// Environment is the namespace of the config, "default" if empty.
+	Environment string
+	Data        map[string]interface{}
+	// RawData is the data as JSON, either an object or an array of single-key
+	// objects. When set it is sent instead of Data, responses always carry it
+	// with the original key order.
+	RawData json.RawMessage
 	Version int32
 	Used    bool
+	// Reveal asks GetConfig to return secret values in clear text.
+	Reveal bool
+	// SchemaVersion is the version of the service schema the config was validated against.
+	SchemaVersion int32
+	// Format asks GetConfig to return RawData in one of the Format constants
+	// instead of JSON, Data is left empty then.
+	Format string
+	// Parent is the service the config inherits from. GetConfig returns the
+	// parent data merged with the own layer, or the own layer only if Raw is set.
+	Parent string
+	Raw    bool
+	// Unresolved asks GetConfig to return placeholders like ${ref:service/key}
+	// as stored instead of resolving them.
+	Unresolved bool
+	// ActivateAt schedules SetConfig and UpdConfig to make the version the
+	// used one at that time instead of immediately.
+	ActivateAt *time.Time
+	// Watch makes UpdConfig guard the activation: for that long a failure
+	// reported by ReportHealth or by the health check at HealthURL makes the
+	// previous version the used one again.
+	Watch     time.Duration
+	HealthURL string
+	// Provenance maps the paths of the values of a config with a parent to the
+	// service each of them came from.
+	Provenance map[string]string
+	// Variant tells whether GetConfig returned the used version ("stable") or
+	// the canary one ("canary"), see WithClientID.
+	Variant string
+	// Pinned makes PinConfig pin the version, retention never removes pinned
+	// versions.
+	Pinned bool
+	// Purge makes DelConfig delete the version permanently instead of moving
+	// it to the trash.
+	Purge bool
+}
+
+// Formats GetConfig can return data in.
+const (
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatTOML       = "toml"
+	FormatProperties = "properties"
+	FormatDotenv     = "dotenv"
+	FormatConfigMap  = "configmap"
+	FormatSecret     = "secret"
+)
+
+type KeyRequest struct {
+	Service     string
+	Environment string
+	Version     int32
+	Path        string
+	Reveal      bool
+	// Value is the addressed JSON value, or the array of matches for paths with wildcards or filters.
+	Value json.RawMessage
+	// Variant is "stable" or "canary" when the used version was read, see WithClientID.
+	Variant string
+}
+
+type SearchRequest struct {
+	// Path is a dotted key like "db.host".
+	Path string
+	// Value, if set, is the JSON value the key must be equal to.
+	Value json.RawMessage
+	// Environment, if set, limits the search to one environment.
+	Environment string
+}
+
+type SearchResult struct {
+	Service     string
+	Environment string
+	Version     int32
+	Value       json.RawMessage
+}
+
+// Environment is an environment of a service with its used version, 0 if none
+// is used, and the number of its versions.
+type Environment struct {
+	Service     string
+	Environment string
+	Version     int32
+	Versions    int32
+}
+
+// PromoteRequest copies a version of a config, the used one if Version is 0,
+// into the Target service as its new version, the used one if Activate is set.
+type PromoteRequest struct {
+	Service           string
+	Environment       string
+	Version           int32
+	Target            string
+	TargetEnvironment string
+	Activate          bool
+}
+
+// Promotion records where a promoted or copied config version came from.
+type Promotion struct {
+	Service           string
+	Environment       string
+	Version           int32
+	Used              bool
+	SourceService     string
+	SourceEnvironment string
+	SourceVersion     int32
+	PromotedBy        string
+	PromotedAt        time.Time
+}
+
+// Activation is a version scheduled to become the used one at ActivateAt.
+// Status is "pending", "done", "cancelled" or "failed", Error tells why it failed.
+type Activation struct {
+	ID          int64
+	Service     string
+	Environment string
+	Version     int32
+	ActivateAt  time.Time
+	Status      string
+	Error       string
+	CreatedBy   string
+	CreatedAt   time.Time
+	DoneAt      *time.Time
+}
+
+// Guard watches a version activated by a guarded UpdConfig until WatchUntil.
+// Status is "watching", "passed", "rolled_back" or "superseded", Reason tells why.
+type Guard struct {
+	ID              int64
+	Service         string
+	Environment     string
+	Version         int32
+	PreviousVersion int32
+	HealthURL       string
+	WatchUntil      time.Time
+	Status          string
+	Reason          string
+	CreatedBy       string
+	CreatedAt       time.Time
+	DoneAt          *time.Time
+}
+
+// HealthReport tells whether the version watched by the guard of the service
+// in the environment works.
+type HealthReport struct {
+	Service     string
+	Environment string
+	Healthy     bool
+	Reason      string
+}
+
+// DeletedVersion is a version in the trash, RestoreConfig brings it back until
+// it is purged at PurgeAt.
+type DeletedVersion struct {
+	Service     string
+	Environment string
+	Version     int32
+	DeletedBy   string
+	DeletedAt   time.Time
+	PurgeAt     time.Time
+}
+
+// RetentionPolicy keeps the last KeepLast versions of every environment of
+// the service and those created in the last KeepDays days, 0 meaning the rule
+// doesn't apply. Used and pinned versions are always kept. Global tells the
+// service has no policy of its own and follows the server's.
+type RetentionPolicy struct {
+	Service   string
+	KeepLast  int32
+	KeepDays  int32
+	Global    bool
+	UpdatedBy string
+	UpdatedAt *time.Time
+}
+
+// ExpiredVersion is a version retention removes.
+type ExpiredVersion struct {
+	Service     string
+	Environment string
+	Version     int32
+	CreatedAt   time.Time
+}
+
+// Canary is a version served instead of the used one to Percentage percent of
+// the clients, chosen by a stable hash of the IDs they send with WithClientID.
+type Canary struct {
+	Service     string
+	Environment string
+	Version     int32
+	Percentage  float64
+	CreatedBy   string
+	CreatedAt   time.Time
+}
+
+// Flag is a feature flag of a service in an environment, see package flags
+// for how its rules are evaluated.
+type Flag struct {
+	Service     string
+	Environment string
+	flags.Flag
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+type (
+	FlagRule    = flags.Rule
+	FlagContext = flags.Context
+	FlagResult  = flags.Result
+)
+
+// FlagsRequest asks for the values of the flags of a service for a client,
+// of all of them if Flags is empty.
+type FlagsRequest struct {
+	Service     string
+	Environment string
+	FlagContext
+	Flags []string
+}
+
+// CopyRequest copies a version of a config, the used one if Version is 0, from
+// one environment of the service to another as its new used version.
+type CopyRequest struct {
+	Service string
+	From    string
+	To      string
+	Version int32
+}
+
+// Patch types of a PatchRequest.
+const (
+	MergePatch = "merge" // RFC 7396
+	JSONPatch  = "json"  // RFC 6902
+)
+
+type PatchRequest struct {
+	Service     string
+	Environment string
+	// Version, if set, is the version the patch is expected to apply to.
+	Version int32
+	Type    string
+	Patch   []byte
+}
+
+type SchemaRequest struct {
+	Service string
+	Schema  []byte
+	// Version selects a schema version, 0 means the latest one.
+	Version int32
+	// Compatibility is one of none, backward, backward_transitive, forward,
+	// forward_transitive, full and full_transitive.
+	Compatibility string
+}
+
+type ValidationResult struct {
+	Valid  bool
+	Errors []FieldError
+}
+
+// FieldError describes an invalid value of a config, Path is like "data.key5[1].E".
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+// FieldErrors returns the schema violations carried by an error returned from SetConfig.
+func FieldErrors(err error) []FieldError {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	var fields []FieldError
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, FieldError{Path: v.Field, Message: v.Description})
+			}
+		}
+	}
+	return fields
 }
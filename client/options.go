@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"os"
+)
+
+type clientOptions struct {
+	tls         bool
+	caFile      string
+	certFile    string
+	keyFile     string
+	serverName  string
+	token       string
+	clientID    string
+	dialOptions []grpc.DialOption
+}
+
+// Option configures the gRPC client.
+type Option func(*clientOptions)
+
+// WithCABundle enables TLS and verifies the server certificate against the CAs in the PEM file.
+func WithCABundle(caFile string) Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.caFile = caFile
+	}
+}
+
+// WithClientCertificate enables TLS and presents the given certificate for mutual TLS.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithServerName overrides the name used to verify the server certificate.
+func WithServerName(name string) Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.serverName = name
+	}
+}
+
+// WithDialOptions passes additional options to grpc.Dial.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *clientOptions) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithBearerToken sends token as "Authorization: Bearer <token>" with every call.
+// The token can be an API key or a JWT.
+func WithBearerToken(token string) Option {
+	return func(o *clientOptions) {
+		o.token = token
+	}
+}
+
+// WithPerRPCCredentials attaches custom per-call credentials, e.g. a refreshing token source.
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) Option {
+	return func(o *clientOptions) {
+		o.dialOptions = append(o.dialOptions, grpc.WithPerRPCCredentials(creds))
+	}
+}
+
+// WithClientID sends id as x-client-id with every call. The server serves the
+// canary version of a config to a stable share of client IDs.
+func WithClientID(id string) Option {
+	return func(o *clientOptions) {
+		o.clientID = id
+	}
+}
+
+type clientIDCredentials string
+
+func (c clientIDCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"x-client-id": string(c)}, nil
+}
+
+func (c clientIDCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+// RequireTransportSecurity refuses to send the token in plaintext once TLS is configured.
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+func (o *clientOptions) transportCredentials() (credentials.TransportCredentials, error) {
+	if !o.tls {
+		return insecure.NewCredentials(), nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: o.serverName}
+	if len(o.caFile) > 0 {
+		pem, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle: %s", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.caFile)
+		}
+	}
+	if len(o.certFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
@@ -3,13 +3,17 @@ module github.com/tonx22/gocloudcamp
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/Netflix/go-env v0.0.0-20220526054621-78278af1949d
 	github.com/golang-migrate/migrate/v4 v4.15.2
 	github.com/lib/pq v1.10.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.2.0
 	github.com/stretchr/testify v1.7.0
 	github.com/tidwall/gjson v1.14.3
+	google.golang.org/genproto v0.0.0-20220314164441-57ef72a4c106
 	google.golang.org/grpc v1.45.0
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -24,6 +28,4 @@ require (
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
 	golang.org/x/sys v0.0.0-20220317061510-51cd9980dadf // indirect
 	golang.org/x/text v0.3.7 // indirect
-	google.golang.org/genproto v0.0.0-20220314164441-57ef72a4c106 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
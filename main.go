@@ -6,18 +6,52 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/encryption"
+	"github.com/tonx22/gocloudcamp/pkg/schema"
 	"github.com/tonx22/gocloudcamp/pkg/service"
 	"github.com/tonx22/gocloudcamp/pkg/transport"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 type environment struct {
 	PgsqlURI string `env:"POSTGRES_URI"`
 	HTTPPort int    `env:"HTTP_PORT"`
 	GRPCPort int    `env:"GRPC_PORT"`
+
+	AuthEnabled     bool    `env:"AUTH_ENABLED"`
+	AuthTokens      string  `env:"AUTH_TOKENS"`
+	JWKSFile        string  `env:"JWT_JWKS_FILE"`
+	JWTIssuer       string  `env:"JWT_ISSUER"`
+	JWTAudience     string  `env:"JWT_AUDIENCE"`
+	RBACEnabled     bool    `env:"RBAC_ENABLED"`
+	TenancyEnabled  bool    `env:"TENANCY_ENABLED"`
+	TrustedIDHeader string  `env:"TRUSTED_IDENTITY_HEADER"`
+	RateLimit       float64 `env:"RATE_LIMIT"`
+	RateBurst       int     `env:"RATE_BURST,default=20"`
+
+	TLSCertFile     string `env:"TLS_CERT_FILE"`
+	TLSKeyFile      string `env:"TLS_KEY_FILE"`
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+
+	EncryptionKeyFile   string        `env:"ENCRYPTION_KEY_FILE"`
+	KeyRotationInterval time.Duration `env:"KEY_ROTATION_INTERVAL,default=1m"`
+	SchedulerInterval   time.Duration `env:"SCHEDULER_INTERVAL,default=10s"`
+
+	SchemaCompatibility string `env:"SCHEMA_COMPATIBILITY,default=backward"`
+	InterpolationEnv    string `env:"INTERPOLATION_ENV"`
+	HealthCheckHosts    string `env:"HEALTH_CHECK_HOSTS"`
+	HealthCheckFailures int    `env:"HEALTH_CHECK_FAILURES,default=3"`
+
+	RetentionKeepLast  int           `env:"RETENTION_KEEP_LAST"`
+	RetentionKeepDays  int           `env:"RETENTION_KEEP_DAYS"`
+	RetentionInterval  time.Duration `env:"RETENTION_INTERVAL,default=1h"`
+	DeletedGracePeriod time.Duration `env:"DELETED_GRACE_PERIOD,default=720h"`
 }
 
 func main() {
@@ -44,12 +78,94 @@ func main() {
 	}
 	m.Up()
 
-	err = transport.StartNewHTTPServer(svc, e.HTTPPort)
+	apiKeys := auth.NewAPIKeyStore(svc.DB)
+	if len(os.Args) > 1 {
+		err = runCommand(apiKeys, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if !schema.ValidMode(e.SchemaCompatibility) {
+		log.Fatalf("Unknown schema compatibility mode %q", e.SchemaCompatibility)
+	}
+	svc.SchemaCompatibility = e.SchemaCompatibility
+	svc.Tenancy = e.TenancyEnabled
+	svc.Environ = make(map[string]string)
+	for _, name := range strings.Split(e.InterpolationEnv, ",") {
+		name = strings.TrimSpace(name)
+		if v, ok := os.LookupEnv(name); ok && len(name) > 0 {
+			svc.Environ[name] = v
+		}
+	}
+	for _, host := range strings.Split(e.HealthCheckHosts, ",") {
+		if host = strings.TrimSpace(host); len(host) > 0 {
+			svc.HealthCheckHosts = append(svc.HealthCheckHosts, host)
+		}
+	}
+	svc.HealthCheckFailures = e.HealthCheckFailures
+	if e.RetentionKeepLast < 0 || e.RetentionKeepDays < 0 {
+		log.Fatalf("RETENTION_KEEP_LAST and RETENTION_KEEP_DAYS must not be negative")
+	}
+	svc.Retention.KeepLast, svc.Retention.KeepDays = e.RetentionKeepLast, e.RetentionKeepDays
+	svc.DeletedGracePeriod = e.DeletedGracePeriod
+
+	if len(e.EncryptionKeyFile) > 0 {
+		svc.Keys, err = encryption.LoadKeyring(e.EncryptionKeyFile)
+		if err != nil {
+			log.Fatalf("Can't load encryption keys: %v", err)
+		}
+		service.StartKeyRotation(svc, e.KeyRotationInterval)
+	}
+
+	service.StartScheduler(svc, e.SchedulerInterval)
+	service.StartRetention(svc, e.RetentionInterval)
+
+	var policies []transport.Policy
+	if e.RateLimit > 0 {
+		policies = append(policies, transport.RateLimit(e.RateLimit, e.RateBurst))
+	}
+	policies = append(policies, transport.PeerIdentity(e.TrustedIDHeader))
+	if e.AuthEnabled || len(e.AuthTokens) > 0 || len(e.JWKSFile) > 0 {
+		authenticator := auth.Authenticator{Keys: apiKeys}
+		if len(e.AuthTokens) > 0 {
+			authenticator.StaticKeys = strings.Split(e.AuthTokens, ",")
+		}
+		if len(e.JWKSFile) > 0 {
+			authenticator.JWT, err = auth.NewJWTVerifier(e.JWKSFile, e.JWTIssuer, e.JWTAudience)
+			if err != nil {
+				log.Fatalf("Can't load JWKS: %v", err)
+			}
+		}
+		policies = append(policies, transport.Authentication(&authenticator))
+	}
+	grants := auth.NewGrantStore(svc.DB)
+	tenants := auth.NewTenantStore(svc.DB)
+	opts := []transport.Option{transport.WithPolicies(policies...), transport.WithAPIKeys(apiKeys), transport.WithGrants(grants), transport.WithTenants(tenants)}
+
+	if len(e.TLSCertFile) > 0 {
+		tlsConfig, err := transport.NewTLSConfig(e.TLSCertFile, e.TLSKeyFile, e.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Can't load TLS configuration: %v", err)
+		}
+		opts = append(opts, transport.WithTLS(tlsConfig))
+	}
+
+	var configSvc service.ConfigService = svc
+	if e.TenancyEnabled {
+		configSvc = service.NewTenantService(configSvc, tenants)
+	}
+	if e.RBACEnabled {
+		configSvc = service.NewAuthorizingService(configSvc, grants)
+	}
+
+	err = transport.StartNewHTTPServer(configSvc, e.HTTPPort, opts...)
 	if err != nil {
 		log.Fatalf("Failed to start HTTP server: %v", err)
 	}
 
-	err = transport.StartNewGRPCServer(svc, e.GRPCPort)
+	err = transport.StartNewGRPCServer(configSvc, e.GRPCPort, opts...)
 	if err != nil {
 		log.Fatalf("Failed to start GRPC server: %v", err)
 	}
@@ -0,0 +1,9 @@
+create table if not exists api_keys
+(
+    id         bigserial primary key,
+    name       varchar(255) NOT NULL unique,
+    key_hash   char(64) NOT NULL unique,
+    admin      boolean default false,
+    created_at timestamptz NOT NULL default now(),
+    revoked_at timestamptz
+);
@@ -0,0 +1,9 @@
+create table if not exists access_grants
+(
+    id         bigserial primary key,
+    subject    varchar(255) NOT NULL,
+    pattern    varchar(255) NOT NULL,
+    role       varchar(16) NOT NULL check (role in ('reader', 'writer', 'admin')),
+    created_at timestamptz NOT NULL default now(),
+    unique (subject, pattern)
+);
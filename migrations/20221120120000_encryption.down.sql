@@ -0,0 +1,3 @@
+alter table configs drop column if exists key_id;
+alter table configs drop column if exists data_key;
+alter table configs drop column if exists encrypted_data;
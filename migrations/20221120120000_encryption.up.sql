@@ -0,0 +1,3 @@
+alter table configs add column if not exists encrypted_data bytea;
+alter table configs add column if not exists data_key bytea;
+alter table configs add column if not exists key_id varchar(64);
@@ -0,0 +1,4 @@
+delete from access_grants where role = 'reveal';
+alter table access_grants drop constraint if exists access_grants_role_check;
+alter table access_grants add constraint access_grants_role_check check (role in ('reader', 'writer', 'admin'));
+alter table configs drop column if exists secrets_key_id;
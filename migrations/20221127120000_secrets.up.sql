@@ -0,0 +1,3 @@
+alter table configs add column if not exists secrets_key_id varchar(64);
+alter table access_grants drop constraint if exists access_grants_role_check;
+alter table access_grants add constraint access_grants_role_check check (role in ('reader', 'writer', 'admin', 'reveal'));
@@ -0,0 +1,6 @@
+create table if not exists schemas
+(
+    service    varchar(255) primary key,
+    schema     json NOT NULL,
+    updated_at timestamptz NOT NULL default now()
+);
@@ -0,0 +1,15 @@
+alter table configs drop column schema_version;
+
+DROP TABLE schema_settings;
+
+create table if not exists schemas
+(
+    service    varchar(255) primary key,
+    schema     json NOT NULL,
+    updated_at timestamptz NOT NULL default now()
+);
+
+insert into schemas (service, schema, updated_at)
+select distinct on (service) service, schema, created_at from schema_versions order by service, version desc;
+
+DROP TABLE schema_versions;
@@ -0,0 +1,22 @@
+create table if not exists schema_versions
+(
+    id         serial primary key,
+    service    varchar(255) NOT NULL,
+    version    int NOT NULL,
+    schema     json NOT NULL,
+    created_at timestamptz NOT NULL default now(),
+    unique (service, version)
+);
+
+insert into schema_versions (service, version, schema, created_at)
+select service, 1, schema, updated_at from schemas;
+
+drop table schemas;
+
+create table if not exists schema_settings
+(
+    service       varchar(255) primary key,
+    compatibility varchar(32) NOT NULL
+);
+
+alter table configs add column schema_version int;
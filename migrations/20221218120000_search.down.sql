@@ -0,0 +1,3 @@
+DROP INDEX ix_configs_search;
+
+alter table configs drop column search_data;
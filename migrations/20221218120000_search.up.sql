@@ -0,0 +1,9 @@
+alter table configs add column if not exists search_data jsonb;
+
+update configs set search_data = data::jsonb where data is not null and json_typeof(data) = 'object';
+
+update configs set search_data = coalesce((select jsonb_object_agg(e.key, e.value)
+    from json_array_elements(configs.data) a, jsonb_each(a::jsonb) e), '{}'::jsonb)
+where data is not null and json_typeof(data) = 'array';
+
+create index if not exists ix_configs_search on configs using gin (search_data jsonb_path_ops) where used = true;
@@ -0,0 +1 @@
+alter table configs drop column parent;
@@ -0,0 +1 @@
+alter table configs add column if not exists parent varchar(255);
@@ -0,0 +1,5 @@
+DROP INDEX ix_configs_service_environment;
+
+delete from configs where environment <> 'default';
+
+alter table configs drop column environment;
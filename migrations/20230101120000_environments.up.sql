@@ -0,0 +1,3 @@
+alter table configs add column if not exists environment varchar(255) NOT NULL default 'default';
+
+create index if not exists ix_configs_service_environment on configs (service, environment, version);
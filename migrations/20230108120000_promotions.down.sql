@@ -0,0 +1,5 @@
+alter table configs drop column promoted_at;
+alter table configs drop column promoted_by;
+alter table configs drop column source_version;
+alter table configs drop column source_environment;
+alter table configs drop column source_service;
@@ -0,0 +1,5 @@
+alter table configs add column if not exists source_service varchar(255);
+alter table configs add column if not exists source_environment varchar(255);
+alter table configs add column if not exists source_version int;
+alter table configs add column if not exists promoted_by varchar(255);
+alter table configs add column if not exists promoted_at timestamptz;
@@ -0,0 +1,2 @@
+alter table api_keys drop column tenant;
+drop table if exists tenants;
@@ -0,0 +1,9 @@
+create table if not exists tenants
+(
+    name              varchar(63) primary key,
+    max_services      int NOT NULL default 0,
+    max_versions      int NOT NULL default 0,
+    max_payload_bytes int NOT NULL default 0,
+    created_at        timestamptz NOT NULL default now()
+);
+alter table api_keys add column if not exists tenant varchar(63) references tenants (name);
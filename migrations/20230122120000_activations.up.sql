@@ -0,0 +1,15 @@
+create table if not exists activations
+(
+    id          bigserial primary key,
+    service     varchar(255) NOT NULL,
+    environment varchar(255) NOT NULL,
+    version     int NOT NULL,
+    activate_at timestamptz NOT NULL,
+    status      varchar(16) NOT NULL default 'pending',
+    error       text,
+    created_by  varchar(255),
+    created_at  timestamptz NOT NULL default now(),
+    done_at     timestamptz
+);
+create index if not exists ix_activations_pending on activations (activate_at) where status = 'pending';
+create index if not exists ix_activations_service on activations (service, environment);
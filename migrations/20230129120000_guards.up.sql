@@ -0,0 +1,17 @@
+create table if not exists guards
+(
+    id               bigserial primary key,
+    service          varchar(255) NOT NULL,
+    environment      varchar(255) NOT NULL,
+    version          int NOT NULL,
+    previous_version int NOT NULL,
+    health_url       text,
+    watch_until      timestamptz NOT NULL,
+    status           varchar(16) NOT NULL default 'watching',
+    failures         int NOT NULL default 0,
+    reason           text,
+    created_by       varchar(255),
+    created_at       timestamptz NOT NULL default now(),
+    done_at          timestamptz
+);
+create index if not exists ix_guards_watching on guards (service, environment) where status = 'watching';
@@ -0,0 +1,12 @@
+create table if not exists flags
+(
+    service       varchar(255) NOT NULL,
+    environment   varchar(255) NOT NULL,
+    name          varchar(255) NOT NULL,
+    enabled       boolean NOT NULL,
+    default_value text NOT NULL,
+    rules         text NOT NULL,
+    updated_by    varchar(255),
+    updated_at    timestamptz NOT NULL default now(),
+    primary key (service, environment, name)
+);
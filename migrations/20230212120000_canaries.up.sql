@@ -0,0 +1,10 @@
+create table if not exists canaries
+(
+    service     varchar(255) NOT NULL,
+    environment varchar(255) NOT NULL,
+    version     int NOT NULL,
+    percentage  double precision NOT NULL,
+    created_by  varchar(255),
+    created_at  timestamptz NOT NULL default now(),
+    primary key (service, environment)
+);
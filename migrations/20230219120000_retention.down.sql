@@ -0,0 +1,4 @@
+drop table if exists retention_policies;
+
+alter table configs drop column if exists pinned;
+alter table configs drop column if exists created_at;
@@ -0,0 +1,11 @@
+alter table configs add column if not exists created_at timestamptz NOT NULL default now();
+alter table configs add column if not exists pinned boolean NOT NULL default false;
+
+create table if not exists retention_policies
+(
+    service    varchar(255) primary key,
+    keep_last  int NOT NULL,
+    keep_days  int NOT NULL,
+    updated_by varchar(255),
+    updated_at timestamptz NOT NULL default now()
+);
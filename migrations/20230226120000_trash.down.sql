@@ -0,0 +1,4 @@
+delete from configs where deleted_at is not null;
+drop index if exists ix_configs_deleted_at;
+alter table configs drop column if exists deleted_by;
+alter table configs drop column if exists deleted_at;
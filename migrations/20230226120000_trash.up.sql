@@ -0,0 +1,3 @@
+alter table configs add column if not exists deleted_at timestamptz;
+alter table configs add column if not exists deleted_by varchar(255);
+create index if not exists ix_configs_deleted_at on configs (deleted_at) where deleted_at is not null;
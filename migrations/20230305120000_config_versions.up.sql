@@ -0,0 +1,11 @@
+create table if not exists config_versions
+(
+    service      varchar(255) NOT NULL,
+    environment  varchar(255) NOT NULL,
+    last_version int NOT NULL,
+    primary key (service, environment)
+);
+
+insert into config_versions (service, environment, last_version)
+select service, environment, max(version) from configs group by service, environment
+on conflict (service, environment) do nothing;
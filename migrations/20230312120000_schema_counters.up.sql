@@ -0,0 +1,9 @@
+create table if not exists schema_counters
+(
+    service      varchar(255) primary key,
+    last_version int NOT NULL
+);
+
+insert into schema_counters (service, last_version)
+select service, max(version) from schema_versions group by service
+on conflict (service) do nothing;
@@ -24,8 +24,40 @@ const _ = grpc.SupportPackageIsVersion7
 type ConfigSvcClient interface {
 	SetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
 	GetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	GetKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyRequest, error)
 	UpdConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
 	DelConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	ListDeleted(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*DeletedVersionList, error)
+	RestoreConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	PatchConfig(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	ValidateConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ValidationResponse, error)
+	SearchConfigs(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	ListEnvironments(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*EnvironmentList, error)
+	CopyConfig(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	PromoteConfig(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	ListPromotions(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*PromotionList, error)
+	ListActivations(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ActivationList, error)
+	CancelActivation(ctx context.Context, in *Activation, opts ...grpc.CallOption) (*Activation, error)
+	ListGuards(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*GuardList, error)
+	ReportHealth(ctx context.Context, in *HealthReport, opts ...grpc.CallOption) (*Guard, error)
+	SetCanary(ctx context.Context, in *Canary, opts ...grpc.CallOption) (*Canary, error)
+	GetCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*Canary, error)
+	PromoteCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	AbortCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*Canary, error)
+	PinConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error)
+	SetRetention(ctx context.Context, in *RetentionPolicy, opts ...grpc.CallOption) (*RetentionPolicy, error)
+	GetRetention(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*RetentionPolicy, error)
+	DelRetention(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*RetentionPolicy, error)
+	RetentionReport(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ExpiredVersionList, error)
+	SetFlag(ctx context.Context, in *Flag, opts ...grpc.CallOption) (*Flag, error)
+	ListFlags(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*FlagList, error)
+	DelFlag(ctx context.Context, in *Flag, opts ...grpc.CallOption) (*Flag, error)
+	EvaluateFlags(ctx context.Context, in *FlagsRequest, opts ...grpc.CallOption) (*FlagValues, error)
+	SetSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error)
+	GetSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error)
+	DelSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error)
+	ListSchemas(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaList, error)
+	SetCompatibility(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error)
 }
 
 type configSvcClient struct {
@@ -54,6 +86,15 @@ func (c *configSvcClient) GetConfig(ctx context.Context, in *ConfigRequest, opts
 	return out, nil
 }
 
+func (c *configSvcClient) GetKey(ctx context.Context, in *KeyRequest, opts ...grpc.CallOption) (*KeyRequest, error) {
+	out := new(KeyRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/GetKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *configSvcClient) UpdConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
 	out := new(ConfigRequest)
 	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/UpdConfig", in, out, opts...)
@@ -72,14 +113,325 @@ func (c *configSvcClient) DelConfig(ctx context.Context, in *ConfigRequest, opts
 	return out, nil
 }
 
+func (c *configSvcClient) ListDeleted(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*DeletedVersionList, error) {
+	out := new(DeletedVersionList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListDeleted", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) RestoreConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/RestoreConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) PatchConfig(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/PatchConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ValidateConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ValidationResponse, error) {
+	out := new(ValidationResponse)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ValidateConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SearchConfigs(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SearchConfigs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListEnvironments(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*EnvironmentList, error) {
+	out := new(EnvironmentList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListEnvironments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) CopyConfig(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/CopyConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) PromoteConfig(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/PromoteConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListPromotions(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*PromotionList, error) {
+	out := new(PromotionList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListPromotions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListActivations(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ActivationList, error) {
+	out := new(ActivationList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListActivations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) CancelActivation(ctx context.Context, in *Activation, opts ...grpc.CallOption) (*Activation, error) {
+	out := new(Activation)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/CancelActivation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListGuards(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*GuardList, error) {
+	out := new(GuardList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListGuards", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ReportHealth(ctx context.Context, in *HealthReport, opts ...grpc.CallOption) (*Guard, error) {
+	out := new(Guard)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ReportHealth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SetCanary(ctx context.Context, in *Canary, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SetCanary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) GetCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/GetCanary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) PromoteCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/PromoteCanary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) AbortCanary(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*Canary, error) {
+	out := new(Canary)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/AbortCanary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) PinConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigRequest, error) {
+	out := new(ConfigRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/PinConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SetRetention(ctx context.Context, in *RetentionPolicy, opts ...grpc.CallOption) (*RetentionPolicy, error) {
+	out := new(RetentionPolicy)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SetRetention", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) GetRetention(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*RetentionPolicy, error) {
+	out := new(RetentionPolicy)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/GetRetention", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) DelRetention(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*RetentionPolicy, error) {
+	out := new(RetentionPolicy)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/DelRetention", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) RetentionReport(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ExpiredVersionList, error) {
+	out := new(ExpiredVersionList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/RetentionReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SetFlag(ctx context.Context, in *Flag, opts ...grpc.CallOption) (*Flag, error) {
+	out := new(Flag)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SetFlag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListFlags(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*FlagList, error) {
+	out := new(FlagList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListFlags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) DelFlag(ctx context.Context, in *Flag, opts ...grpc.CallOption) (*Flag, error) {
+	out := new(Flag)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/DelFlag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) EvaluateFlags(ctx context.Context, in *FlagsRequest, opts ...grpc.CallOption) (*FlagValues, error) {
+	out := new(FlagValues)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/EvaluateFlags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SetSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error) {
+	out := new(SchemaRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SetSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) GetSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error) {
+	out := new(SchemaRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/GetSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) DelSchema(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error) {
+	out := new(SchemaRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/DelSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) ListSchemas(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaList, error) {
+	out := new(SchemaList)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/ListSchemas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configSvcClient) SetCompatibility(ctx context.Context, in *SchemaRequest, opts ...grpc.CallOption) (*SchemaRequest, error) {
+	out := new(SchemaRequest)
+	err := c.cc.Invoke(ctx, "/pb.ConfigSvc/SetCompatibility", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ConfigSvcServer is the server API for ConfigSvc service.
 // All implementations must embed UnimplementedConfigSvcServer
 // for forward compatibility
 type ConfigSvcServer interface {
 	SetConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
 	GetConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
+	GetKey(context.Context, *KeyRequest) (*KeyRequest, error)
 	UpdConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
 	DelConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
+	ListDeleted(context.Context, *ConfigRequest) (*DeletedVersionList, error)
+	RestoreConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
+	PatchConfig(context.Context, *PatchRequest) (*ConfigRequest, error)
+	ValidateConfig(context.Context, *ConfigRequest) (*ValidationResponse, error)
+	SearchConfigs(context.Context, *SearchRequest) (*SearchResponse, error)
+	ListEnvironments(context.Context, *ConfigRequest) (*EnvironmentList, error)
+	CopyConfig(context.Context, *CopyRequest) (*ConfigRequest, error)
+	PromoteConfig(context.Context, *PromoteRequest) (*ConfigRequest, error)
+	ListPromotions(context.Context, *ConfigRequest) (*PromotionList, error)
+	ListActivations(context.Context, *ConfigRequest) (*ActivationList, error)
+	CancelActivation(context.Context, *Activation) (*Activation, error)
+	ListGuards(context.Context, *ConfigRequest) (*GuardList, error)
+	ReportHealth(context.Context, *HealthReport) (*Guard, error)
+	SetCanary(context.Context, *Canary) (*Canary, error)
+	GetCanary(context.Context, *ConfigRequest) (*Canary, error)
+	PromoteCanary(context.Context, *ConfigRequest) (*ConfigRequest, error)
+	AbortCanary(context.Context, *ConfigRequest) (*Canary, error)
+	PinConfig(context.Context, *ConfigRequest) (*ConfigRequest, error)
+	SetRetention(context.Context, *RetentionPolicy) (*RetentionPolicy, error)
+	GetRetention(context.Context, *ConfigRequest) (*RetentionPolicy, error)
+	DelRetention(context.Context, *ConfigRequest) (*RetentionPolicy, error)
+	RetentionReport(context.Context, *ConfigRequest) (*ExpiredVersionList, error)
+	SetFlag(context.Context, *Flag) (*Flag, error)
+	ListFlags(context.Context, *ConfigRequest) (*FlagList, error)
+	DelFlag(context.Context, *Flag) (*Flag, error)
+	EvaluateFlags(context.Context, *FlagsRequest) (*FlagValues, error)
+	SetSchema(context.Context, *SchemaRequest) (*SchemaRequest, error)
+	GetSchema(context.Context, *SchemaRequest) (*SchemaRequest, error)
+	DelSchema(context.Context, *SchemaRequest) (*SchemaRequest, error)
+	ListSchemas(context.Context, *SchemaRequest) (*SchemaList, error)
+	SetCompatibility(context.Context, *SchemaRequest) (*SchemaRequest, error)
 	mustEmbedUnimplementedConfigSvcServer()
 }
 
@@ -93,12 +445,108 @@ func (UnimplementedConfigSvcServer) SetConfig(context.Context, *ConfigRequest) (
 func (UnimplementedConfigSvcServer) GetConfig(context.Context, *ConfigRequest) (*ConfigRequest, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
 }
+func (UnimplementedConfigSvcServer) GetKey(context.Context, *KeyRequest) (*KeyRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKey not implemented")
+}
 func (UnimplementedConfigSvcServer) UpdConfig(context.Context, *ConfigRequest) (*ConfigRequest, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdConfig not implemented")
 }
 func (UnimplementedConfigSvcServer) DelConfig(context.Context, *ConfigRequest) (*ConfigRequest, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DelConfig not implemented")
 }
+func (UnimplementedConfigSvcServer) ListDeleted(context.Context, *ConfigRequest) (*DeletedVersionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeleted not implemented")
+}
+func (UnimplementedConfigSvcServer) RestoreConfig(context.Context, *ConfigRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) PatchConfig(context.Context, *PatchRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) ValidateConfig(context.Context, *ConfigRequest) (*ValidationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) SearchConfigs(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchConfigs not implemented")
+}
+func (UnimplementedConfigSvcServer) ListEnvironments(context.Context, *ConfigRequest) (*EnvironmentList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEnvironments not implemented")
+}
+func (UnimplementedConfigSvcServer) CopyConfig(context.Context, *CopyRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CopyConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) PromoteConfig(context.Context, *PromoteRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) ListPromotions(context.Context, *ConfigRequest) (*PromotionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPromotions not implemented")
+}
+func (UnimplementedConfigSvcServer) ListActivations(context.Context, *ConfigRequest) (*ActivationList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListActivations not implemented")
+}
+func (UnimplementedConfigSvcServer) CancelActivation(context.Context, *Activation) (*Activation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelActivation not implemented")
+}
+func (UnimplementedConfigSvcServer) ListGuards(context.Context, *ConfigRequest) (*GuardList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGuards not implemented")
+}
+func (UnimplementedConfigSvcServer) ReportHealth(context.Context, *HealthReport) (*Guard, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportHealth not implemented")
+}
+func (UnimplementedConfigSvcServer) SetCanary(context.Context, *Canary) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCanary not implemented")
+}
+func (UnimplementedConfigSvcServer) GetCanary(context.Context, *ConfigRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCanary not implemented")
+}
+func (UnimplementedConfigSvcServer) PromoteCanary(context.Context, *ConfigRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PromoteCanary not implemented")
+}
+func (UnimplementedConfigSvcServer) AbortCanary(context.Context, *ConfigRequest) (*Canary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortCanary not implemented")
+}
+func (UnimplementedConfigSvcServer) PinConfig(context.Context, *ConfigRequest) (*ConfigRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PinConfig not implemented")
+}
+func (UnimplementedConfigSvcServer) SetRetention(context.Context, *RetentionPolicy) (*RetentionPolicy, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRetention not implemented")
+}
+func (UnimplementedConfigSvcServer) GetRetention(context.Context, *ConfigRequest) (*RetentionPolicy, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRetention not implemented")
+}
+func (UnimplementedConfigSvcServer) DelRetention(context.Context, *ConfigRequest) (*RetentionPolicy, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DelRetention not implemented")
+}
+func (UnimplementedConfigSvcServer) RetentionReport(context.Context, *ConfigRequest) (*ExpiredVersionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetentionReport not implemented")
+}
+func (UnimplementedConfigSvcServer) SetFlag(context.Context, *Flag) (*Flag, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFlag not implemented")
+}
+func (UnimplementedConfigSvcServer) ListFlags(context.Context, *ConfigRequest) (*FlagList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFlags not implemented")
+}
+func (UnimplementedConfigSvcServer) DelFlag(context.Context, *Flag) (*Flag, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DelFlag not implemented")
+}
+func (UnimplementedConfigSvcServer) EvaluateFlags(context.Context, *FlagsRequest) (*FlagValues, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvaluateFlags not implemented")
+}
+func (UnimplementedConfigSvcServer) SetSchema(context.Context, *SchemaRequest) (*SchemaRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSchema not implemented")
+}
+func (UnimplementedConfigSvcServer) GetSchema(context.Context, *SchemaRequest) (*SchemaRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchema not implemented")
+}
+func (UnimplementedConfigSvcServer) DelSchema(context.Context, *SchemaRequest) (*SchemaRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DelSchema not implemented")
+}
+func (UnimplementedConfigSvcServer) ListSchemas(context.Context, *SchemaRequest) (*SchemaList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchemas not implemented")
+}
+func (UnimplementedConfigSvcServer) SetCompatibility(context.Context, *SchemaRequest) (*SchemaRequest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCompatibility not implemented")
+}
 func (UnimplementedConfigSvcServer) mustEmbedUnimplementedConfigSvcServer() {}
 
 // UnsafeConfigSvcServer may be embedded to opt out of forward compatibility for this service.
@@ -148,6 +596,24 @@ func _ConfigSvc_GetConfig_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ConfigSvc_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).GetKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/GetKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).GetKey(ctx, req.(*KeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ConfigSvc_UpdConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ConfigRequest)
 	if err := dec(in); err != nil {
@@ -184,21 +650,583 @@ func _ConfigSvc_DelConfig_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
-// ConfigSvc_ServiceDesc is the grpc.ServiceDesc for ConfigSvc service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var ConfigSvc_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "pb.ConfigSvc",
-	HandlerType: (*ConfigSvcServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "SetConfig",
-			Handler:    _ConfigSvc_SetConfig_Handler,
-		},
-		{
-			MethodName: "GetConfig",
+func _ConfigSvc_ListDeleted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListDeleted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListDeleted",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListDeleted(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_RestoreConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).RestoreConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/RestoreConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).RestoreConfig(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_PatchConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).PatchConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/PatchConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).PatchConfig(ctx, req.(*PatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ValidateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ValidateConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ValidateConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ValidateConfig(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SearchConfigs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SearchConfigs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SearchConfigs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SearchConfigs(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListEnvironments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListEnvironments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListEnvironments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListEnvironments(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_CopyConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).CopyConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/CopyConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).CopyConfig(ctx, req.(*CopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_PromoteConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).PromoteConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/PromoteConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).PromoteConfig(ctx, req.(*PromoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListPromotions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListPromotions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListPromotions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListPromotions(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListActivations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListActivations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListActivations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListActivations(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_CancelActivation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Activation)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).CancelActivation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/CancelActivation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).CancelActivation(ctx, req.(*Activation))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListGuards_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListGuards(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListGuards",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListGuards(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ReportHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ReportHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ReportHealth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ReportHealth(ctx, req.(*HealthReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SetCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Canary)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SetCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SetCanary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SetCanary(ctx, req.(*Canary))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_GetCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).GetCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/GetCanary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).GetCanary(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_PromoteCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).PromoteCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/PromoteCanary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).PromoteCanary(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_AbortCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).AbortCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/AbortCanary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).AbortCanary(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_PinConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).PinConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/PinConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).PinConfig(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SetRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetentionPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SetRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SetRetention",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SetRetention(ctx, req.(*RetentionPolicy))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_GetRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).GetRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/GetRetention",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).GetRetention(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_DelRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).DelRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/DelRetention",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).DelRetention(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_RetentionReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).RetentionReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/RetentionReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).RetentionReport(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SetFlag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Flag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SetFlag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SetFlag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SetFlag(ctx, req.(*Flag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListFlags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListFlags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListFlags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListFlags(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_DelFlag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Flag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).DelFlag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/DelFlag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).DelFlag(ctx, req.(*Flag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_EvaluateFlags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).EvaluateFlags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/EvaluateFlags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).EvaluateFlags(ctx, req.(*FlagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SetSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SetSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SetSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SetSchema(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_GetSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).GetSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/GetSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).GetSchema(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_DelSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).DelSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/DelSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).DelSchema(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_ListSchemas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).ListSchemas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/ListSchemas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).ListSchemas(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigSvc_SetCompatibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigSvcServer).SetCompatibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ConfigSvc/SetCompatibility",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigSvcServer).SetCompatibility(ctx, req.(*SchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ConfigSvc_ServiceDesc is the grpc.ServiceDesc for ConfigSvc service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConfigSvc_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.ConfigSvc",
+	HandlerType: (*ConfigSvcServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetConfig",
+			Handler:    _ConfigSvc_SetConfig_Handler,
+		},
+		{
+			MethodName: "GetConfig",
 			Handler:    _ConfigSvc_GetConfig_Handler,
 		},
+		{
+			MethodName: "GetKey",
+			Handler:    _ConfigSvc_GetKey_Handler,
+		},
 		{
 			MethodName: "UpdConfig",
 			Handler:    _ConfigSvc_UpdConfig_Handler,
@@ -207,6 +1235,130 @@ var ConfigSvc_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DelConfig",
 			Handler:    _ConfigSvc_DelConfig_Handler,
 		},
+		{
+			MethodName: "ListDeleted",
+			Handler:    _ConfigSvc_ListDeleted_Handler,
+		},
+		{
+			MethodName: "RestoreConfig",
+			Handler:    _ConfigSvc_RestoreConfig_Handler,
+		},
+		{
+			MethodName: "PatchConfig",
+			Handler:    _ConfigSvc_PatchConfig_Handler,
+		},
+		{
+			MethodName: "ValidateConfig",
+			Handler:    _ConfigSvc_ValidateConfig_Handler,
+		},
+		{
+			MethodName: "SearchConfigs",
+			Handler:    _ConfigSvc_SearchConfigs_Handler,
+		},
+		{
+			MethodName: "ListEnvironments",
+			Handler:    _ConfigSvc_ListEnvironments_Handler,
+		},
+		{
+			MethodName: "CopyConfig",
+			Handler:    _ConfigSvc_CopyConfig_Handler,
+		},
+		{
+			MethodName: "PromoteConfig",
+			Handler:    _ConfigSvc_PromoteConfig_Handler,
+		},
+		{
+			MethodName: "ListPromotions",
+			Handler:    _ConfigSvc_ListPromotions_Handler,
+		},
+		{
+			MethodName: "ListActivations",
+			Handler:    _ConfigSvc_ListActivations_Handler,
+		},
+		{
+			MethodName: "CancelActivation",
+			Handler:    _ConfigSvc_CancelActivation_Handler,
+		},
+		{
+			MethodName: "ListGuards",
+			Handler:    _ConfigSvc_ListGuards_Handler,
+		},
+		{
+			MethodName: "ReportHealth",
+			Handler:    _ConfigSvc_ReportHealth_Handler,
+		},
+		{
+			MethodName: "SetCanary",
+			Handler:    _ConfigSvc_SetCanary_Handler,
+		},
+		{
+			MethodName: "GetCanary",
+			Handler:    _ConfigSvc_GetCanary_Handler,
+		},
+		{
+			MethodName: "PromoteCanary",
+			Handler:    _ConfigSvc_PromoteCanary_Handler,
+		},
+		{
+			MethodName: "AbortCanary",
+			Handler:    _ConfigSvc_AbortCanary_Handler,
+		},
+		{
+			MethodName: "PinConfig",
+			Handler:    _ConfigSvc_PinConfig_Handler,
+		},
+		{
+			MethodName: "SetRetention",
+			Handler:    _ConfigSvc_SetRetention_Handler,
+		},
+		{
+			MethodName: "GetRetention",
+			Handler:    _ConfigSvc_GetRetention_Handler,
+		},
+		{
+			MethodName: "DelRetention",
+			Handler:    _ConfigSvc_DelRetention_Handler,
+		},
+		{
+			MethodName: "RetentionReport",
+			Handler:    _ConfigSvc_RetentionReport_Handler,
+		},
+		{
+			MethodName: "SetFlag",
+			Handler:    _ConfigSvc_SetFlag_Handler,
+		},
+		{
+			MethodName: "ListFlags",
+			Handler:    _ConfigSvc_ListFlags_Handler,
+		},
+		{
+			MethodName: "DelFlag",
+			Handler:    _ConfigSvc_DelFlag_Handler,
+		},
+		{
+			MethodName: "EvaluateFlags",
+			Handler:    _ConfigSvc_EvaluateFlags_Handler,
+		},
+		{
+			MethodName: "SetSchema",
+			Handler:    _ConfigSvc_SetSchema_Handler,
+		},
+		{
+			MethodName: "GetSchema",
+			Handler:    _ConfigSvc_GetSchema_Handler,
+		},
+		{
+			MethodName: "DelSchema",
+			Handler:    _ConfigSvc_DelSchema_Handler,
+		},
+		{
+			MethodName: "ListSchemas",
+			Handler:    _ConfigSvc_ListSchemas_Handler,
+		},
+		{
+			MethodName: "SetCompatibility",
+			Handler:    _ConfigSvc_SetCompatibility_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "configsvc.proto",
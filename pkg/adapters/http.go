@@ -2,14 +2,25 @@ package adapters
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/tidwall/gjson"
+	"github.com/tonx22/gocloudcamp/pkg/formats"
 	Models "github.com/tonx22/gocloudcamp/pkg/models"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// ClientIDHeader identifies the client reading a config, a share of clients
+// gets the canary version.
+const ClientIDHeader = "X-Client-ID"
+
+// DecodeSetRequest reads {"service": ..., "data": ...} as JSON. A body in
+// YAML, TOML, Java properties or dotenv format, given by Content-Type, is the
+// data itself and the service is taken from the service parameter.
 func DecodeSetRequest(ctx context.Context, r *http.Request) (*Models.ConfigRequest, error) {
 	var req Models.ConfigRequest
 	b, err := io.ReadAll(r.Body)
@@ -17,6 +28,24 @@ func DecodeSetRequest(ctx context.Context, r *http.Request) (*Models.ConfigReque
 		return nil, Models.ResponseError{ErrorDescr: "Reading input failure"}
 	}
 
+	if format := formats.FromContentType(r.Header.Get("Content-Type")); len(format) > 0 && format != formats.JSON {
+		req.Service = r.URL.Query().Get("service")
+		if len(req.Service) == 0 {
+			return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+		}
+		req.Environment = r.URL.Query().Get("environment")
+		req.Parent = r.URL.Query().Get("parent")
+		req.ActivateAt, err = parseActivateAt(r.URL.Query().Get("activate_at"))
+		if err != nil {
+			return nil, err
+		}
+		req.Data.Members, err = formats.Decode(format, b)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error(), Status: http.StatusBadRequest}
+		}
+		return &req, nil
+	}
+
 	json := string(b)
 	fmt.Println(json)
 	if !gjson.Valid(json) {
@@ -32,6 +61,34 @@ func DecodeSetRequest(ctx context.Context, r *http.Request) (*Models.ConfigReque
 		req.Service = service.String()
 	}
 
+	env := gjson.Get(json, "environment")
+	if env.Exists() && env.Type != gjson.String && env.Type != gjson.Null {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid json: environment field must be a string", Status: http.StatusBadRequest}
+	}
+	req.Environment = env.String()
+	if len(req.Environment) == 0 {
+		req.Environment = r.URL.Query().Get("environment")
+	}
+
+	parent := gjson.Get(json, "parent")
+	if parent.Exists() && parent.Type != gjson.String && parent.Type != gjson.Null {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid json: parent field must be a string", Status: http.StatusBadRequest}
+	}
+	req.Parent = parent.String()
+
+	activateAt := gjson.Get(json, "activate_at")
+	if activateAt.Exists() && activateAt.Type != gjson.String && activateAt.Type != gjson.Null {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid json: activate_at field must be a string", Status: http.StatusBadRequest}
+	}
+	at := activateAt.String()
+	if len(at) == 0 {
+		at = r.URL.Query().Get("activate_at")
+	}
+	req.ActivateAt, err = parseActivateAt(at)
+	if err != nil {
+		return nil, err
+	}
+
 	data := gjson.Get(json, "data")
 	if !data.Exists() {
 		return nil, Models.ResponseError{ErrorDescr: "Invalid json: data field missing", Status: http.StatusBadRequest}
@@ -39,15 +96,9 @@ func DecodeSetRequest(ctx context.Context, r *http.Request) (*Models.ConfigReque
 		return nil, Models.ResponseError{ErrorDescr: "Invalid json: data field is empty", Status: http.StatusBadRequest}
 	}
 
-	req.Data = make(map[string]interface{})
-	for _, w := range data.Array() {
-		d, ok := w.Value().(map[string]interface{})
-		if !ok {
-			return nil, Models.ResponseError{ErrorDescr: "Invalid json: data array is invalid", Status: http.StatusBadRequest}
-		}
-		for k, v := range d {
-			req.Data[k] = v
-		}
+	err = req.Data.UnmarshalJSON([]byte(data.Raw))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid json: " + err.Error(), Status: http.StatusBadRequest}
 	}
 	return &req, nil
 }
@@ -60,6 +111,7 @@ func DecodeGetRequest(_ context.Context, r *http.Request) (*Models.ConfigRequest
 		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
 	}
 	req.Service = service
+	req.Environment = r.URL.Query().Get("environment")
 
 	v := r.URL.Query().Get("version")
 	if len(v) > 0 {
@@ -83,5 +135,418 @@ func DecodeGetRequest(_ context.Context, r *http.Request) (*Models.ConfigRequest
 	if r.URL.Query().Get("extended") == "true" {
 		req.Extended = true
 	}
+	if r.URL.Query().Get("reveal") == "true" {
+		req.Reveal = true
+	}
+	if r.URL.Query().Get("raw") == "true" {
+		req.Raw = true
+	}
+	if r.URL.Query().Get("resolve") == "false" {
+		req.Unresolved = true
+	}
+	if r.URL.Query().Get("purge") == "true" {
+		req.Purge = true
+	}
+
+	activateAt, err := parseActivateAt(r.URL.Query().Get("activate_at"))
+	if err != nil {
+		return nil, err
+	}
+	req.ActivateAt = activateAt
+	req.Watch, err = parseWatch(r.URL.Query().Get("watch"))
+	if err != nil {
+		return nil, err
+	}
+	req.HealthURL = r.URL.Query().Get("health_url")
+	req.ClientID = r.Header.Get(ClientIDHeader)
+
+	req.Format = r.URL.Query().Get("format")
+	if len(req.Format) == 0 {
+		req.Format = formats.FromAccept(r.Header.Get("Accept"))
+	}
+	if _, ok := formats.ContentTypes[req.Format]; len(req.Format) > 0 && !ok {
+		return nil, Models.ResponseError{ErrorDescr: "format parameter incorrect, must be one of json, yaml, toml, properties, dotenv, configmap or secret", Status: http.StatusBadRequest}
+	}
+	if req.Extended && len(req.Format) > 0 && req.Format != formats.JSON {
+		return nil, Models.ResponseError{ErrorDescr: "extended is only supported for json", Status: http.StatusBadRequest}
+	}
 	return &req, nil
 }
+
+func DecodeKeyRequest(_ context.Context, r *http.Request) (*Models.KeyRequest, error) {
+	var req Models.KeyRequest
+
+	service := r.URL.Query().Get("service")
+	if len(service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Service = service
+	req.Environment = r.URL.Query().Get("environment")
+
+	if !r.URL.Query().Has("path") {
+		return nil, Models.ResponseError{ErrorDescr: "path parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Path = r.URL.Query().Get("path")
+
+	v := r.URL.Query().Get("version")
+	if len(v) > 0 {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		req.Version = version
+	}
+
+	if r.URL.Query().Get("reveal") == "true" {
+		req.Reveal = true
+	}
+	req.ClientID = r.Header.Get(ClientIDHeader)
+	return &req, nil
+}
+
+// DecodeSearchRequest reads the path and value parameters. A value that is
+// valid JSON is matched as such, e.g. value=5432 matches the number and
+// value="5432" the string, any other value is matched as a string.
+func DecodeSearchRequest(_ context.Context, r *http.Request) (*Models.SearchRequest, error) {
+	var req Models.SearchRequest
+
+	req.Path = r.URL.Query().Get("path")
+	if len(req.Path) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "path parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Environment = r.URL.Query().Get("environment")
+
+	if r.URL.Query().Has("value") {
+		value := r.URL.Query().Get("value")
+		if gjson.Valid(value) {
+			req.Value = []byte(value)
+		} else {
+			req.Value, _ = json.Marshal(value)
+		}
+	}
+	return &req, nil
+}
+
+// DecodePatchRequest reads a patch of the config given by the service parameter.
+// The patch type is taken from Content-Type, application/merge-patch+json or
+// application/json-patch+json, or guessed from the body: an array of
+// operations is a JSON Patch, an object is a merge patch.
+func DecodePatchRequest(_ context.Context, r *http.Request) (*Models.PatchRequest, error) {
+	var req Models.PatchRequest
+
+	service := r.URL.Query().Get("service")
+	if len(service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Service = service
+	req.Environment = r.URL.Query().Get("environment")
+
+	v := r.URL.Query().Get("version")
+	if len(v) > 0 {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		req.Version = version
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Reading input failure"}
+	}
+	if !gjson.ValidBytes(b) {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid input json", Status: http.StatusBadRequest}
+	}
+	req.Patch = b
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/merge-patch+json":
+		req.Type = Models.MergePatch
+	case "application/json-patch+json":
+		req.Type = Models.JSONPatch
+	default:
+		if gjson.ParseBytes(b).IsArray() {
+			req.Type = Models.JSONPatch
+		} else {
+			req.Type = Models.MergePatch
+		}
+	}
+	return &req, nil
+}
+
+// DecodeCopyRequest reads the service, the from and to environments and the
+// version to copy, the used one if it is not given.
+func DecodeCopyRequest(_ context.Context, r *http.Request) (*Models.CopyRequest, error) {
+	var req Models.CopyRequest
+
+	service := r.URL.Query().Get("service")
+	if len(service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Service = service
+	req.From = r.URL.Query().Get("from")
+	req.To = r.URL.Query().Get("to")
+	if len(req.To) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "to parameter must be specified", Status: http.StatusBadRequest}
+	}
+
+	v := r.URL.Query().Get("version")
+	if len(v) > 0 {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		req.Version = version
+	}
+	return &req, nil
+}
+
+// DecodePromoteRequest reads the source service, environment and version, the
+// used one if it is not given, the target service and environment and whether
+// to activate the new version.
+func DecodePromoteRequest(_ context.Context, r *http.Request) (*Models.PromoteRequest, error) {
+	var req Models.PromoteRequest
+
+	service := r.URL.Query().Get("service")
+	if len(service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Service = service
+	req.Environment = r.URL.Query().Get("environment")
+	req.Target = r.URL.Query().Get("target")
+	if len(req.Target) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "target parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.TargetEnvironment = r.URL.Query().Get("target_environment")
+
+	v := r.URL.Query().Get("version")
+	if len(v) > 0 {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		req.Version = version
+	}
+
+	activate := r.URL.Query().Get("activate")
+	if len(activate) > 0 {
+		switch activate {
+		case "true", "false":
+			req.Activate, _ = strconv.ParseBool(activate)
+		default:
+			return nil, Models.ResponseError{ErrorDescr: "activate parameter incorrect, must be a true or false", Status: http.StatusBadRequest}
+		}
+	}
+	return &req, nil
+}
+
+func DecodeSchemaRequest(_ context.Context, r *http.Request) (*Models.SchemaRequest, error) {
+	var req Models.SchemaRequest
+
+	service := r.URL.Query().Get("service")
+	if len(service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Service = service
+
+	v := r.URL.Query().Get("version")
+	if len(v) > 0 {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		req.Version = version
+	}
+	req.Compatibility = r.URL.Query().Get("mode")
+
+	if r.Method == http.MethodPut && len(req.Compatibility) == 0 {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Reading input failure"}
+		}
+		if !gjson.ValidBytes(b) {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid input json", Status: http.StatusBadRequest}
+		}
+		req.Schema = b
+	}
+	return &req, nil
+}
+
+// DecodeActivationRequest reads the service and the id of a scheduled activation.
+func DecodeActivationRequest(_ context.Context, r *http.Request) (*Models.Activation, error) {
+	var req Models.Activation
+
+	req.Service = r.URL.Query().Get("service")
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "id parameter incorrect, must be a number", Status: http.StatusBadRequest}
+	}
+	req.ID = id
+	return &req, nil
+}
+
+// DecodeCanaryRequest reads the service, environment, version and percentage
+// of a canary.
+func DecodeCanaryRequest(_ context.Context, r *http.Request) (*Models.Canary, error) {
+	var req Models.Canary
+
+	req.Service = r.URL.Query().Get("service")
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Environment = r.URL.Query().Get("environment")
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "version parameter incorrect, must be a number", Status: http.StatusBadRequest}
+	}
+	req.Version = version
+	percentage, err := strconv.ParseFloat(r.URL.Query().Get("percentage"), 64)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "percentage parameter incorrect, must be a number", Status: http.StatusBadRequest}
+	}
+	req.Percentage = percentage
+	return &req, nil
+}
+
+// DecodePinRequest reads the version to pin like DecodeGetRequest and pinned,
+// true if it is not given.
+func DecodePinRequest(ctx context.Context, r *http.Request) (*Models.ConfigRequest, error) {
+	req, err := DecodeGetRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	switch pinned := r.URL.Query().Get("pinned"); pinned {
+	case "", "true":
+		req.Pinned = true
+	case "false":
+	default:
+		return nil, Models.ResponseError{ErrorDescr: "pinned parameter incorrect, must be a true or false", Status: http.StatusBadRequest}
+	}
+	return req, nil
+}
+
+// DecodeRetentionRequest reads the service and the keep_last and keep_days
+// rules of a retention policy, 0 if they are not given.
+func DecodeRetentionRequest(_ context.Context, r *http.Request) (*Models.RetentionPolicy, error) {
+	var req Models.RetentionPolicy
+
+	req.Service = r.URL.Query().Get("service")
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	for name, v := range map[string]*int{"keep_last": &req.KeepLast, "keep_days": &req.KeepDays} {
+		s := r.URL.Query().Get(name)
+		if len(s) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: name + " parameter incorrect, must be a number", Status: http.StatusBadRequest}
+		}
+		*v = n
+	}
+	return &req, nil
+}
+
+// DecodeFlagRequest reads the service, environment and name of a flag and,
+// for PUT, the flag as JSON: {"name": ..., "enabled": ..., "default": ..., "rules": [...]}.
+func DecodeFlagRequest(_ context.Context, r *http.Request) (*Models.Flag, error) {
+	var req Models.Flag
+
+	if r.Method == http.MethodPut {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Reading input failure"}
+		}
+		if err := json.Unmarshal(b, &req.Flag); err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid input json: " + err.Error(), Status: http.StatusBadRequest}
+		}
+	}
+	req.Service = r.URL.Query().Get("service")
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Environment = r.URL.Query().Get("environment")
+	if name := r.URL.Query().Get("name"); len(name) > 0 {
+		req.Name = name
+	}
+	if r.Method == http.MethodDelete && len(req.Name) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "name parameter must be specified", Status: http.StatusBadRequest}
+	}
+	return &req, nil
+}
+
+// DecodeFlagsRequest reads the service and environment whose flags are to be
+// evaluated and the evaluation context as JSON:
+// {"client_id": ..., "attributes": {...}, "flags": [...]}.
+func DecodeFlagsRequest(_ context.Context, r *http.Request) (*Models.FlagsRequest, error) {
+	var req Models.FlagsRequest
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Reading input failure"}
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &req); err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid input json: " + err.Error(), Status: http.StatusBadRequest}
+		}
+	}
+	if service := r.URL.Query().Get("service"); len(service) > 0 {
+		req.Service = service
+	}
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	if env := r.URL.Query().Get("environment"); len(env) > 0 {
+		req.Environment = env
+	}
+	return &req, nil
+}
+
+// DecodeHealthRequest reads the health reported for the guarded activation of
+// a service in an environment.
+func DecodeHealthRequest(_ context.Context, r *http.Request) (*Models.HealthReport, error) {
+	var req Models.HealthReport
+
+	req.Service = r.URL.Query().Get("service")
+	if len(req.Service) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "service parameter must be specified", Status: http.StatusBadRequest}
+	}
+	req.Environment = r.URL.Query().Get("environment")
+	switch healthy := r.URL.Query().Get("healthy"); healthy {
+	case "true", "false":
+		req.Healthy, _ = strconv.ParseBool(healthy)
+	default:
+		return nil, Models.ResponseError{ErrorDescr: "healthy parameter must be true or false", Status: http.StatusBadRequest}
+	}
+	req.Reason = r.URL.Query().Get("reason")
+	return &req, nil
+}
+
+// parseWatch parses the watch window of a guarded activation, 0 if it is empty.
+func parseWatch(s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, Models.ResponseError{ErrorDescr: "watch incorrect, must be a positive duration like 10m", Status: http.StatusBadRequest}
+	}
+	return d, nil
+}
+
+// parseActivateAt parses an activate_at timestamp, nil if it is empty.
+func parseActivateAt(s string) (*time.Time, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "activate_at incorrect, must be an RFC 3339 timestamp like 2023-01-22T03:00:00Z", Status: http.StatusBadRequest}
+	}
+	return &t, nil
+}
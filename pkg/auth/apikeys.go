@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/lib/pq"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"time"
+)
+
+// APIKey describes a stored API key. The key itself is only known when it is created,
+// the database keeps its SHA-256 hash.
+type APIKey struct {
+	Name      string     `json:"name"`
+	Key       string     `json:"key,omitempty"`
+	Admin     bool       `json:"admin"`
+	Tenant    string     `json:"tenant,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyStore keeps API keys in the api_keys table.
+type APIKeyStore struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyStore(db *sql.DB) *APIKeyStore {
+	return &APIKeyStore{DB: db}
+}
+
+// Create generates a new key for name, belonging to tenant unless it is empty.
+// The returned APIKey is the only place the key is shown.
+func (s *APIKeyStore) Create(name string, admin bool, tenant string) (*APIKey, error) {
+	if len(name) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "name must be specified", Status: http.StatusBadRequest}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	key := APIKey{Name: name, Key: "gcc_" + base64.RawURLEncoding.EncodeToString(b), Admin: admin, Tenant: tenant}
+
+	row := s.DB.QueryRow("insert into api_keys (name, key_hash, admin, tenant) values ($1, $2, $3, $4) on conflict (name) do nothing returning created_at",
+		name, hashKey(key.Key), admin, nullString(tenant))
+	err := row.Scan(&key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "API key with this name already exists", Status: http.StatusConflict}
+	} else if e, ok := err.(*pq.Error); ok && e.Code == "23503" {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Unknown tenant %s", tenant), Status: http.StatusBadRequest}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) List() ([]APIKey, error) {
+	rows, err := s.DB.Query("select name, admin, coalesce(tenant, ''), created_at, revoked_at from api_keys order by name")
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		var k APIKey
+		err := rows.Scan(&k.Name, &k.Admin, &k.Tenant, &k.CreatedAt, &k.RevokedAt)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyStore) Revoke(name string) error {
+	res, err := s.DB.Exec("update api_keys set revoked_at = now() where name = $1 and revoked_at is null", name)
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Models.ResponseError{ErrorDescr: "No active API key with this name", Status: http.StatusNotFound}
+	}
+	return nil
+}
+
+// Lookup returns the identity owning key, or nil if the key is unknown or revoked.
+func (s *APIKeyStore) Lookup(key string) (*Identity, error) {
+	id := Identity{Method: "apikey"}
+	row := s.DB.QueryRow("select name, admin, coalesce(tenant, '') from api_keys where key_hash = $1 and revoked_at is null", hashKey(key))
+	err := row.Scan(&id.Subject, &id.Admin, &id.Tenant)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &id, nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
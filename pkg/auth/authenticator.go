@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// Authenticator resolves bearer tokens and API keys into identities. Tokens with
+// three dot-separated segments are treated as JWTs, anything else as an API key
+// which is checked against the static keys first and the key store second.
+type Authenticator struct {
+	StaticKeys []string
+	Keys       *APIKeyStore
+	JWT        *JWTVerifier
+}
+
+func (a *Authenticator) Authenticate(_ context.Context, token string) (*Identity, error) {
+	if len(token) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "Authentication required", Status: http.StatusUnauthorized}
+	}
+
+	if strings.Count(token, ".") == 2 && a.JWT != nil {
+		id, err := a.JWT.Verify(token)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid token: " + err.Error(), Status: http.StatusUnauthorized}
+		}
+		return id, nil
+	}
+
+	for _, k := range a.StaticKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(token)) == 1 {
+			return &Identity{Subject: "static", Method: "static", Admin: true}, nil
+		}
+	}
+	if a.Keys != nil {
+		id, err := a.Keys.Lookup(token)
+		if err != nil {
+			return nil, err
+		}
+		if id != nil {
+			return id, nil
+		}
+	}
+	return nil, Models.ResponseError{ErrorDescr: "Invalid credentials", Status: http.StatusUnauthorized}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"database/sql"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"path"
+	"time"
+)
+
+// Role is a level of access to the configs of a service. Every role includes
+// the permissions of the lower ones: reader < writer < admin. RoleReveal,
+// allowing to read secret values, is outside the hierarchy and is only held
+// when granted explicitly.
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleWriter Role = "writer"
+	RoleAdmin  Role = "admin"
+	RoleReveal Role = "reveal"
+)
+
+var roleRank = map[Role]int{RoleReader: 1, RoleWriter: 2, RoleAdmin: 3, RoleReveal: 0}
+
+// Includes reports whether r grants at least the permissions of other.
+func (r Role) Includes(other Role) bool {
+	if r == RoleReveal || other == RoleReveal {
+		return r == other
+	}
+	return roleRank[r] >= roleRank[other] && roleRank[other] > 0
+}
+
+// Grant gives a subject a role on every service matching a glob pattern, e.g. "payments-*".
+type Grant struct {
+	ID        int64     `json:"id"`
+	Subject   string    `json:"subject"`
+	Pattern   string    `json:"pattern"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GrantStore keeps grants in the access_grants table.
+type GrantStore struct {
+	DB *sql.DB
+}
+
+func NewGrantStore(db *sql.DB) *GrantStore {
+	return &GrantStore{DB: db}
+}
+
+// Create stores g, replacing the role of an existing grant for the same subject and pattern.
+func (s *GrantStore) Create(g Grant) (*Grant, error) {
+	if len(g.Subject) == 0 || len(g.Pattern) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "subject and pattern must be specified", Status: http.StatusBadRequest}
+	}
+	if _, ok := roleRank[g.Role]; !ok {
+		return nil, Models.ResponseError{ErrorDescr: "role must be one of reader, writer, admin, reveal", Status: http.StatusBadRequest}
+	}
+	if _, err := path.Match(g.Pattern, ""); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "pattern is not a valid glob", Status: http.StatusBadRequest}
+	}
+
+	row := s.DB.QueryRow(`insert into access_grants (subject, pattern, role) values ($1, $2, $3)
+		on conflict (subject, pattern) do update set role = excluded.role returning id, created_at`, g.Subject, g.Pattern, g.Role)
+	err := row.Scan(&g.ID, &g.CreatedAt)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &g, nil
+}
+
+// List returns the grants of subject, or all grants when subject is empty.
+func (s *GrantStore) List(subject string) ([]Grant, error) {
+	rows, err := s.DB.Query("select id, subject, pattern, role, created_at from access_grants where $1 = '' or subject = $1 order by subject, pattern", subject)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	grants := make([]Grant, 0)
+	for rows.Next() {
+		var g Grant
+		err := rows.Scan(&g.ID, &g.Subject, &g.Pattern, &g.Role, &g.CreatedAt)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+func (s *GrantStore) Delete(id int64) error {
+	res, err := s.DB.Exec("delete from access_grants where id = $1", id)
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Models.ResponseError{ErrorDescr: "No grant with this id", Status: http.StatusNotFound}
+	}
+	return nil
+}
+
+// Authorize reports whether subject holds role, or a higher one, on service.
+func (s *GrantStore) Authorize(subject, service string, role Role) (bool, error) {
+	grants, err := s.List(subject)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range grants {
+		if ok, _ := path.Match(g.Pattern, service); ok && g.Role.Includes(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AuthorizeAll reports whether subject holds role, or a higher one, on all
+// services: only a grant with the pattern "*" itself counts, not patterns
+// that merely match the name "*" like "?" or "[*]".
+func (s *GrantStore) AuthorizeAll(subject string, role Role) (bool, error) {
+	grants, err := s.List(subject)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range grants {
+		if g.Pattern == "*" && g.Role.Includes(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
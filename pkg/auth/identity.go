@@ -0,0 +1,62 @@
+package auth
+
+import "context"
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Subject string
+	// Method tells how the caller was authenticated: "static", "apikey", "jwt", "mtls" or "header".
+	Method string
+	// Admin identities may manage credentials through the admin API.
+	Admin bool
+	// Tenant confines the identity to the services of one tenant when
+	// multi-tenancy is enabled, global identities have none.
+	Tenant string
+}
+
+// Principal names the identity in grants. Subjects are only unique within a
+// tenant, so those of tenant identities are qualified as "tenant/subject".
+func (id *Identity) Principal() string {
+	if len(id.Tenant) > 0 {
+		return id.Tenant + "/" + id.Subject
+	}
+	return id.Subject
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity stored in ctx, if any.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Subject returns the subject of the identity in ctx, or "anonymous".
+func Subject(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id.Subject
+	}
+	return "anonymous"
+}
+
+type revealKey struct{}
+
+// WithRevealPermission marks ctx as allowed to read secret values in clear text.
+func WithRevealPermission(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revealKey{}, true)
+}
+
+// CanReveal reports whether the caller may read secret values: admin identities
+// always can, others need the permission granted by the authorization layer.
+func CanReveal(ctx context.Context) bool {
+	if id, ok := FromContext(ctx); ok && id.Admin {
+		return true
+	}
+	allowed, _ := ctx.Value(revealKey{}).(bool)
+	return allowed
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTVerifier validates HMAC (HS256/384/512) and RSA (RS256/384/512) signed bearer
+// tokens against the keys of a local JWKS file.
+type JWTVerifier struct {
+	Issuer   string
+	Audience string
+
+	hmacKeys map[string][]byte
+	rsaKeys  map[string]*rsa.PublicKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewJWTVerifier loads the keys of the JWKS file. Only "oct" and "RSA" keys are supported.
+func NewJWTVerifier(jwksFile, issuer, audience string) (*JWTVerifier, error) {
+	b, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read JWKS file: %v", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS file: %v", err)
+	}
+
+	v := JWTVerifier{Issuer: issuer, Audience: audience, hmacKeys: make(map[string][]byte), rsaKeys: make(map[string]*rsa.PublicKey)}
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oct key %q: %v", k.Kid, err)
+			}
+			v.hmacKeys[k.Kid] = secret
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA key %q: %v", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA key %q: %v", k.Kid, err)
+			}
+			v.rsaKeys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		default:
+			return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+		}
+	}
+	return &v, nil
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Admin     bool            `json:"admin"`
+	Tenant    string          `json:"tenant"`
+}
+
+// Verify checks the signature and the registered claims of token and returns the
+// caller identity. Tokens must expire, ones without exp are rejected.
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var c jwtClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if c.ExpiresAt == nil {
+		return nil, errors.New("token expiration missing")
+	}
+	if now >= *c.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	if c.NotBefore != nil && now < *c.NotBefore {
+		return nil, errors.New("token not valid yet")
+	}
+	if len(v.Issuer) > 0 && c.Issuer != v.Issuer {
+		return nil, errors.New("unexpected token issuer")
+	}
+	if len(v.Audience) > 0 && !hasAudience(c.Audience, v.Audience) {
+		return nil, errors.New("unexpected token audience")
+	}
+	if len(c.Subject) == 0 {
+		return nil, errors.New("token subject missing")
+	}
+	return &Identity{Subject: c.Subject, Method: "jwt", Admin: c.Admin, Tenant: c.Tenant}, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, kid, signed string, sig []byte) error {
+	switch alg {
+	case "HS256", "HS384", "HS512":
+		for id, key := range v.hmacKeys {
+			if len(kid) > 0 && id != kid {
+				continue
+			}
+			var mac []byte
+			switch alg {
+			case "HS256":
+				m := hmac.New(sha256.New, key)
+				m.Write([]byte(signed))
+				mac = m.Sum(nil)
+			case "HS384":
+				m := hmac.New(sha512.New384, key)
+				m.Write([]byte(signed))
+				mac = m.Sum(nil)
+			default:
+				m := hmac.New(sha512.New, key)
+				m.Write([]byte(signed))
+				mac = m.Sum(nil)
+			}
+			if hmac.Equal(mac, sig) {
+				return nil
+			}
+		}
+	case "RS256", "RS384", "RS512":
+		hash := map[string]crypto.Hash{"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512}[alg]
+		h := hash.New()
+		h.Write([]byte(signed))
+		digest := h.Sum(nil)
+		for id, key := range v.rsaKeys {
+			if len(kid) > 0 && id != kid {
+				continue
+			}
+			if rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	return errors.New("invalid token signature")
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array of strings, contains audience.
+func hasAudience(aud json.RawMessage, audience string) bool {
+	var one string
+	if json.Unmarshal(aud, &one) == nil {
+		return one == audience
+	}
+	var many []string
+	if json.Unmarshal(aud, &many) == nil {
+		for _, a := range many {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
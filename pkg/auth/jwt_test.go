@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, _  = rsa.GenerateKey(rand.Reader, 2048)
+)
+
+func segment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sign builds a token with the given header and claims, signed for HS256 and
+// RS256 and left unsigned otherwise.
+func sign(header, claims map[string]interface{}) string {
+	signed := segment(header) + "." + segment(claims)
+	var sig []byte
+	switch header["alg"] {
+	case "HS256":
+		m := hmac.New(sha256.New, hmacSecret)
+		m.Write([]byte(signed))
+		sig = m.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newVerifier(t *testing.T, issuer, audience string) *JWTVerifier {
+	keys := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "oct", "kid": "h1", "k": base64.RawURLEncoding.EncodeToString(hmacSecret)},
+		{"kty": "RSA", "kid": "r1", "n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+	}}
+	b, err := json.Marshal(keys)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, b, 0600))
+	v, err := NewJWTVerifier(file, issuer, audience)
+	require.NoError(t, err)
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := newVerifier(t, "issuer", "config")
+	now := time.Now().Unix()
+	hs := map[string]interface{}{"alg": "HS256", "kid": "h1"}
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "alice", "iss": "issuer", "aud": "config", "exp": now + 60}
+		for k, e := range extra {
+			if e == nil {
+				delete(c, k)
+			} else {
+				c[k] = e
+			}
+		}
+		return c
+	}
+	valid := sign(hs, claims(nil))
+
+	tests := []struct {
+		name  string
+		token string
+		want  *Identity
+		err   string
+	}{
+		{"HS256", valid, &Identity{Subject: "alice", Method: "jwt"}, ""},
+		{"RS256", sign(map[string]interface{}{"alg": "RS256", "kid": "r1"}, claims(map[string]interface{}{"admin": true, "tenant": "acme"})),
+			&Identity{Subject: "alice", Method: "jwt", Admin: true, Tenant: "acme"}, ""},
+		{"no kid tries every key", sign(map[string]interface{}{"alg": "HS256"}, claims(nil)), &Identity{Subject: "alice", Method: "jwt"}, ""},
+		{"audience array", sign(hs, claims(map[string]interface{}{"aud": []string{"other", "config"}})), &Identity{Subject: "alice", Method: "jwt"}, ""},
+		{"nbf in the past", sign(hs, claims(map[string]interface{}{"nbf": now - 10})), &Identity{Subject: "alice", Method: "jwt"}, ""},
+		{"bad signature", valid[:len(valid)-4] + "AAAA", nil, "invalid token signature"},
+		{"claims changed", strings.Join([]string{strings.Split(valid, ".")[0], segment(claims(map[string]interface{}{"admin": true})), strings.Split(valid, ".")[2]}, "."),
+			nil, "invalid token signature"},
+		{"kid of a key of another type", sign(map[string]interface{}{"alg": "HS256", "kid": "r1"}, claims(nil)), nil, "invalid token signature"},
+		{"kid mismatch", sign(map[string]interface{}{"alg": "HS256", "kid": "h2"}, claims(nil)), nil, "invalid token signature"},
+		{"alg none", sign(map[string]interface{}{"alg": "none"}, claims(nil)), nil, `unsupported token algorithm "none"`},
+		{"unknown alg", sign(map[string]interface{}{"alg": "ES256", "kid": "h1"}, claims(nil)), nil, `unsupported token algorithm "ES256"`},
+		{"expired", sign(hs, claims(map[string]interface{}{"exp": now - 1})), nil, "token expired"},
+		{"expires now", sign(hs, claims(map[string]interface{}{"exp": now})), nil, "token expired"},
+		{"no exp", sign(hs, claims(map[string]interface{}{"exp": nil})), nil, "token expiration missing"},
+		{"nbf in the future", sign(hs, claims(map[string]interface{}{"nbf": now + 60})), nil, "token not valid yet"},
+		{"wrong issuer", sign(hs, claims(map[string]interface{}{"iss": "other"})), nil, "unexpected token issuer"},
+		{"wrong audience", sign(hs, claims(map[string]interface{}{"aud": "other"})), nil, "unexpected token audience"},
+		{"audience array without ours", sign(hs, claims(map[string]interface{}{"aud": []string{"a", "b"}})), nil, "unexpected token audience"},
+		{"no audience", sign(hs, claims(map[string]interface{}{"aud": nil})), nil, "unexpected token audience"},
+		{"no subject", sign(hs, claims(map[string]interface{}{"sub": nil})), nil, "token subject missing"},
+		{"two segments", "a.b", nil, "malformed token"},
+		{"header not base64", "!!." + strings.SplitN(valid, ".", 2)[1], nil, "malformed token"},
+		{"header not JSON", base64.RawURLEncoding.EncodeToString([]byte("{")) + "." + strings.SplitN(valid, ".", 2)[1], nil, "malformed token"},
+		{"signature not base64", valid + "!", nil, "malformed token signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := v.Verify(tt.token)
+			if len(tt.err) > 0 {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, id)
+		})
+	}
+
+	// Without an expected issuer and audience, any are accepted.
+	id, err := newVerifier(t, "", "").Verify(sign(hs, claims(map[string]interface{}{"iss": nil, "aud": nil})))
+	require.NoError(t, err)
+	require.Equal(t, "alice", id.Subject)
+}
+
+func TestNewJWTVerifier(t *testing.T) {
+	tests := []struct {
+		name string
+		jwks string
+		err  string
+	}{
+		{"not JSON", `{`, "invalid JWKS file"},
+		{"unsupported key type", `{"keys":[{"kty":"EC","kid":"e1"}]}`, `unsupported key type "EC"`},
+		{"invalid oct key", `{"keys":[{"kty":"oct","kid":"h1","k":"!"}]}`, `invalid oct key "h1"`},
+		{"invalid RSA modulus", `{"keys":[{"kty":"RSA","kid":"r1","n":"!","e":"AQAB"}]}`, `invalid RSA key "r1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "jwks.json")
+			require.NoError(t, os.WriteFile(file, []byte(tt.jwks), 0600))
+			_, err := NewJWTVerifier(file, "", "")
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+	_, err := NewJWTVerifier(filepath.Join(t.TempDir(), "missing.json"), "", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't read JWKS file")
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := &Authenticator{StaticKeys: []string{"static-key"}, JWT: newVerifier(t, "", "")}
+	token := sign(map[string]interface{}{"alg": "HS256"}, map[string]interface{}{"sub": "bob", "exp": time.Now().Unix() + 60})
+
+	tests := []struct {
+		name   string
+		token  string
+		want   *Identity
+		status int
+	}{
+		{"jwt", token, &Identity{Subject: "bob", Method: "jwt"}, 0},
+		{"static key", "static-key", &Identity{Subject: "static", Method: "static", Admin: true}, 0},
+		{"no token", "", nil, 401},
+		{"invalid jwt", token + "x", nil, 401},
+		{"unknown key", "other-key", nil, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Authenticate(context.Background(), tt.token)
+			if tt.status > 0 {
+				require.Error(t, err)
+				require.Equal(t, tt.status, err.(Models.ResponseError).Status)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, id)
+		})
+	}
+}
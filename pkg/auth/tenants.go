@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Tenant is an organization whose services are isolated from those of the
+// others. A quota of 0 means unlimited.
+type Tenant struct {
+	Name            string `json:"name"`
+	MaxServices     int    `json:"max_services"`
+	MaxVersions     int    `json:"max_versions"`
+	MaxPayloadBytes int    `json:"max_payload_bytes"`
+	// Services and Versions tell how much of the quotas is used.
+	Services  int       `json:"services"`
+	Versions  int       `json:"versions"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var validTenant = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// TenantStore keeps tenants in the tenants table. The services of a tenant are
+// stored as "tenant/service".
+type TenantStore struct {
+	DB *sql.DB
+}
+
+func NewTenantStore(db *sql.DB) *TenantStore {
+	return &TenantStore{DB: db}
+}
+
+func (s *TenantStore) Create(t Tenant) (*Tenant, error) {
+	if !validTenant.MatchString(t.Name) {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid tenant name %q, must be letters, digits, '.', '_' and '-' up to 63 characters", t.Name), Status: http.StatusBadRequest}
+	}
+	if err := checkQuotas(t); err != nil {
+		return nil, err
+	}
+	row := s.DB.QueryRow(`insert into tenants (name, max_services, max_versions, max_payload_bytes) values ($1, $2, $3, $4)
+		on conflict (name) do nothing returning created_at`, t.Name, t.MaxServices, t.MaxVersions, t.MaxPayloadBytes)
+	err := row.Scan(&t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "Tenant with this name already exists", Status: http.StatusConflict}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &t, nil
+}
+
+// Update replaces the quotas of the tenant. Lowering a quota below the current
+// usage only prevents further growth.
+func (s *TenantStore) Update(t Tenant) (*Tenant, error) {
+	if err := checkQuotas(t); err != nil {
+		return nil, err
+	}
+	res, err := s.DB.Exec("update tenants set max_services = $2, max_versions = $3, max_payload_bytes = $4 where name = $1",
+		t.Name, t.MaxServices, t.MaxVersions, t.MaxPayloadBytes)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "No tenant with this name", Status: http.StatusNotFound}
+	}
+	return s.Get(t.Name)
+}
+
+// Get returns the tenant with its usage, or nil if there is none.
+func (s *TenantStore) Get(name string) (*Tenant, error) {
+	list, err := s.list("where t.name = $1", name)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Exists reports whether the tenant is registered, without counting its usage like Get.
+func (s *TenantStore) Exists(name string) (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow("select exists (select 1 from tenants where name = $1)", name).Scan(&exists)
+	if err != nil {
+		return false, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return exists, nil
+}
+
+func (s *TenantStore) List() ([]Tenant, error) {
+	return s.list("")
+}
+
+func (s *TenantStore) list(where string, args ...interface{}) ([]Tenant, error) {
+	rows, err := s.DB.Query(`select t.name, t.max_services, t.max_versions, t.max_payload_bytes, t.created_at,
+		count(distinct c.service), count(c.service)
+		from tenants t left join configs c on left(c.service, length(t.name) + 1) = t.name || '/' and c.deleted_at is null
+		`+where+` group by t.name order by t.name`, args...)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	tenants := make([]Tenant, 0)
+	for rows.Next() {
+		var t Tenant
+		err := rows.Scan(&t.Name, &t.MaxServices, &t.MaxVersions, &t.MaxPayloadBytes, &t.CreatedAt, &t.Services, &t.Versions)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// Delete removes a tenant that has neither configs nor API keys left, along
+// with the schemas, flags, canaries, retention policies and version counters
+// of its services.
+func (s *TenantStore) Delete(name string) error {
+	var configs, keys int
+	err := s.DB.QueryRow(`select (select count(*) from configs where left(service, length($1) + 1) = $1 || '/'),
+		(select count(*) from api_keys where tenant = $1)`, name).Scan(&configs, &keys)
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if configs > 0 || keys > 0 {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Tenant %s still has %d config versions and %d API keys", name, configs, keys), Status: http.StatusConflict}
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"schema_versions", "schema_settings", "flags", "canaries", "retention_policies", "config_versions", "schema_counters"} {
+		_, err = tx.Exec("delete from "+table+" where left(service, length($1) + 1) = $1 || '/'", name)
+		if err != nil {
+			return Models.ResponseError{ErrorDescr: err.Error()}
+		}
+	}
+	res, err := tx.Exec("delete from tenants where name = $1", name)
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Models.ResponseError{ErrorDescr: "No tenant with this name", Status: http.StatusNotFound}
+	}
+	if err := tx.Commit(); err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return nil
+}
+
+func checkQuotas(t Tenant) error {
+	if t.MaxServices < 0 || t.MaxVersions < 0 || t.MaxPayloadBytes < 0 {
+		return Models.ResponseError{ErrorDescr: "quotas must not be negative", Status: http.StatusBadRequest}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Envelope is a payload encrypted with its own data key (DEK). The data key is
+// stored wrapped by the key-encryption key (KEK) identified by KeyID.
+type Envelope struct {
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+}
+
+// Keyring holds the key-encryption keys loaded from a key file of the form
+//
+//	{"active": "2022-11", "keys": {"2022-10": "<base64>", "2022-11": "<base64>"}}
+//
+// where every key is 32 random bytes. New payloads are encrypted with the active
+// key, older keys are kept to decrypt existing payloads until they are rotated.
+type Keyring struct {
+	file string
+
+	mu      sync.RWMutex
+	active  string
+	keys    map[string][]byte
+	modTime time.Time
+}
+
+func LoadKeyring(file string) (*Keyring, error) {
+	k := &Keyring{file: file}
+	if _, err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload re-reads the key file if it changed since the last load and reports whether it did.
+func (k *Keyring) Reload() (bool, error) {
+	fi, err := os.Stat(k.file)
+	if err != nil {
+		return false, fmt.Errorf("can't read key file: %v", err)
+	}
+	k.mu.RLock()
+	unchanged := fi.ModTime().Equal(k.modTime)
+	k.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(k.file)
+	if err != nil {
+		return false, fmt.Errorf("can't read key file: %v", err)
+	}
+	var f struct {
+		Active string            `json:"active"`
+		Keys   map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return false, fmt.Errorf("invalid key file: %v", err)
+	}
+	keys := make(map[string][]byte)
+	for id, v := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			return false, fmt.Errorf("key %q must be 32 base64 encoded bytes", id)
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[f.Active]; !ok {
+		return false, fmt.Errorf("active key %q not found in key file", f.Active)
+	}
+
+	k.mu.Lock()
+	k.active, k.keys, k.modTime = f.Active, keys, fi.ModTime()
+	k.mu.Unlock()
+	return true, nil
+}
+
+// ActiveKeyID returns the ID of the key used for new payloads.
+func (k *Keyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Encrypt seals plaintext with a fresh data key wrapped by the active key.
+func (k *Keyring) Encrypt(plaintext []byte) (*Envelope, error) {
+	k.mu.RLock()
+	keyID, kek := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dek, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(kek, dek, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: keyID, DataKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Decrypt unwraps the data key of e and opens its payload.
+func (k *Keyring) Decrypt(e *Envelope) ([]byte, error) {
+	k.mu.RLock()
+	kek, ok := k.keys[e.KeyID]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q is not available", e.KeyID)
+	}
+
+	dek, err := open(kek, e.DataKey, []byte(e.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("can't unwrap data key: %v", err)
+	}
+	return open(dek, e.Ciphertext, nil)
+}
+
+// seal encrypts with AES-256-GCM and prepends the random nonce to the result.
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(key, sealed, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
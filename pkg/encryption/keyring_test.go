@@ -0,0 +1,166 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// writeKeyFile writes a key file and moves its modification time forward so
+// that Reload sees every write as a change.
+func writeKeyFile(t *testing.T, file, active string, keys map[string]string) {
+	b, err := json.Marshal(map[string]interface{}{"active": active, "keys": keys})
+	require.NoError(t, err)
+	var modTime time.Time
+	if fi, err := os.Stat(file); err == nil {
+		modTime = fi.ModTime()
+	}
+	require.NoError(t, os.WriteFile(file, b, 0600))
+	modTime = modTime.Add(time.Second)
+	if now := time.Now(); modTime.Before(now) {
+		modTime = now
+	}
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func TestKeyring(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	// k1 and same hold the same key, only the key ID bound to the wrapped data key tells them apart.
+	writeKeyFile(t, file, "k1", map[string]string{"k1": testKey(1), "same": testKey(1)})
+	k, err := LoadKeyring(file)
+	require.NoError(t, err)
+	require.Equal(t, "k1", k.ActiveKeyID())
+
+	plain := []byte(`{"password":"secret"}`)
+	e, err := k.Encrypt(plain)
+	require.NoError(t, err)
+	require.Equal(t, "k1", e.KeyID)
+	require.False(t, bytes.Contains(e.Ciphertext, []byte("secret")))
+	got, err := k.Decrypt(e)
+	require.NoError(t, err)
+	require.Equal(t, plain, got)
+
+	again, err := k.Encrypt(plain)
+	require.NoError(t, err)
+	require.NotEqual(t, e.DataKey, again.DataKey)
+	require.NotEqual(t, e.Ciphertext, again.Ciphertext)
+
+	tampered := func(b []byte) []byte {
+		c := append([]byte{}, b...)
+		c[len(c)-1] ^= 1
+		return c
+	}
+	tests := []struct {
+		name string
+		e    Envelope
+		err  string
+	}{
+		{"tampered ciphertext", Envelope{KeyID: e.KeyID, DataKey: e.DataKey, Ciphertext: tampered(e.Ciphertext)}, "message authentication failed"},
+		{"tampered data key", Envelope{KeyID: e.KeyID, DataKey: tampered(e.DataKey), Ciphertext: e.Ciphertext}, "can't unwrap data key"},
+		{"data key of another envelope", Envelope{KeyID: e.KeyID, DataKey: again.DataKey, Ciphertext: e.Ciphertext}, "message authentication failed"},
+		{"other key ID with the same key", Envelope{KeyID: "same", DataKey: e.DataKey, Ciphertext: e.Ciphertext}, "can't unwrap data key"},
+		{"unknown key ID", Envelope{KeyID: "k9", DataKey: e.DataKey, Ciphertext: e.Ciphertext}, `encryption key "k9" is not available`},
+		{"short ciphertext", Envelope{KeyID: e.KeyID, DataKey: e.DataKey, Ciphertext: []byte{1, 2}}, "ciphertext too short"},
+		{"short data key", Envelope{KeyID: e.KeyID, DataKey: []byte{1, 2}, Ciphertext: e.Ciphertext}, "ciphertext too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Decrypt(&tt.e)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, file, "k1", map[string]string{"k1": testKey(1)})
+	k, err := LoadKeyring(file)
+	require.NoError(t, err)
+	old, err := k.Encrypt([]byte("old"))
+	require.NoError(t, err)
+
+	changed, err := k.Reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	writeKeyFile(t, file, "k2", map[string]string{"k1": testKey(1), "k2": testKey(2)})
+	changed, err = k.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "k2", k.ActiveKeyID())
+
+	e, err := k.Encrypt([]byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, "k2", e.KeyID)
+	got, err := k.Decrypt(old)
+	require.NoError(t, err)
+	require.Equal(t, []byte("old"), got)
+
+	// A broken key file keeps the loaded keys.
+	writeKeyFile(t, file, "k3", map[string]string{"k2": testKey(2)})
+	_, err = k.Reload()
+	require.Error(t, err)
+	require.Equal(t, "k2", k.ActiveKeyID())
+
+	// Once the old key is retired, what it sealed can't be opened.
+	writeKeyFile(t, file, "k2", map[string]string{"k2": testKey(2)})
+	changed, err = k.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	_, err = k.Decrypt(old)
+	require.EqualError(t, err, `encryption key "k1" is not available`)
+	got, err = k.Decrypt(e)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), got)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"not JSON", `{`, "invalid key file"},
+		{"short key", `{"active":"k1","keys":{"k1":"` + base64.StdEncoding.EncodeToString([]byte("short")) + `"}}`, `key "k1" must be 32 base64 encoded bytes`},
+		{"not base64", `{"active":"k1","keys":{"k1":"!"}}`, `key "k1" must be 32 base64 encoded bytes`},
+		{"missing active key", `{"active":"k2","keys":{"k1":"` + testKey(1) + `"}}`, `active key "k2" not found in key file`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "keys.json")
+			require.NoError(t, os.WriteFile(file, []byte(tt.file), 0600))
+			_, err := LoadKeyring(file)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+	_, err := LoadKeyring(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't read key file")
+}
+
+func TestSeal(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sealed, err := seal(key, []byte("data key"), []byte("k1"))
+	require.NoError(t, err)
+	opened, err := open(key, sealed, []byte("k1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("data key"), opened)
+
+	_, err = open(key, sealed, []byte("k2"))
+	require.Error(t, err)
+	_, err = open(bytes.Repeat([]byte{8}, 32), sealed, []byte("k1"))
+	require.Error(t, err)
+	_, err = seal([]byte("short"), []byte("x"), nil)
+	require.Error(t, err)
+}
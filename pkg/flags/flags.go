@@ -0,0 +1,159 @@
+// Package flags evaluates feature flags. A flag serves the value of the first
+// of its rules matching the evaluation context, or its default value:
+//
+//	{"name": "new-checkout", "enabled": true, "default": false, "rules": [
+//	  {"attributes": {"region": ["eu"]}, "percentage": 10, "value": true}
+//	]}
+//
+// serves true to 10% of the clients in the eu region. The server and the Go
+// client evaluate flags with this package, a client ID falls into the same
+// percentage bucket on both.
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+)
+
+// Reasons for the value of a flag.
+const (
+	ReasonDisabled = "disabled"
+	ReasonRule     = "rule"
+	ReasonDefault  = "default"
+	ReasonNotFound = "not_found"
+)
+
+type Flag struct {
+	Name string `json:"name"`
+	// Enabled false serves Default to everyone.
+	Enabled bool            `json:"enabled"`
+	Default json.RawMessage `json:"default"`
+	Rules   []Rule          `json:"rules,omitempty"`
+}
+
+// Rule serves Value to the clients having one of the listed values of every
+// attribute in Attributes, if any, and falling into the first Percentage
+// percent of the buckets, if set. Percentage rollouts need a client ID.
+type Rule struct {
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	Percentage *float64            `json:"percentage,omitempty"`
+	Value      json.RawMessage     `json:"value"`
+}
+
+// Context describes the client flags are evaluated for.
+type Context struct {
+	ClientID   string            `json:"client_id,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Result is the value of a flag for a context. Rule is the index of the rule
+// that matched, -1 if none did.
+type Result struct {
+	Name   string          `json:"name"`
+	Value  json.RawMessage `json:"value"`
+	Rule   int             `json:"rule"`
+	Reason string          `json:"reason"`
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+// Validate checks the name, the values and the rules of the flag.
+func (f Flag) Validate() error {
+	if !validName.MatchString(f.Name) {
+		return fmt.Errorf("Invalid flag name %q, must be letters, digits, '.', '_' and '-' up to 255 characters", f.Name)
+	}
+	if !validValue(f.Default) {
+		return fmt.Errorf("default of flag %s must be a JSON value", f.Name)
+	}
+	for i, r := range f.Rules {
+		if !validValue(r.Value) {
+			return fmt.Errorf("value of rule %d of flag %s must be a JSON value", i, f.Name)
+		}
+		if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+			return fmt.Errorf("percentage of rule %d of flag %s must be between 0 and 100", i, f.Name)
+		}
+		for name, values := range r.Attributes {
+			if len(values) == 0 {
+				return fmt.Errorf("attribute %s of rule %d of flag %s must list at least one value", name, i, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func validValue(v json.RawMessage) bool {
+	return len(bytes.TrimSpace(v)) > 0 && json.Valid(v)
+}
+
+// Evaluate returns the value of the flag for the context.
+func (f Flag) Evaluate(c Context) Result {
+	if !f.Enabled {
+		return Result{Name: f.Name, Value: f.Default, Rule: -1, Reason: ReasonDisabled}
+	}
+	for i, r := range f.Rules {
+		if r.matches(f.Name, c) {
+			return Result{Name: f.Name, Value: r.Value, Rule: i, Reason: ReasonRule}
+		}
+	}
+	return Result{Name: f.Name, Value: f.Default, Rule: -1, Reason: ReasonDefault}
+}
+
+func (r Rule) matches(flag string, c Context) bool {
+	for name, values := range r.Attributes {
+		v, ok := c.Attributes[name]
+		if !ok || !contains(values, v) {
+			return false
+		}
+	}
+	if r.Percentage == nil {
+		return true
+	}
+	return len(c.ClientID) > 0 && Bucket(flag, c.ClientID) < *r.Percentage
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Bucket places the client at a point in [0, 100) for the flag, always the
+// same one. The flag name is hashed along so that the rollouts of different
+// flags reach different clients.
+func Bucket(flag, clientID string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(flag))
+	h.Write([]byte{0})
+	h.Write([]byte(clientID))
+	return float64(h.Sum64()%10000) / 100
+}
+
+// EvaluateAll evaluates the named flags, all of them if names is empty. A
+// name not among the flags results in a null value.
+func EvaluateAll(list []Flag, c Context, names []string) []Result {
+	results := make([]Result, 0, len(list))
+	if len(names) == 0 {
+		for _, f := range list {
+			results = append(results, f.Evaluate(c))
+		}
+		return results
+	}
+	byName := make(map[string]Flag, len(list))
+	for _, f := range list {
+		byName[f.Name] = f
+	}
+	for _, name := range names {
+		if f, ok := byName[name]; ok {
+			results = append(results, f.Evaluate(c))
+		} else {
+			results = append(results, Result{Name: name, Value: json.RawMessage("null"), Rule: -1, Reason: ReasonNotFound})
+		}
+	}
+	return results
+}
@@ -0,0 +1,150 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func percentage(p float64) *float64 {
+	return &p
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		flag Flag
+		err  string
+	}{
+		{"valid", Flag{Name: "new-checkout", Default: json.RawMessage(`false`),
+			Rules: []Rule{{Attributes: map[string][]string{"region": {"eu"}}, Percentage: percentage(10), Value: json.RawMessage(`true`)}}}, ""},
+		{"empty name", Flag{Default: json.RawMessage(`1`)}, "Invalid flag name"},
+		{"name with spaces", Flag{Name: "a b", Default: json.RawMessage(`1`)}, "Invalid flag name"},
+		{"name starting with a dot", Flag{Name: ".a", Default: json.RawMessage(`1`)}, "Invalid flag name"},
+		{"missing default", Flag{Name: "a"}, "default of flag a must be a JSON value"},
+		{"invalid default", Flag{Name: "a", Default: json.RawMessage(`{`)}, "default of flag a must be a JSON value"},
+		{"missing rule value", Flag{Name: "a", Default: json.RawMessage(`1`), Rules: []Rule{{}}}, "value of rule 0 of flag a"},
+		{"negative percentage", Flag{Name: "a", Default: json.RawMessage(`1`),
+			Rules: []Rule{{Percentage: percentage(-1), Value: json.RawMessage(`2`)}}}, "percentage of rule 0 of flag a"},
+		{"percentage over 100", Flag{Name: "a", Default: json.RawMessage(`1`),
+			Rules: []Rule{{Value: json.RawMessage(`2`)}, {Percentage: percentage(100.5), Value: json.RawMessage(`2`)}}}, "percentage of rule 1 of flag a"},
+		{"attribute without values", Flag{Name: "a", Default: json.RawMessage(`1`),
+			Rules: []Rule{{Attributes: map[string][]string{"region": {}}, Value: json.RawMessage(`2`)}}}, "attribute region of rule 0 of flag a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.flag.Validate()
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	flag := Flag{
+		Name:    "checkout",
+		Enabled: true,
+		Default: json.RawMessage(`"default"`),
+		Rules: []Rule{
+			{Attributes: map[string][]string{"region": {"eu", "us"}, "plan": {"pro"}}, Value: json.RawMessage(`"pro"`)},
+			{Attributes: map[string][]string{"region": {"eu"}}, Value: json.RawMessage(`"eu"`)},
+			{Percentage: percentage(0), Value: json.RawMessage(`"nobody"`)},
+			{Attributes: map[string][]string{"beta": {"yes"}}, Percentage: percentage(100), Value: json.RawMessage(`"beta"`)},
+		},
+	}
+	tests := []struct {
+		name   string
+		flag   Flag
+		ctx    Context
+		value  string
+		rule   int
+		reason string
+	}{
+		{"no attributes", flag, Context{ClientID: "c1"}, `"default"`, -1, ReasonDefault},
+		{"all attributes of the first rule", flag,
+			Context{Attributes: map[string]string{"region": "us", "plan": "pro"}}, `"pro"`, 0, ReasonRule},
+		{"first matching rule wins", flag,
+			Context{Attributes: map[string]string{"region": "eu", "plan": "pro"}}, `"pro"`, 0, ReasonRule},
+		{"some attributes of a rule", flag,
+			Context{Attributes: map[string]string{"region": "us", "plan": "free"}}, `"default"`, -1, ReasonDefault},
+		{"second rule", flag,
+			Context{Attributes: map[string]string{"region": "eu", "plan": "free"}}, `"eu"`, 1, ReasonRule},
+		{"values are case sensitive", flag,
+			Context{Attributes: map[string]string{"region": "EU"}}, `"default"`, -1, ReasonDefault},
+		{"percentage rollout needs a client ID", flag,
+			Context{Attributes: map[string]string{"beta": "yes"}}, `"default"`, -1, ReasonDefault},
+		{"full rollout", flag,
+			Context{ClientID: "c1", Attributes: map[string]string{"beta": "yes"}}, `"beta"`, 3, ReasonRule},
+		{"disabled", Flag{Name: flag.Name, Default: flag.Default, Rules: flag.Rules},
+			Context{Attributes: map[string]string{"region": "eu"}}, `"default"`, -1, ReasonDisabled},
+		{"rule without conditions", Flag{Name: "a", Enabled: true, Default: json.RawMessage(`1`), Rules: []Rule{{Value: json.RawMessage(`2`)}}},
+			Context{}, `2`, 0, ReasonRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.flag.Evaluate(tt.ctx)
+			require.Equal(t, tt.flag.Name, r.Name)
+			require.Equal(t, tt.value, string(r.Value))
+			require.Equal(t, tt.rule, r.Rule)
+			require.Equal(t, tt.reason, r.Reason)
+		})
+	}
+}
+
+func TestBucket(t *testing.T) {
+	require.Equal(t, Bucket("checkout", "client-1"), Bucket("checkout", "client-1"))
+	require.NotEqual(t, Bucket("checkout", "client-1"), Bucket("search", "client-1"))
+
+	flag := Flag{
+		Name:    "checkout",
+		Enabled: true,
+		Default: json.RawMessage(`false`),
+		Rules:   []Rule{{Percentage: percentage(10), Value: json.RawMessage(`true`)}},
+	}
+	served := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("client-%d", i)
+		b := Bucket(flag.Name, id)
+		require.True(t, b >= 0 && b < 100)
+		r := flag.Evaluate(Context{ClientID: id})
+		require.Equal(t, b < 10, r.Reason == ReasonRule)
+		require.Equal(t, r, flag.Evaluate(Context{ClientID: id}))
+		if r.Reason == ReasonRule {
+			served++
+		}
+	}
+	require.InDelta(t, 1000, served, 150)
+}
+
+func TestEvaluateAll(t *testing.T) {
+	list := []Flag{
+		{Name: "a", Enabled: true, Default: json.RawMessage(`1`)},
+		{Name: "b", Default: json.RawMessage(`2`)},
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []Result
+	}{
+		{"all flags", nil, []Result{
+			{Name: "a", Value: json.RawMessage(`1`), Rule: -1, Reason: ReasonDefault},
+			{Name: "b", Value: json.RawMessage(`2`), Rule: -1, Reason: ReasonDisabled},
+		}},
+		{"named flags in the asked order", []string{"b", "missing", "a"}, []Result{
+			{Name: "b", Value: json.RawMessage(`2`), Rule: -1, Reason: ReasonDisabled},
+			{Name: "missing", Value: json.RawMessage(`null`), Rule: -1, Reason: ReasonNotFound},
+			{Name: "a", Value: json.RawMessage(`1`), Rule: -1, Reason: ReasonDefault},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, EvaluateAll(list, Context{}, tt.names))
+		})
+	}
+	require.Empty(t, EvaluateAll(nil, Context{}, nil))
+}
@@ -0,0 +1,129 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"regexp"
+	"strings"
+)
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// decodeDotenv reads KEY=value lines as written for docker or dotenv
+// libraries: "export" prefixes, # comments, single quoted literal values and
+// double quoted values with escapes, which may span lines. Keys stay flat and
+// all values are strings.
+func decodeDotenv(b []byte) (Models.Object, error) {
+	obj := Models.Object{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		start := lineNo
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: KEY=value expected", start)
+		}
+		key := strings.TrimSpace(line[:i])
+		if !envKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", start, key)
+		}
+		if _, dup := obj.Get(key); dup {
+			return nil, fmt.Errorf("line %d: duplicate key %s", start, key)
+		}
+
+		raw := strings.TrimSpace(line[i+1:])
+		var value string
+		switch {
+		case strings.HasPrefix(raw, "'"):
+			end := strings.IndexByte(raw[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", start)
+			}
+			value = raw[1 : end+1]
+		case strings.HasPrefix(raw, `"`):
+			// Collect lines until the closing quote.
+			for closingQuote(raw) < 0 && sc.Scan() {
+				lineNo++
+				raw += "\n" + sc.Text()
+			}
+			end := closingQuote(raw)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", start)
+			}
+			value = unescapeDotenv(raw[1:end])
+		default:
+			if j := strings.Index(raw, " #"); j >= 0 {
+				raw = raw[:j]
+			}
+			value = strings.TrimSpace(raw)
+		}
+		obj = append(obj, Models.Member{Key: key, Value: value})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// closingQuote returns the index of the unescaped double quote closing s, which starts with one.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+	return r.Replace(s)
+}
+
+// encodeDotenv writes every value under the upper-cased keys leading to it
+// joined by underscores, with array indexes as keys: DB_HOSTS_0_NAME=x.
+// Characters not allowed in variable names are replaced with underscores too,
+// keys that collide after that are reported as errors.
+func encodeDotenv(obj Models.Object) ([]byte, error) {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, v := range flatten(obj) {
+		parts := make([]string, len(v.keys))
+		for i, k := range v.keys {
+			parts[i] = fmt.Sprint(k)
+		}
+		key := strings.ToUpper(envInvalid.ReplaceAllString(strings.Join(parts, "_"), "_"))
+		if len(key) == 0 || key[0] >= '0' && key[0] <= '9' {
+			key = "_" + key
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("more than one value for variable %s", key)
+		}
+		seen[key] = true
+		fmt.Fprintf(&b, "%s=%s\n", key, quoteDotenv(v.value))
+	}
+	return []byte(b.String()), nil
+}
+
+var envInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// quoteDotenv double quotes values that would not be read back as is.
+func quoteDotenv(s string) string {
+	if !strings.ContainsAny(s, " \t\r\n\"'\\#$=`") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
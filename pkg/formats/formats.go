@@ -0,0 +1,141 @@
+// Package formats converts config files in other formats to the config data
+// document and back.
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"mime"
+	"strings"
+)
+
+// Formats, identified by the name used in error messages.
+const (
+	JSON       = "json"
+	YAML       = "yaml"
+	TOML       = "toml"
+	Properties = "properties"
+	Dotenv     = "dotenv"
+	ConfigMap  = "configmap" // Kubernetes ConfigMap manifest, export only
+	Secret     = "secret"    // Kubernetes Secret manifest, export only
+)
+
+// ContentTypes maps formats to the Content-Type of their encoded output.
+var ContentTypes = map[string]string{
+	JSON:       "application/json",
+	YAML:       "application/yaml",
+	TOML:       "application/toml",
+	Properties: "text/x-properties",
+	Dotenv:     "text/x-dotenv",
+	ConfigMap:  "application/yaml",
+	Secret:     "application/yaml",
+}
+
+var mediaTypes = map[string]string{
+	"application/json":         JSON,
+	"application/yaml":         YAML,
+	"application/x-yaml":       YAML,
+	"text/yaml":                YAML,
+	"application/toml":         TOML,
+	"text/x-properties":        Properties,
+	"text/x-java-properties":   Properties,
+	"application/x-properties": Properties,
+	"text/x-dotenv":            Dotenv,
+	"application/x-dotenv":     Dotenv,
+}
+
+// FromContentType returns the format of a Content-Type header value, or an
+// empty string if the media type is unknown.
+func FromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaTypes[strings.ToLower(mediaType)]
+}
+
+// FromAccept returns the format of the first media type of an Accept header
+// value that has one, or an empty string.
+func FromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		if format := FromContentType(strings.TrimSpace(part)); len(format) > 0 {
+			return format
+		}
+	}
+	return ""
+}
+
+// Decode converts a config file to the config data. Values that have no JSON
+// representation are reported as errors.
+func Decode(format string, b []byte) (Models.Object, error) {
+	var obj Models.Object
+	var err error
+	switch format {
+	case YAML:
+		obj, err = decodeYAML(b)
+	case TOML:
+		obj, err = decodeTOML(b)
+	case Properties:
+		obj, err = decodeProperties(b)
+	case Dotenv:
+		obj, err = decodeDotenv(b)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", format, err)
+	}
+	return obj, nil
+}
+
+// Encode renders config data of the service in the format.
+func Encode(format string, obj Models.Object, service string) ([]byte, error) {
+	switch format {
+	case JSON:
+		return json.Marshal(obj)
+	case YAML:
+		return encodeYAML(obj)
+	case TOML:
+		return encodeTOML(obj)
+	case Properties:
+		return encodeProperties(obj)
+	case Dotenv:
+		return encodeDotenv(obj)
+	case ConfigMap, Secret:
+		return encodeManifest(format, obj, service)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// flatValue is a scalar of the config data at the keys leading to it, array
+// indexes included as ints.
+type flatValue struct {
+	keys  []interface{}
+	value string
+}
+
+// flatten lists scalars of the object in document order, printed as strings.
+// Null values become empty strings, empty objects and arrays are dropped.
+func flatten(obj Models.Object) []flatValue {
+	var values []flatValue
+	var walk func(v interface{}, keys []interface{})
+	walk = func(v interface{}, keys []interface{}) {
+		switch t := v.(type) {
+		case Models.Object:
+			for _, m := range t {
+				walk(m.Value, append(keys[:len(keys):len(keys)], m.Key))
+			}
+		case []interface{}:
+			for i, e := range t {
+				walk(e, append(keys[:len(keys):len(keys)], i))
+			}
+		case nil:
+			values = append(values, flatValue{keys, ""})
+		default:
+			values = append(values, flatValue{keys, fmt.Sprint(t)})
+		}
+	}
+	walk(obj, nil)
+	return values
+}
@@ -0,0 +1,154 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"strings"
+	"testing"
+)
+
+// aliasBomb nests levels of anchors, each aliasing the previous one ten times.
+func aliasBomb(levels int) string {
+	var b strings.Builder
+	b.WriteString("a0: &a0 [x]\n")
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [", i, i)
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "*a%d", i-1)
+		}
+		b.WriteString("]\n")
+	}
+	return b.String()
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		want   string
+		err    string
+	}{
+		{"yaml keeps order and types", YAML, "b: 1\na: [true, 1.5, null, x]\n", `{"b":1,"a":[true,1.5,null,"x"]}`, ""},
+		{"yaml quoted numbers stay strings", YAML, "port: \"5432\"\n", `{"port":"5432"}`, ""},
+		{"yaml empty document", YAML, "", `{}`, ""},
+		{"yaml alias", YAML, "base: &b {host: x}\ncopy: *b\n", `{"base":{"host":"x"},"copy":{"host":"x"}}`, ""},
+		{"yaml self-referencing alias", YAML, "a: &x [*x]\n", "", "refers to itself"},
+		{"yaml nested self-reference", YAML, "a: &x {b: [1, {c: *x}]}\n", "", "refers to itself"},
+		{"yaml alias bomb", YAML, aliasBomb(7), "", "aliases expand to more than"},
+		{"yaml small alias expansion", YAML, aliasBomb(2), "", ""},
+		{"yaml duplicate key", YAML, "a: 1\na: 2\n", "", "duplicate key"},
+		{"yaml merge key", YAML, "base: &b {x: 1}\nc:\n  <<: *b\n", "", "merge keys"},
+		{"yaml NaN", YAML, "a: .nan\n", "", "no JSON representation"},
+		{"yaml infinity", YAML, "a: -.inf\n", "", "no JSON representation"},
+		{"yaml multiple documents", YAML, "a: 1\n---\nb: 2\n", "", "multiple documents"},
+		{"yaml scalar top level", YAML, "1\n", "", "top level must be a mapping"},
+		{"toml", TOML, "name = \"x\"\nport = 5432\n[db]\nhost = \"h\"\n", `{"name":"x","port":5432,"db":{"host":"h"}}`, ""},
+		{"toml NaN", TOML, "a = nan\n", "", "no JSON representation"},
+		{"toml infinity", TOML, "a = inf\n", "", "no JSON representation"},
+		{"toml duplicate key", TOML, "a = 1\na = 2\n", "", "invalid toml"},
+		{"properties dotted keys", Properties, "db.host=x\ndb.port = 5432\nname:svc\n", `{"db":{"host":"x","port":"5432"},"name":"svc"}`, ""},
+		{"properties escapes and continuation", Properties, "a=one \\\n    two\nb=\\u00e9\\=\n", `{"a":"one two","b":"é="}`, ""},
+		{"properties duplicate key", Properties, "a=1\na=2\n", "", "duplicate key a"},
+		{"properties value and group", Properties, "a=1\na.b=2\n", "", "both a value and a group"},
+		{"properties group and value", Properties, "a.b=1\na=2\n", "", "both a value and a group"},
+		{"properties empty key part", Properties, "a..b=1\n", "", "empty key"},
+		{"dotenv", Dotenv, "export A=1\nB='x y' \nC=\"l1\\nl2\"\nD=v # comment\n", `{"A":"1","B":"x y","C":"l1\nl2","D":"v"}`, ""},
+		{"dotenv multiline", Dotenv, "A=\"l1\nl2\"\n", `{"A":"l1\nl2"}`, ""},
+		{"dotenv duplicate key", Dotenv, "A=1\nA=2\n", "", "duplicate key A"},
+		{"dotenv invalid key", Dotenv, "1A=1\n", "", "invalid key"},
+		{"dotenv unterminated", Dotenv, "A=\"x\n", "", "unterminated"},
+		{"unsupported format", "xml", "<a/>", "", "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := Decode(tt.format, []byte(tt.in))
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if len(tt.want) > 0 {
+				got, err := json.Marshal(obj)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	doc := `{"name":"svc","db":{"host":"h","port":5432,"tls":true},"hosts":[{"name":"a"},{"name":"b"}],"note":"a b"}`
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		want   string
+		err    string
+	}{
+		{"json keeps order", JSON, `{"b":1,"a":[null,"x"]}`, `{"b":1,"a":[null,"x"]}`, ""},
+		{"yaml", YAML, `{"b":"true","a":[1,1.5,null],"c":{"d":"x\ny"}}`,
+			"b: \"true\"\na:\n  - 1\n  - 1.5\n  - null\nc:\n  d: |-\n    x\n    y\n", ""},
+		{"toml", TOML, doc,
+			"name = \"svc\"\nnote = \"a b\"\n\n[db]\nhost = \"h\"\nport = 5432\ntls = true\n\n[[hosts]]\nname = \"a\"\n\n[[hosts]]\nname = \"b\"\n", ""},
+		{"toml null", TOML, `{"a":{"b":null}}`, "", "null value of a.b"},
+		{"properties", Properties, doc,
+			"name=svc\ndb.host=h\ndb.port=5432\ndb.tls=true\nhosts[0].name=a\nhosts[1].name=b\nnote=a b\n", ""},
+		{"properties escapes", Properties, `{"a b":"=é","c":null}`, "a\\ b=\\=\\u00e9\nc=\n", ""},
+		{"dotenv", Dotenv, doc,
+			"NAME=svc\nDB_HOST=h\nDB_PORT=5432\nDB_TLS=true\nHOSTS_0_NAME=a\nHOSTS_1_NAME=b\nNOTE=\"a b\"\n", ""},
+		{"dotenv collision", Dotenv, `{"db":{"host":"a"},"db.host":"b"}`, "", "more than one value for variable DB_HOST"},
+		{"dotenv case collision", Dotenv, `{"a":"1","A":"2"}`, "", "more than one value for variable A"},
+		{"dotenv leading digit", Dotenv, `{"1a":"x"}`, "_1A=x\n", ""},
+		{"configmap", ConfigMap, `{"db":{"host":"h"},"hosts":["a"]}`,
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: svc\ndata:\n  db.host: h\n  hosts.0: a\n", ""},
+		{"secret", Secret, `{"password":"x"}`,
+			"apiVersion: v1\nkind: Secret\nmetadata:\n  name: svc\ntype: Opaque\ndata:\n  password: eA==\n", ""},
+		{"configmap collision", ConfigMap, `{"a":{"b":"1"},"a.b":"2"}`, "", "more than one value for key a.b"},
+		{"configmap invalid key", ConfigMap, `{"a b":"1"}`, "", "is not valid in a configmap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := decodeJSONObject(tt.in)
+			require.NoError(t, err)
+			out, err := Encode(tt.format, obj, "svc")
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(out))
+		})
+	}
+}
+
+// TestRoundTrip decodes what Encode wrote back to the same data.
+func TestRoundTrip(t *testing.T) {
+	in := `{"name":"svc","db":{"host":"h","port":5432,"ratio":0.25,"tls":true},"hosts":[{"name":"a"},{"name":"b"}],"tags":["x","y"]}`
+	for _, format := range []string{YAML, TOML} {
+		t.Run(format, func(t *testing.T) {
+			obj, err := decodeJSONObject(in)
+			require.NoError(t, err)
+			out, err := Encode(format, obj, "svc")
+			require.NoError(t, err)
+			back, err := Decode(format, out)
+			require.NoError(t, err)
+			got, err := json.Marshal(back)
+			require.NoError(t, err)
+			require.JSONEq(t, in, string(got))
+		})
+	}
+}
+
+func decodeJSONObject(s string) (Models.Object, error) {
+	var obj Models.Object
+	err := json.Unmarshal([]byte(s), &obj)
+	return obj, err
+}
@@ -0,0 +1,49 @@
+package formats
+
+import (
+	"encoding/base64"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"regexp"
+	"strings"
+)
+
+var manifestKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// encodeManifest writes a Kubernetes ConfigMap or Secret named after the
+// service. Its data has a key per value, the keys leading to the value joined
+// by dots with array indexes as keys: db.hosts.0.name. Secret values are
+// base64 encoded.
+func encodeManifest(kind string, obj Models.Object, service string) ([]byte, error) {
+	data := Models.Object{}
+	for _, v := range flatten(obj) {
+		parts := make([]string, len(v.keys))
+		for i, k := range v.keys {
+			parts[i] = fmt.Sprint(k)
+		}
+		key := strings.Join(parts, ".")
+		if !manifestKey.MatchString(key) || len(key) > 253 {
+			return nil, fmt.Errorf("key %q is not valid in a %s", key, kind)
+		}
+		if _, dup := data.Get(key); dup {
+			return nil, fmt.Errorf("more than one value for key %s", key)
+		}
+		value := v.value
+		if kind == Secret {
+			value = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		data = append(data, Models.Member{Key: key, Value: value})
+	}
+
+	manifest := Models.Object{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "ConfigMap"},
+		{Key: "metadata", Value: Models.Object{{Key: "name", Value: service}}},
+	}
+	if kind == Secret {
+		manifest[1].Value = "Secret"
+		manifest = append(manifest, Models.Member{Key: "type", Value: "Opaque"})
+	}
+	manifest = append(manifest, Models.Member{Key: "data", Value: data})
+	return encodeYAML(manifest)
+}
@@ -0,0 +1,198 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// decodeProperties reads a Java properties file. Dotted keys become nested
+// objects, so db.host=x and db.port=5432 give {"db": {"host": "x", "port": "5432"}}.
+// All values are strings.
+func decodeProperties(b []byte) (Models.Object, error) {
+	obj := Models.Object{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		start := lineNo
+		line := strings.TrimLeft(sc.Text(), " \t\f")
+		if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// A line ending with an odd number of backslashes continues on the next one.
+		for strings.HasSuffix(line, "\\") && (len(line)-len(strings.TrimRight(line, "\\")))%2 == 1 {
+			line = line[:len(line)-1]
+			if !sc.Scan() {
+				break
+			}
+			lineNo++
+			line += strings.TrimLeft(sc.Text(), " \t\f")
+		}
+
+		key, value := splitProperty(line)
+		key, err := unescapeProperty(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		value, err = unescapeProperty(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		obj, err = setDotted(obj, strings.Split(key, "."), value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// splitProperty splits a logical line at the first unescaped =, : or whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if len(rest) > 0 && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return line[:i], rest
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("invalid escape \\u%s", s[i+1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape \\u%s", s[i+1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// setDotted sets the value at the nested keys, creating objects as needed.
+func setDotted(obj Models.Object, keys []string, value interface{}) (Models.Object, error) {
+	for _, k := range keys {
+		if len(k) == 0 {
+			return nil, fmt.Errorf("empty key in %q", strings.Join(keys, "."))
+		}
+	}
+	return setKeys(obj, keys, value, "")
+}
+
+func setKeys(obj Models.Object, keys []string, value interface{}, path string) (Models.Object, error) {
+	path += "." + keys[0]
+	current, exists := obj.Get(keys[0])
+	if len(keys) == 1 {
+		if exists {
+			if _, ok := current.(Models.Object); ok {
+				return nil, fmt.Errorf("key %s is both a value and a group of keys", path[1:])
+			}
+			return nil, fmt.Errorf("duplicate key %s", path[1:])
+		}
+		return obj.Set(keys[0], value), nil
+	}
+	child, ok := current.(Models.Object)
+	if exists && !ok {
+		return nil, fmt.Errorf("key %s is both a value and a group of keys", path[1:])
+	}
+	child, err := setKeys(child, keys[1:], value, path)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Set(keys[0], child), nil
+}
+
+// encodeProperties writes every value on its own line under the dotted key,
+// with array indexes in brackets: db.hosts[0].name=x.
+func encodeProperties(obj Models.Object) ([]byte, error) {
+	var b strings.Builder
+	for _, v := range flatten(obj) {
+		var key strings.Builder
+		for _, k := range v.keys {
+			switch t := k.(type) {
+			case int:
+				fmt.Fprintf(&key, "[%d]", t)
+			case string:
+				if key.Len() > 0 {
+					key.WriteByte('.')
+				}
+				key.WriteString(escapeProperty(t, true))
+			}
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key.String(), escapeProperty(v.value, false))
+	}
+	return []byte(b.String()), nil
+}
+
+// escapeProperty escapes s for a properties file in ISO 8859-1, as read by
+// java.util.Properties.
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
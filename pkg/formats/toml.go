@@ -0,0 +1,233 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func decodeTOML(b []byte) (Models.Object, error) {
+	var m map[string]interface{}
+	md, err := toml.Decode(string(b), &m)
+	if err != nil {
+		return nil, err
+	}
+	// The decoded maps lose the order of keys, restore it from the metadata.
+	order := make(map[string]int)
+	for i, k := range md.Keys() {
+		if _, ok := order[k.String()]; !ok {
+			order[k.String()] = i
+		}
+	}
+	v, err := tomlValue(m, "", order)
+	if err != nil {
+		return nil, err
+	}
+	return v.(Models.Object), nil
+}
+
+func tomlValue(v interface{}, path string, order map[string]int) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			oi, iok := order[joinKey(path, keys[i])]
+			oj, jok := order[joinKey(path, keys[j])]
+			if iok != jok {
+				return iok
+			}
+			if oi != oj {
+				return oi < oj
+			}
+			return keys[i] < keys[j]
+		})
+		obj := make(Models.Object, 0, len(keys))
+		for _, k := range keys {
+			e, err := tomlValue(t[k], joinKey(path, k), order)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, Models.Member{Key: k, Value: e})
+		}
+		return obj, nil
+	case []map[string]interface{}:
+		arr := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			value, err := tomlValue(e, path, order)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+	case []interface{}:
+		arr := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			value, err := tomlValue(e, path, order)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+	case int64:
+		return json.Number(strconv.FormatInt(t, 10)), nil
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return nil, fmt.Errorf("value of %s has no JSON representation", strings.TrimPrefix(path, "."))
+		}
+		return json.Number(strconv.FormatFloat(t, 'g', -1, 64)), nil
+	case time.Time:
+		// Local dates and times are decoded with these zone names.
+		switch t.Location().String() {
+		case "datetime-local":
+			return t.Format("2006-01-02T15:04:05.999999999"), nil
+		case "date-local":
+			return t.Format("2006-01-02"), nil
+		case "time-local":
+			return t.Format("15:04:05.999999999"), nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	case string, bool:
+		return t, nil
+	}
+	return nil, fmt.Errorf("value of %s of type %T is not supported", strings.TrimPrefix(path, "."), v)
+}
+
+// joinKey builds the key path as printed by toml.Key.String.
+func joinKey(path, key string) string {
+	k := toml.Key{key}.String()
+	if len(path) == 0 {
+		return k
+	}
+	return path + "." + k
+}
+
+// encodeTOML writes the object as TOML. Keys of a table come before its
+// subtables, otherwise the order of keys is kept. TOML has no null, so null
+// values are reported as errors.
+func encodeTOML(obj Models.Object) ([]byte, error) {
+	var b strings.Builder
+	if err := writeTOMLTable(&b, obj, ""); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+func writeTOMLTable(b *strings.Builder, obj Models.Object, path string) error {
+	var tables, arrays []Models.Member
+	for _, m := range obj {
+		switch t := m.Value.(type) {
+		case Models.Object:
+			tables = append(tables, m)
+			continue
+		case []interface{}:
+			if isTableArray(t) {
+				arrays = append(arrays, m)
+				continue
+			}
+		}
+		value, err := tomlInline(m.Value, joinKey(path, m.Key))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "%s = %s\n", tomlKey(m.Key), value)
+	}
+	for _, m := range tables {
+		key := joinTOMLKey(path, m.Key)
+		fmt.Fprintf(b, "\n[%s]\n", key)
+		if err := writeTOMLTable(b, m.Value.(Models.Object), key); err != nil {
+			return err
+		}
+	}
+	for _, m := range arrays {
+		key := joinTOMLKey(path, m.Key)
+		for _, e := range m.Value.([]interface{}) {
+			fmt.Fprintf(b, "\n[[%s]]\n", key)
+			if err := writeTOMLTable(b, e.(Models.Object), key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTableArray reports whether the array is written as [[array]] tables.
+func isTableArray(arr []interface{}) bool {
+	for _, e := range arr {
+		if _, ok := e.(Models.Object); !ok {
+			return false
+		}
+	}
+	return len(arr) > 0
+}
+
+func tomlInline(v interface{}, path string) (string, error) {
+	switch t := v.(type) {
+	case Models.Object:
+		parts := make([]string, 0, len(t))
+		for _, m := range t {
+			value, err := tomlInline(m.Value, joinKey(path, m.Key))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKey(m.Key)+" = "+value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for i, e := range t {
+			value, err := tomlInline(e, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, value)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case json.Number:
+		return string(t), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case string:
+		return tomlString(t), nil
+	}
+	return "", fmt.Errorf("null value of %s has no TOML representation", path)
+}
+
+var tomlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\b", `\b`, "\t", `\t`, "\n", `\n`, "\f", `\f`, "\r", `\r`)
+
+func tomlString(s string) string {
+	s = tomlEscaper.Replace(s)
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			fmt.Fprintf(&b, `\u%04X`, r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return `"` + b.String() + `"`
+}
+
+func tomlKey(key string) string {
+	if len(key) > 0 && strings.Trim(key, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-") == "" {
+		return key
+	}
+	return tomlString(key)
+}
+
+func joinTOMLKey(path, key string) string {
+	if len(path) == 0 {
+		return tomlKey(key)
+	}
+	return path + "." + tomlKey(key)
+}
@@ -0,0 +1,190 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"gopkg.in/yaml.v3"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func decodeYAML(b []byte) (Models.Object, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return Models.Object{}, nil
+		}
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, errors.New("multiple documents are not supported")
+	}
+	d := yamlDecoder{anchors: make(map[*yaml.Node]bool)}
+	v, err := d.value(&doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return Models.Object{}, nil
+	}
+	obj, ok := v.(Models.Object)
+	if !ok {
+		return nil, errors.New("top level must be a mapping")
+	}
+	return obj, nil
+}
+
+// maxAliasNodes limits the nodes expanded from aliases, so that a small
+// document of nested aliases can't expand to an enormous config.
+const maxAliasNodes = 10000
+
+// yamlDecoder expands aliases into copies of their anchored values. anchors
+// holds the anchors being expanded, an alias to one of them is a cycle.
+type yamlDecoder struct {
+	anchors map[*yaml.Node]bool
+	// expanded counts the nodes decoded inside aliases.
+	expanded int
+}
+
+func (d *yamlDecoder) value(n *yaml.Node, path string) (interface{}, error) {
+	fail := func(format string, a ...interface{}) error {
+		return fmt.Errorf("line %d: %s", n.Line, fmt.Sprintf(format, a...))
+	}
+	if len(d.anchors) > 0 {
+		if d.expanded++; d.expanded > maxAliasNodes {
+			return nil, fail("aliases expand to more than %d values", maxAliasNodes)
+		}
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return d.value(n.Content[0], path)
+	case yaml.AliasNode:
+		if d.anchors[n.Alias] {
+			return nil, fail("alias *%s refers to itself", n.Value)
+		}
+		d.anchors[n.Alias] = true
+		defer delete(d.anchors, n.Alias)
+		return d.value(n.Alias, path)
+	case yaml.MappingNode:
+		obj := make(Models.Object, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Tag == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys (<<) are not supported", k.Line)
+			}
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be scalars", k.Line)
+			}
+			if _, dup := obj.Get(k.Value); dup {
+				return nil, fmt.Errorf("line %d: duplicate key %q in data%s", k.Line, k.Value, path)
+			}
+			value, err := d.value(v, path+"."+k.Value)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, Models.Member{Key: k.Value, Value: value})
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		arr := make([]interface{}, 0, len(n.Content))
+		for i, e := range n.Content {
+			value, err := d.value(e, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		return arr, nil
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool":
+			var v bool
+			if err := n.Decode(&v); err != nil {
+				return nil, fail("%v", err)
+			}
+			return v, nil
+		case "!!int":
+			var v int64
+			if err := n.Decode(&v); err != nil {
+				var u uint64
+				if err := n.Decode(&u); err != nil {
+					return nil, fail("%v", err)
+				}
+				return json.Number(strconv.FormatUint(u, 10)), nil
+			}
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		case "!!float":
+			var v float64
+			if err := n.Decode(&v); err != nil {
+				return nil, fail("%v", err)
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fail("%s has no JSON representation", n.Value)
+			}
+			return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+		case "!!str", "!!timestamp":
+			return n.Value, nil
+		}
+		return nil, fail("values tagged %s are not supported", n.Tag)
+	}
+	return nil, fail("unexpected node")
+}
+
+func encodeYAML(obj Models.Object) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(obj)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// yamlNode builds the node tree of a value, keeping the order of keys. Strings
+// are tagged, so that values like "true" or "1.0" are quoted.
+func yamlNode(v interface{}) *yaml.Node {
+	switch t := v.(type) {
+	case Models.Object:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, m := range t {
+			n.Content = append(n.Content, yamlNode(m.Key), yamlNode(m.Value))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, e := range t {
+			n.Content = append(n.Content, yamlNode(e))
+		}
+		return n
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(t), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(t)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(t)}
+	case string:
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}
+		if strings.Contains(t, "\n") {
+			n.Style = yaml.LiteralStyle
+		}
+		return n
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
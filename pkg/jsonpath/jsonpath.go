@@ -0,0 +1,450 @@
+// Package jsonpath evaluates a subset of JSONPath against config data:
+//
+//	key4.A                   dotted keys
+//	$.key5[1].E              array indexes, the leading $ is optional
+//	key5[*].E, key4.*        wildcards
+//	['key.with.dots']        quoted keys
+//	key5[?(@.E > 10)]        filters comparing with ==, !=, <, <=, >, >=,
+//	key5[?(@.F)]             testing for existence, combined with && and ||
+//
+// Values are expected as decoded by models.ParseValue.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"strconv"
+	"strings"
+)
+
+type Path struct {
+	steps []step
+}
+
+type step struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+	filter   *filter
+}
+
+// filter is a disjunction of conjunctions of comparisons.
+type filter struct {
+	or [][]comparison
+}
+
+type comparison struct {
+	left, right operand
+	op          string
+}
+
+type operand struct {
+	rel     []step // relative to @ when literal is not set
+	literal interface{}
+	isLit   bool
+}
+
+// Parse compiles a path expression.
+func Parse(expr string) (*Path, error) {
+	p := &parser{s: strings.TrimSpace(expr)}
+	steps, err := p.steps(true)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return &Path{steps: steps}, nil
+}
+
+// Definite reports whether the path addresses a single value, i.e. has no wildcards or filters.
+func (p *Path) Definite() bool {
+	for _, s := range p.steps {
+		if s.wildcard || s.filter != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval returns the values addressed by the path in document order.
+func (p *Path) Eval(v interface{}) []interface{} {
+	return eval([]interface{}{v}, p.steps)
+}
+
+func eval(nodes []interface{}, steps []step) []interface{} {
+	for _, s := range steps {
+		var next []interface{}
+		for _, n := range nodes {
+			next = append(next, s.apply(n)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (s step) apply(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case Models.Object:
+		switch {
+		case s.wildcard || s.filter != nil:
+			var res []interface{}
+			for _, m := range t {
+				if s.filter == nil || s.filter.match(m.Value) {
+					res = append(res, m.Value)
+				}
+			}
+			return res
+		case !s.isIndex:
+			if e, ok := t.Get(s.key); ok {
+				return []interface{}{e}
+			}
+		}
+	case []interface{}:
+		switch {
+		case s.wildcard || s.filter != nil:
+			var res []interface{}
+			for _, e := range t {
+				if s.filter == nil || s.filter.match(e) {
+					res = append(res, e)
+				}
+			}
+			return res
+		case s.isIndex:
+			i := s.index
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return []interface{}{t[i]}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *filter) match(v interface{}) bool {
+	for _, and := range f.or {
+		ok := true
+		for _, c := range and {
+			if !c.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparison) match(v interface{}) bool {
+	left, ok := c.left.value(v)
+	if !ok {
+		return false
+	}
+	if len(c.op) == 0 {
+		return true
+	}
+	right, ok := c.right.value(v)
+	if !ok {
+		return false
+	}
+	if c.op == "==" || c.op == "!=" {
+		return equal(left, right) == (c.op == "==")
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case json.Number:
+		r, ok := right.(json.Number)
+		if !ok {
+			return false
+		}
+		lf, err1 := l.Float64()
+		rf, err2 := r.Float64()
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		switch {
+		case lf < rf:
+			cmp = -1
+		case lf > rf:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func (o operand) value(current interface{}) (interface{}, bool) {
+	if o.isLit {
+		return o.literal, true
+	}
+	res := eval([]interface{}{current}, o.rel)
+	if len(res) != 1 {
+		return nil, false
+	}
+	return res[0], true
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		xf, err1 := x.Float64()
+		yf, err2 := y.Float64()
+		return err1 == nil && err2 == nil && xf == yf
+	}
+	switch a.(type) {
+	case Models.Object, []interface{}:
+		x, _ := json.Marshal(a)
+		y, _ := json.Marshal(b)
+		return string(x) == string(y)
+	}
+	return a == b
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid path at position %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+// steps parses path segments. A top-level path may start with $ or a bare key,
+// a relative path inside a filter starts after @.
+func (p *parser) steps(top bool) ([]step, error) {
+	var steps []step
+	if top {
+		if p.peek() == '$' {
+			p.pos++
+		} else if c := p.peek(); c != '.' && c != '[' && c != 0 {
+			s, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+		}
+	}
+	for {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			s, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+		case '[':
+			p.pos++
+			s, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+		default:
+			return steps, nil
+		}
+	}
+}
+
+func (p *parser) name() (step, error) {
+	if p.peek() == '*' {
+		p.pos++
+		return step{wildcard: true}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(".[] =!<>&|)", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return step{}, p.errorf("key expected")
+	}
+	return step{key: p.s[start:p.pos]}, nil
+}
+
+func (p *parser) bracket() (step, error) {
+	var s step
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		s.wildcard = true
+	case c == '\'' || c == '"':
+		lit, err := p.quoted()
+		if err != nil {
+			return s, err
+		}
+		s.key = lit
+	case c == '?':
+		p.pos++
+		if p.peek() != '(' {
+			return s, p.errorf("( expected")
+		}
+		p.pos++
+		f, err := p.filter()
+		if err != nil {
+			return s, err
+		}
+		if p.peek() != ')' {
+			return s, p.errorf(") expected")
+		}
+		p.pos++
+		s.filter = f
+	default:
+		start := p.pos
+		if c == '-' {
+			p.pos++
+		}
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		i, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil {
+			return s, p.errorf("index, quoted key, * or filter expected")
+		}
+		s.index, s.isIndex = i, true
+	}
+	p.skipSpaces()
+	if p.peek() != ']' {
+		return s, p.errorf("] expected")
+	}
+	p.pos++
+	return s, nil
+}
+
+func (p *parser) quoted() (string, error) {
+	q := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case q:
+			return b.String(), nil
+		case '\\':
+			if p.pos < len(p.s) {
+				b.WriteByte(p.s[p.pos])
+				p.pos++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) filter() (*filter, error) {
+	f := &filter{}
+	and := []comparison{}
+	for {
+		c, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, c)
+		p.skipSpaces()
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], "&&"):
+			p.pos += 2
+		case strings.HasPrefix(p.s[p.pos:], "||"):
+			p.pos += 2
+			f.or = append(f.or, and)
+			and = []comparison{}
+		default:
+			f.or = append(f.or, and)
+			return f, nil
+		}
+	}
+}
+
+func (p *parser) comparison() (comparison, error) {
+	var c comparison
+	var err error
+	if c.left, err = p.operand(); err != nil {
+		return c, err
+	}
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			c.op = op
+			c.right, err = p.operand()
+			return c, err
+		}
+	}
+	if c.left.isLit {
+		return c, p.errorf("comparison expected")
+	}
+	return c, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '@':
+		p.pos++
+		rel, err := p.steps(false)
+		return operand{rel: rel}, err
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		return operand{literal: s, isLit: true}, err
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" )]&|=!<>", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	word := p.s[start:p.pos]
+	switch word {
+	case "true":
+		return operand{literal: true, isLit: true}, nil
+	case "false":
+		return operand{literal: false, isLit: true}, nil
+	case "null":
+		return operand{literal: nil, isLit: true}, nil
+	}
+	if _, err := strconv.ParseFloat(word, 64); err != nil {
+		p.pos = start
+		return operand{}, p.errorf("@, string, number, true, false or null expected")
+	}
+	return operand{literal: json.Number(word), isLit: true}, nil
+}
@@ -0,0 +1,98 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"testing"
+)
+
+const doc = `{
+	"key1": "value1",
+	"key4": {"A": 1, "B": "x", "C": null},
+	"key5": [
+		{"E": 5, "F": "a"},
+		{"E": 12, "F": "b", "G": true},
+		{"E": 20.5},
+		{"E": "30"}
+	],
+	"key.with.dots": {"x y": 1, "it's": 2}
+}`
+
+func TestEval(t *testing.T) {
+	v, err := Models.ParseValue([]byte(doc))
+	require.NoError(t, err)
+	tests := []struct {
+		expr     string
+		want     string
+		definite bool
+	}{
+		{"key1", `["value1"]`, true},
+		{"$.key1", `["value1"]`, true},
+		{"key4.A", `[1]`, true},
+		{"$.key4.C", `[null]`, true},
+		{"key4.missing", `null`, true},
+		{"key1.A", `null`, true},
+		{"$.key5[1].E", `[12]`, true},
+		{"key5[-1].E", `["30"]`, true},
+		{"key5[4]", `null`, true},
+		{"key4[0]", `null`, true},
+		{"key4.*", `[1,"x",null]`, false},
+		{"key5[*].E", `[5,12,20.5,"30"]`, false},
+		{"['key.with.dots']", `[{"x y":1,"it's":2}]`, true},
+		{`$["key.with.dots"]['x y']`, `[1]`, true},
+		{`['key.with.dots']['it\'s']`, `[2]`, true},
+		{"key5[?(@.E>10)].E", `[12,20.5]`, false},
+		{"key5[?(@.E > 10 && @.E < 20)].F", `["b"]`, false},
+		{"key5[?(@.E == 5 || @.G)].F", `["a","b"]`, false},
+		{"key5[?(@.E >= 12)].E", `[12,20.5]`, false},
+		{"key5[?(@.E <= 5.0)].E", `[5]`, false},
+		{"key5[?(@.E != 5)].E", `[12,20.5,"30"]`, false},
+		{"key5[?(@.E == '30')].E", `["30"]`, false},
+		{"key5[?(@.F > 'a')].F", `["b"]`, false},
+		{"key5[?(@.G == true)].E", `[12]`, false},
+		{"key5[?(@.F)].F", `["a","b"]`, false},
+		{"key4[?(@ == null)]", `[null]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Parse(tt.expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.definite, p.Definite())
+			got, err := json.Marshal(p.Eval(v))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"key4.", "key expected"},
+		{"key4..A", "key expected"},
+		{"key5[", "index, quoted key, * or filter expected"},
+		{"key5[1", "] expected"},
+		{"key5[x]", "index, quoted key, * or filter expected"},
+		{"key5[-]", "index, quoted key, * or filter expected"},
+		{"['key", "unterminated string"},
+		{"key5[?@.E]", "( expected"},
+		{"key5[?(@.E > 10]", ") expected"},
+		{"key5[?(@.E > 10)", "] expected"},
+		{"key5[?(@.E > )]", "@, string, number, true, false or null expected"},
+		{"key5[?(@.E > abc)]", "@, string, number, true, false or null expected"},
+		{"key5[?(10)]", "comparison expected"},
+		{"key1 key2", `unexpected " key2"`},
+		{"key5]", `unexpected "]"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid path")
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -1,18 +1,304 @@
 package models
 
+import (
+	"encoding/json"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	"time"
+)
+
 type ResponseError struct {
 	ErrorDescr string
 	Status     int
+	Fields     []FieldError
 }
 
 func (c ResponseError) Error() string {
 	return c.ErrorDescr
 }
 
+// DefaultEnvironment is the environment of requests that do not name one.
+const DefaultEnvironment = "default"
+
 type ConfigRequest struct {
-	Service  string                 `json:"service"`
-	Data     map[string]interface{} `json:"data,omitempty"`
-	Version  int                    `json:"version,omitempty"`
-	Used     bool                   `json:"-"`
-	Extended bool                   `json:"-"`
+	Service string `json:"service"`
+	// Environment is the namespace of the config, every environment of a
+	// service has its own versions and used version.
+	Environment string   `json:"environment,omitempty"`
+	Data        Document `json:"data"`
+	Version     int      `json:"version,omitempty"`
+	Used        bool     `json:"-"`
+	Extended    bool     `json:"-"`
+	Reveal      bool     `json:"-"`
+	// Format is the format GetConfig data is returned in, JSON if empty.
+	Format string `json:"-"`
+	// Parent is the service the config inherits from: GetConfig returns the
+	// data of the parent merged with the own layer, or the own layer only if Raw is set.
+	Parent string `json:"parent,omitempty"`
+	Raw    bool   `json:"-"`
+	// Provenance maps the paths of the values of a config with a parent to
+	// the service each of them came from.
+	Provenance map[string]string `json:"provenance,omitempty"`
+	// SchemaVersion is the version of the service schema the config was validated against.
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// ActivateAt schedules SetConfig and UpdConfig to make the version the
+	// used one at that time instead of immediately.
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	// Watch makes UpdConfig guard the activation: for that long a failure
+	// reported by ReportHealth or by the health check at HealthURL makes the
+	// previous version the used one again.
+	Watch     time.Duration `json:"-"`
+	HealthURL string        `json:"-"`
+	// Unresolved makes GetConfig return placeholders like ${ref:service/key}
+	// as stored, Raw implies it.
+	Unresolved bool `json:"-"`
+	// References are the services other than the config itself that
+	// placeholders were resolved from.
+	References []string `json:"-"`
+	// ClientID identifies the client reading the used version, a share of
+	// clients gets the canary version instead, see Canary. Variant tells
+	// which of the two GetConfig returned.
+	ClientID string `json:"-"`
+	Variant  string `json:"variant,omitempty"`
+	// Pinned versions are never removed by retention, see PinConfig.
+	Pinned bool `json:"-"`
+	// Purge makes DelConfig delete the version permanently instead of moving
+	// it to the trash, see DeletedVersion.
+	Purge bool `json:"-"`
+}
+
+// KeyRequest addresses a part of a config by a dotted path or JSONPath,
+// Value is the addressed value, or the array of matches for paths with
+// wildcards or filters.
+type KeyRequest struct {
+	Service     string      `json:"service"`
+	Environment string      `json:"environment,omitempty"`
+	Version     int         `json:"version,omitempty"`
+	Path        string      `json:"path"`
+	Reveal      bool        `json:"-"`
+	Value       interface{} `json:"value"`
+	ClientID    string      `json:"-"`
+	Variant     string      `json:"variant,omitempty"`
+}
+
+// SearchRequest looks for used configs having the key given by a dotted path
+// like "db.host", equal to Value if it is set, in Environment if it is set.
+type SearchRequest struct {
+	Path        string          `json:"path"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Environment string          `json:"environment,omitempty"`
+}
+
+type SearchResult struct {
+	Service     string      `json:"service"`
+	Environment string      `json:"environment"`
+	Version     int         `json:"version"`
+	Value       interface{} `json:"value"`
+}
+
+// Environment describes an environment of a service: its used version, 0 if
+// none is used, and the number of versions.
+type Environment struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment"`
+	Version     int    `json:"version"`
+	Versions    int    `json:"versions"`
+}
+
+// PromoteRequest copies a version of a config, the used one if Version is 0,
+// into the Target service as its new version, the used one if Activate is set.
+// Environments default to the default one.
+type PromoteRequest struct {
+	Service           string `json:"service"`
+	Environment       string `json:"environment,omitempty"`
+	Version           int    `json:"version,omitempty"`
+	Target            string `json:"target"`
+	TargetEnvironment string `json:"target_environment,omitempty"`
+	Activate          bool   `json:"activate,omitempty"`
+}
+
+// Promotion records where a promoted or copied config version came from.
+type Promotion struct {
+	Service           string    `json:"service"`
+	Environment       string    `json:"environment"`
+	Version           int       `json:"version"`
+	Used              bool      `json:"used"`
+	SourceService     string    `json:"source_service"`
+	SourceEnvironment string    `json:"source_environment"`
+	SourceVersion     int       `json:"source_version"`
+	PromotedBy        string    `json:"promoted_by"`
+	PromotedAt        time.Time `json:"promoted_at"`
+}
+
+// Activation statuses.
+const (
+	ActivationPending   = "pending"
+	ActivationDone      = "done"
+	ActivationCancelled = "cancelled"
+	ActivationFailed    = "failed"
+)
+
+// Activation is a version of a config scheduled to become the used one at
+// ActivateAt. Error tells why a failed activation couldn't be carried out.
+type Activation struct {
+	ID          int64      `json:"id"`
+	Service     string     `json:"service"`
+	Environment string     `json:"environment"`
+	Version     int        `json:"version"`
+	ActivateAt  time.Time  `json:"activate_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DoneAt      *time.Time `json:"done_at,omitempty"`
+}
+
+// Guard statuses.
+const (
+	GuardWatching   = "watching"
+	GuardPassed     = "passed"
+	GuardRolledBack = "rolled_back"
+	GuardSuperseded = "superseded"
+)
+
+// Guard watches a version activated by a guarded UpdConfig until WatchUntil.
+// If it fails meanwhile PreviousVersion becomes the used one again, Reason
+// records why. A guard whose version was replaced by another is superseded.
+type Guard struct {
+	ID              int64      `json:"id"`
+	Service         string     `json:"service"`
+	Environment     string     `json:"environment"`
+	Version         int        `json:"version"`
+	PreviousVersion int        `json:"previous_version"`
+	HealthURL       string     `json:"health_url,omitempty"`
+	WatchUntil      time.Time  `json:"watch_until"`
+	Status          string     `json:"status"`
+	Reason          string     `json:"reason,omitempty"`
+	CreatedBy       string     `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DoneAt          *time.Time `json:"done_at,omitempty"`
+}
+
+// HealthReport tells whether the version watched by the guard of the service
+// in the environment works.
+type HealthReport struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment,omitempty"`
+	Healthy     bool   `json:"healthy"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// RetentionPolicy tells which versions of the configs of a service are kept:
+// the last KeepLast versions of every environment and those created in the
+// last KeepDays days, 0 meaning the rule doesn't apply. Without any rule all
+// versions are kept. The used, pinned and canary versions, versions with a
+// pending activation and the versions guarded activations may roll back to
+// are always kept. Global tells the service has no policy of its own and
+// follows the global one.
+type RetentionPolicy struct {
+	Service   string     `json:"service"`
+	KeepLast  int        `json:"keep_last"`
+	KeepDays  int        `json:"keep_days"`
+	Global    bool       `json:"global,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ExpiredVersion is a version of a config the retention policy of its
+// service removes.
+type ExpiredVersion struct {
+	Service     string    `json:"service"`
+	Environment string    `json:"environment"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeletedVersion is a version of a config in the trash: DelConfig and
+// retention only mark versions deleted, RestoreConfig brings them back until
+// they are purged at PurgeAt.
+type DeletedVersion struct {
+	Service     string    `json:"service"`
+	Environment string    `json:"environment"`
+	Version     int       `json:"version"`
+	DeletedBy   string    `json:"deleted_by,omitempty"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// Variants of a config GetConfig returns in place of the used version.
+const (
+	VariantStable = "stable"
+	VariantCanary = "canary"
+)
+
+// Canary is a version of a config served instead of the used one to
+// Percentage percent of the clients, chosen by a stable hash of their IDs.
+type Canary struct {
+	Service     string    `json:"service"`
+	Environment string    `json:"environment"`
+	Version     int       `json:"version"`
+	Percentage  float64   `json:"percentage"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Flag is a feature flag of a service in an environment.
+type Flag struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment"`
+	flags.Flag
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FlagsRequest asks for the values of the flags of a service for a client,
+// of all of them if Flags is empty.
+type FlagsRequest struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment,omitempty"`
+	flags.Context
+	Flags []string `json:"flags,omitempty"`
+}
+
+// CopyRequest copies a version of a config, the used one if Version is 0,
+// from one environment of the service to another as its new used version.
+type CopyRequest struct {
+	Service string `json:"service"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Version int    `json:"version,omitempty"`
+}
+
+// Patch types of a PatchRequest.
+const (
+	MergePatch = "merge" // RFC 7396
+	JSONPatch  = "json"  // RFC 6902
+)
+
+// PatchRequest changes a config with a merge patch or JSON Patch operations.
+// Version, if set, is the version the patch is expected to apply to.
+type PatchRequest struct {
+	Service     string          `json:"service"`
+	Environment string          `json:"environment,omitempty"`
+	Version     int             `json:"version,omitempty"`
+	Type        string          `json:"type"`
+	Patch       json.RawMessage `json:"patch"`
+}
+
+// FieldError describes a problem with a single value of a config, Path is
+// like "data.key4.A" or "data.key5[1].E".
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type SchemaRequest struct {
+	Service       string          `json:"service"`
+	Schema        json.RawMessage `json:"schema,omitempty"`
+	Version       int             `json:"version,omitempty"`
+	Compatibility string          `json:"compatibility,omitempty"`
+}
+
+type ValidationResult struct {
+	Valid  bool         `json:"valid"`
+	Errors []FieldError `json:"errors,omitempty"`
 }
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Document is the data of a config. It accepts both
+//
+//	{"key1": "value1", "key2": 15}
+//	[{"key1": "value1"}, {"key2": 15}]
+//
+// and is encoded back in the shape it was sent in, keeping the order of keys
+// at every level. Duplicate keys are rejected.
+type Document struct {
+	Members Object
+	// Array is set when the document was sent as an array of single-key objects.
+	Array bool
+}
+
+// Object is a JSON object with its keys in their original order. Values are
+// nil, bool, json.Number, string, []interface{} or Object.
+type Object []Member
+
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Get returns the value of key.
+func (o Object) Get(key string) (interface{}, bool) {
+	for _, m := range o {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of key or appends it if o has no such key.
+func (o Object) Set(key string, value interface{}) Object {
+	for i := range o {
+		if o[i].Key == key {
+			o[i].Value = value
+			return o
+		}
+	}
+	return append(o, Member{Key: key, Value: value})
+}
+
+// Delete removes key from o.
+func (o Object) Delete(key string) Object {
+	for i := range o {
+		if o[i].Key == key {
+			return append(o[:i:i], o[i+1:]...)
+		}
+	}
+	return o
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := writeMember(&b, m); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (o *Object) UnmarshalJSON(b []byte) error {
+	v, err := ParseValue(b)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	obj, ok := v.(Object)
+	if !ok {
+		return fmt.Errorf("json: expected an object")
+	}
+	*o = obj
+	return nil
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	if !d.Array {
+		return d.Members.MarshalJSON()
+	}
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, m := range d.Members {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		if err := writeMember(&b, m); err != nil {
+			return nil, err
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+func (d *Document) UnmarshalJSON(b []byte) error {
+	v, err := ParseValue(b)
+	if err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case Object:
+		*d = Document{Members: t}
+		return nil
+	case []interface{}:
+		doc := Document{Members: make(Object, 0, len(t)), Array: true}
+		for i, e := range t {
+			obj, ok := e.(Object)
+			if !ok || len(obj) != 1 {
+				return fmt.Errorf("element %d of the data array must be an object with a single key", i)
+			}
+			if _, dup := doc.Members.Get(obj[0].Key); dup {
+				return fmt.Errorf("duplicate key %q in data", obj[0].Key)
+			}
+			doc.Members = append(doc.Members, obj[0])
+		}
+		*d = doc
+		return nil
+	}
+	return fmt.Errorf("data must be an object or an array of single-key objects")
+}
+
+func writeMember(b *bytes.Buffer, m Member) error {
+	k, err := json.Marshal(m.Key)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(m.Value)
+	if err != nil {
+		return err
+	}
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(v)
+	return nil
+}
+
+// ParseValue decodes a JSON value keeping the order of object keys, objects
+// are returned as Object and numbers as json.Number. Duplicate keys are rejected.
+func ParseValue(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := parseValue(dec, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("json: unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+func parseValue(dec *json.Decoder, path string) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		obj := make(Object, 0)
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := t.(string)
+			if _, dup := obj.Get(key); dup {
+				return nil, fmt.Errorf("duplicate key %q in data%s", key, path)
+			}
+			v, err := parseValue(dec, path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, Member{Key: key, Value: v})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := make([]interface{}, 0)
+		for dec.More() {
+			v, err := parseValue(dec, fmt.Sprintf("%s[%d]", path, len(arr)))
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	}
+	return t, nil
+}
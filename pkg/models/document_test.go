@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		array bool
+		err   string
+	}{
+		{"object keeps key order", `{"z":1,"a":{"y":2,"b":3},"m":[{"q":1,"p":2}]}`, `{"z":1,"a":{"y":2,"b":3},"m":[{"q":1,"p":2}]}`, false, ""},
+		{"array form", ` [{"key1":"value1"}, {"key2":15}] `, `[{"key1":"value1"},{"key2":15}]`, true, ""},
+		{"empty object", `{}`, `{}`, false, ""},
+		{"empty array", `[]`, `[]`, true, ""},
+		{"scalars", `{"s":"x\"y","t":true,"f":false,"n":null,"u":"é"}`, `{"s":"x\"y","t":true,"f":false,"n":null,"u":"é"}`, false, ""},
+		{"numbers are kept as written", `{"big":12345678901234567890,"f":1.50,"e":1e400,"neg":-0}`,
+			`{"big":12345678901234567890,"f":1.50,"e":1e400,"neg":-0}`, false, ""},
+		{"duplicate key", `{"a":1,"b":2,"a":3}`, "", false, `duplicate key "a" in data`},
+		{"nested duplicate key", `{"a":[{"b":{"c":1,"c":2}}]}`, "", false, `duplicate key "c" in data.a[0].b`},
+		{"duplicate key across array elements", `[{"a":1},{"a":2}]`, "", true, `duplicate key "a" in data`},
+		{"array element with two keys", `[{"a":1,"b":2}]`, "", true, "element 0 of the data array must be an object with a single key"},
+		{"array element that is not an object", `[{"a":1},2]`, "", true, "element 1 of the data array"},
+		{"scalar", `"x"`, "", false, "data must be an object or an array"},
+		{"trailing data", `{"a":1} {}`, "", false, "after top-level value"},
+		{"invalid JSON", `{"a":}`, "", false, "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc Document
+			err := json.Unmarshal([]byte(tt.in), &doc)
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.array, doc.Array)
+			out, err := json.Marshal(doc)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(out))
+
+			var back Document
+			require.NoError(t, json.Unmarshal(out, &back))
+			require.Equal(t, doc, back)
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	v, err := ParseValue([]byte(`{"n":10,"f":0.1,"a":[1,"2",null]}`))
+	require.NoError(t, err)
+	require.Equal(t, Object{
+		{Key: "n", Value: json.Number("10")},
+		{Key: "f", Value: json.Number("0.1")},
+		{Key: "a", Value: []interface{}{json.Number("1"), "2", nil}},
+	}, v)
+
+	v, err = ParseValue([]byte(`null`))
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	_, err = ParseValue([]byte(`{"a":1} {}`))
+	require.EqualError(t, err, "json: unexpected data after the top-level value")
+}
+
+func TestObject(t *testing.T) {
+	o := Object{{Key: "a", Value: 1}, {Key: "b", Value: 2}, {Key: "c", Value: 3}}
+
+	v, ok := o.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+	_, ok = o.Get("x")
+	require.False(t, ok)
+
+	o = o.Set("b", 20).Set("d", 4)
+	require.Equal(t, Object{{Key: "a", Value: 1}, {Key: "b", Value: 20}, {Key: "c", Value: 3}, {Key: "d", Value: 4}}, o)
+
+	deleted := o.Delete("b")
+	require.Equal(t, Object{{Key: "a", Value: 1}, {Key: "c", Value: 3}, {Key: "d", Value: 4}}, deleted)
+	require.Equal(t, Object{{Key: "a", Value: 1}, {Key: "b", Value: 20}, {Key: "c", Value: 3}, {Key: "d", Value: 4}}, o)
+	require.Equal(t, deleted, deleted.Delete("x"))
+
+	var obj Object
+	require.NoError(t, json.Unmarshal([]byte(`null`), &obj))
+	require.Nil(t, obj)
+	require.Error(t, json.Unmarshal([]byte(`[1]`), &obj))
+}
@@ -0,0 +1,242 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"reflect"
+	"sort"
+)
+
+// Compatibility modes, with the same meaning as in a schema registry:
+// backward — the new schema accepts all data valid under the previous version,
+// forward — data valid under the new schema is accepted by the previous version,
+// full — both. Transitive modes check against all previous versions, not only the latest.
+const (
+	None               = "none"
+	Backward           = "backward"
+	BackwardTransitive = "backward_transitive"
+	Forward            = "forward"
+	ForwardTransitive  = "forward_transitive"
+	Full               = "full"
+	FullTransitive     = "full_transitive"
+)
+
+// ValidMode reports whether mode is one of the compatibility modes.
+func ValidMode(mode string) bool {
+	switch mode {
+	case None, Backward, BackwardTransitive, Forward, ForwardTransitive, Full, FullTransitive:
+		return true
+	}
+	return false
+}
+
+// Transitive reports whether mode has to be checked against every previous version.
+func Transitive(mode string) bool {
+	return mode == BackwardTransitive || mode == ForwardTransitive || mode == FullTransitive
+}
+
+// CheckCompatibility checks the new schema against previous versions, given as
+// version number to document, and returns the detected incompatibilities.
+//
+// The check is structural and conservative: it understands type, enum,
+// properties, required, additionalProperties, items and the numeric, length
+// and size bounds. Any other keyword that differs between the two schemas is
+// reported as unverifiable.
+func CheckCompatibility(mode string, doc []byte, previous map[int][]byte) ([]Models.FieldError, error) {
+	if mode == None {
+		return nil, nil
+	}
+	var next interface{}
+	if err := json.Unmarshal(doc, &next); err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(previous))
+	for v := range previous {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	var fields []Models.FieldError
+	for _, v := range versions {
+		var prev interface{}
+		if err := json.Unmarshal(previous[v], &prev); err != nil {
+			return nil, err
+		}
+		if mode != Forward && mode != ForwardTransitive {
+			for _, f := range covers(prev, next, "data") {
+				f.Message = fmt.Sprintf("not backward compatible with version %d: %s", v, f.Message)
+				fields = append(fields, f)
+			}
+		}
+		if mode != Backward && mode != BackwardTransitive {
+			for _, f := range covers(next, prev, "data") {
+				f.Message = fmt.Sprintf("not forward compatible with version %d: %s", v, f.Message)
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// unverifiable lists the keywords whose effect on compatibility is not analysed.
+var unverifiable = []string{"$ref", "$defs", "definitions", "allOf", "anyOf", "oneOf", "not", "if", "then", "else",
+	"const", "pattern", "format", "multipleOf", "uniqueItems", "contains", "prefixItems",
+	"patternProperties", "propertyNames", "dependentRequired", "dependentSchemas", "unevaluatedProperties", "unevaluatedItems"}
+
+var lowerBounds = []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"}
+var upperBounds = []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"}
+
+// covers returns the reasons why the reader schema r may reject a value that
+// is valid under the writer schema w.
+func covers(w, r interface{}, path string) []Models.FieldError {
+	if rb, ok := r.(bool); ok {
+		if wb, ok := w.(bool); rb || (ok && !wb) {
+			return nil
+		}
+		return []Models.FieldError{{Path: path, Message: "no value is allowed"}}
+	}
+	rm, _ := r.(map[string]interface{})
+	if len(rm) == 0 {
+		return nil
+	}
+	if wb, ok := w.(bool); ok {
+		if !wb {
+			return nil
+		}
+		w = map[string]interface{}{}
+	}
+	wm, _ := w.(map[string]interface{})
+	if reflect.DeepEqual(wm, rm) {
+		return nil
+	}
+
+	var fields []Models.FieldError
+	problem := func(p, format string, a ...interface{}) {
+		fields = append(fields, Models.FieldError{Path: p, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for _, k := range unverifiable {
+		if rv, ok := rm[k]; ok && !reflect.DeepEqual(rv, wm[k]) {
+			problem(path, "can't verify the change of %s", k)
+		}
+	}
+
+	if rt := types(rm); rt != nil {
+		wt := types(wm)
+		if wt == nil {
+			problem(path, "type was not restricted, now must be %v", rm["type"])
+		}
+		for t := range wt {
+			if !rt[t] && !(t == "integer" && rt["number"]) {
+				problem(path, "type %s is no longer allowed", t)
+			}
+		}
+	}
+
+	if re, ok := rm["enum"].([]interface{}); ok {
+		we, ok := wm["enum"].([]interface{})
+		if !ok {
+			problem(path, "values were not restricted, now limited to %v", re)
+		}
+		for _, v := range we {
+			if !contains(re, v) {
+				problem(path, "value %v is no longer allowed", v)
+			}
+		}
+	}
+
+	for _, k := range lowerBounds {
+		if rv, ok := rm[k].(float64); ok {
+			if wv, ok := wm[k].(float64); !ok || wv < rv {
+				problem(path, "%s %v is stricter than before", k, rv)
+			}
+		}
+	}
+	for _, k := range upperBounds {
+		if rv, ok := rm[k].(float64); ok {
+			if wv, ok := wm[k].(float64); !ok || wv > rv {
+				problem(path, "%s %v is stricter than before", k, rv)
+			}
+		}
+	}
+
+	if rr, ok := rm["required"].([]interface{}); ok {
+		wr, _ := wm["required"].([]interface{})
+		for _, name := range rr {
+			if !contains(wr, name) {
+				problem(fmt.Sprintf("%s.%v", path, name), "property became required")
+			}
+		}
+	}
+
+	rprops, _ := rm["properties"].(map[string]interface{})
+	wprops, _ := wm["properties"].(map[string]interface{})
+	for _, name := range sortedKeys(rprops) {
+		ws, ok := wprops[name]
+		if !ok {
+			ws = additional(wm)
+		}
+		fields = append(fields, covers(ws, rprops[name], path+"."+name)...)
+	}
+	if rap, ok := rm["additionalProperties"]; ok {
+		for _, name := range sortedKeys(wprops) {
+			if _, ok := rprops[name]; !ok {
+				fields = append(fields, covers(wprops[name], rap, path+"."+name)...)
+			}
+		}
+		fields = append(fields, covers(additional(wm), rap, path+".*")...)
+	}
+
+	if ri, ok := rm["items"]; ok {
+		wi, ok := wm["items"]
+		if !ok {
+			wi = true
+		}
+		fields = append(fields, covers(wi, ri, path+"[]")...)
+	}
+	return fields
+}
+
+func types(m map[string]interface{}) map[string]bool {
+	switch t := m["type"].(type) {
+	case string:
+		return map[string]bool{t: true}
+	case []interface{}:
+		res := make(map[string]bool)
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				res[s] = true
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// additional returns the schema applied to properties not listed in properties.
+func additional(m map[string]interface{}) interface{} {
+	if ap, ok := m["additionalProperties"]; ok {
+		return ap
+	}
+	return true
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,166 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"testing"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer string
+		reader string
+		want   []Models.FieldError
+	}{
+		{"same schema", `{"type":"object","properties":{"a":{"type":"string"}}}`, `{"type":"object","properties":{"a":{"type":"string"}}}`, nil},
+		{"reader accepts anything", `{"type":"string"}`, `{}`, nil},
+		{"reader true", `{"type":"string"}`, `true`, nil},
+		{"reader false", `{"type":"string"}`, `false`, []Models.FieldError{{Path: "data", Message: "no value is allowed"}}},
+		{"writer false", `false`, `false`, nil},
+		{"writer true", `true`, `{"type":"string"}`, []Models.FieldError{{Path: "data", Message: "type was not restricted, now must be string"}}},
+
+		{"type narrowed", `{"type":["string","number"]}`, `{"type":"string"}`, []Models.FieldError{{Path: "data", Message: "type number is no longer allowed"}}},
+		{"type widened", `{"type":"string"}`, `{"type":["string","null"]}`, nil},
+		{"integer read as number", `{"type":"integer"}`, `{"type":"number"}`, nil},
+		{"number read as integer", `{"type":"number"}`, `{"type":"integer"}`, []Models.FieldError{{Path: "data", Message: "type number is no longer allowed"}}},
+		{"type restricted", `{}`, `{"type":"string"}`, []Models.FieldError{{Path: "data", Message: "type was not restricted, now must be string"}}},
+
+		{"enum value removed", `{"enum":["a","b"]}`, `{"enum":["a"]}`, []Models.FieldError{{Path: "data", Message: "value b is no longer allowed"}}},
+		{"enum value added", `{"enum":["a"]}`, `{"enum":["a","b"]}`, nil},
+		{"enum introduced", `{"type":"string"}`, `{"type":"string","enum":["a"]}`, []Models.FieldError{{Path: "data", Message: "values were not restricted, now limited to [a]"}}},
+		{"enum dropped", `{"enum":["a"]}`, `{}`, nil},
+
+		{"minimum raised", `{"minimum":1}`, `{"minimum":2}`, []Models.FieldError{{Path: "data", Message: "minimum 2 is stricter than before"}}},
+		{"minimum lowered", `{"minimum":2}`, `{"minimum":1}`, nil},
+		{"maxLength introduced", `{"type":"string"}`, `{"type":"string","maxLength":5}`, []Models.FieldError{{Path: "data", Message: "maxLength 5 is stricter than before"}}},
+		{"maxItems raised", `{"maxItems":2}`, `{"maxItems":3}`, nil},
+
+		{"property became required", `{"properties":{"a":{}}}`, `{"properties":{"a":{}},"required":["a"]}`,
+			[]Models.FieldError{{Path: "data.a", Message: "property became required"}}},
+		{"added required property", `{"type":"object"}`, `{"type":"object","required":["b"],"properties":{"b":{"type":"string"}}}`, []Models.FieldError{
+			{Path: "data.b", Message: "property became required"},
+			{Path: "data.b", Message: "type was not restricted, now must be string"},
+		}},
+		{"required dropped", `{"required":["a"]}`, `{}`, nil},
+		{"property type narrowed", `{"properties":{"db":{"properties":{"port":{"type":["integer","string"]}}}}}`,
+			`{"properties":{"db":{"properties":{"port":{"type":"integer"}}}}}`,
+			[]Models.FieldError{{Path: "data.db.port", Message: "type string is no longer allowed"}}},
+		{"property removed from a closed object", `{"properties":{"a":{},"b":{}},"additionalProperties":false}`,
+			`{"properties":{"a":{}},"additionalProperties":false}`,
+			[]Models.FieldError{{Path: "data.b", Message: "no value is allowed"}}},
+		{"property removed from an open object", `{"properties":{"a":{},"b":{"type":"string"}}}`, `{"properties":{"a":{}}}`, nil},
+		{"property added to a closed writer", `{"properties":{"a":{}},"additionalProperties":false}`,
+			`{"properties":{"a":{},"b":{"type":"string"}},"additionalProperties":false}`, nil},
+		{"property added to an open writer", `{"properties":{"a":{}}}`, `{"properties":{"a":{},"b":{"type":"string"}}}`,
+			[]Models.FieldError{{Path: "data.b", Message: "type was not restricted, now must be string"}}},
+
+		{"additionalProperties closed", `{"properties":{"a":{}}}`, `{"properties":{"a":{}},"additionalProperties":false}`,
+			[]Models.FieldError{{Path: "data.*", Message: "no value is allowed"}}},
+		{"additionalProperties opened", `{"additionalProperties":false}`, `{"additionalProperties":true}`, nil},
+		{"additionalProperties narrowed", `{"additionalProperties":{"type":["string","number"]}}`, `{"additionalProperties":{"type":"string"}}`,
+			[]Models.FieldError{{Path: "data.*", Message: "type number is no longer allowed"}}},
+		{"additionalProperties against a listed property", `{"properties":{"x":{"type":"number"}}}`, `{"additionalProperties":{"type":"string"}}`, []Models.FieldError{
+			{Path: "data.x", Message: "type number is no longer allowed"},
+			{Path: "data.*", Message: "type was not restricted, now must be string"},
+		}},
+
+		{"items narrowed", `{"type":"array","items":{"type":["string","null"]}}`, `{"type":"array","items":{"type":"string"}}`,
+			[]Models.FieldError{{Path: "data[]", Message: "type null is no longer allowed"}}},
+		{"items introduced", `{"type":"array"}`, `{"type":"array","items":{"type":"string"}}`,
+			[]Models.FieldError{{Path: "data[]", Message: "type was not restricted, now must be string"}}},
+
+		{"unverifiable keyword", `{"type":"string"}`, `{"type":"string","pattern":"^a"}`, []Models.FieldError{{Path: "data", Message: "can't verify the change of pattern"}}},
+		{"unchanged unverifiable keyword", `{"type":"string","pattern":"^a","minLength":1}`, `{"type":"string","pattern":"^a"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := CheckCompatibility(Backward, []byte(tt.reader), map[int][]byte{1: []byte(tt.writer)})
+			require.NoError(t, err)
+			for i := range tt.want {
+				tt.want[i].Message = "not backward compatible with version 1: " + tt.want[i].Message
+			}
+			require.Equal(t, tt.want, fields)
+		})
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	// v1 allowed b as a string or a number, v2 dropped it, the new schema brings it back as a string.
+	previous := map[int][]byte{
+		1: []byte(`{"properties":{"a":{"type":"string"},"b":{"type":["string","number"]}}}`),
+		2: []byte(`{"properties":{"a":{"type":"string"}}}`),
+	}
+	next := []byte(`{"properties":{"a":{"type":"string"},"b":{"type":"string"}}}`)
+	latest := map[int][]byte{2: previous[2]}
+	backward1 := Models.FieldError{Path: "data.b", Message: "not backward compatible with version 1: type number is no longer allowed"}
+	backward2 := Models.FieldError{Path: "data.b", Message: "not backward compatible with version 2: type was not restricted, now must be string"}
+
+	tests := []struct {
+		name     string
+		mode     string
+		previous map[int][]byte
+		want     []Models.FieldError
+	}{
+		{"none", None, previous, nil},
+		{"backward", Backward, latest, []Models.FieldError{backward2}},
+		{"backward transitive", BackwardTransitive, previous, []Models.FieldError{backward1, backward2}},
+		{"forward", Forward, latest, nil},
+		{"forward transitive", ForwardTransitive, previous, nil},
+		{"full", Full, latest, []Models.FieldError{backward2}},
+		{"full transitive", FullTransitive, previous, []Models.FieldError{backward1, backward2}},
+		{"no previous versions", FullTransitive, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := CheckCompatibility(tt.mode, next, tt.previous)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, fields)
+		})
+	}
+
+	// Closing the object is forward compatible only: old data with other keys
+	// is rejected, new data is still read by the open previous version.
+	closed := []byte(`{"properties":{"a":{"type":"string"}},"additionalProperties":false}`)
+	fields, err := CheckCompatibility(Forward, closed, latest)
+	require.NoError(t, err)
+	require.Empty(t, fields)
+	fields, err = CheckCompatibility(Full, closed, latest)
+	require.NoError(t, err)
+	require.Equal(t, []Models.FieldError{{Path: "data.*", Message: "not backward compatible with version 2: no value is allowed"}}, fields)
+
+	// Data without a no longer required property is rejected by the previous version.
+	fields, err = CheckCompatibility(Forward, []byte(`{"properties":{"a":{"type":"string"}}}`),
+		map[int][]byte{1: []byte(`{"properties":{"a":{"type":"string"}},"required":["a"]}`)})
+	require.NoError(t, err)
+	require.Equal(t, []Models.FieldError{{Path: "data.a", Message: "not forward compatible with version 1: property became required"}}, fields)
+
+	// Data written with b widened again is readable by v2, but not by v1.
+	wider := []byte(`{"properties":{"a":{"type":"string"},"b":{"type":["string","number","null"]}}}`)
+	narrow := map[int][]byte{1: []byte(`{"properties":{"b":{"type":"string"}}}`), 2: previous[2]}
+	forward1 := []Models.FieldError{
+		{Path: "data.b", Message: "not forward compatible with version 1: type null is no longer allowed"},
+		{Path: "data.b", Message: "not forward compatible with version 1: type number is no longer allowed"},
+	}
+	fields, err = CheckCompatibility(Forward, wider, latest)
+	require.NoError(t, err)
+	require.Empty(t, fields)
+	fields, err = CheckCompatibility(ForwardTransitive, wider, narrow)
+	require.NoError(t, err)
+	require.ElementsMatch(t, forward1, fields)
+
+	_, err = CheckCompatibility(Backward, []byte(`{`), latest)
+	require.Error(t, err)
+	_, err = CheckCompatibility(Backward, next, map[int][]byte{1: []byte(`{`)})
+	require.Error(t, err)
+}
+
+func TestModes(t *testing.T) {
+	for _, mode := range []string{None, Backward, BackwardTransitive, Forward, ForwardTransitive, Full, FullTransitive} {
+		require.True(t, ValidMode(mode), mode)
+	}
+	require.False(t, ValidMode("transitive"))
+	require.False(t, ValidMode(""))
+	require.True(t, Transitive(FullTransitive))
+	require.False(t, Transitive(Full))
+}
@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const schemaURL = "mem:///schema.json"
+
+// Compile parses a JSON Schema document. References to external documents are
+// not resolved, a schema has to be self-contained.
+func Compile(doc []byte) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external reference %s is not allowed", url)
+	}
+	if err := c.AddResource(schemaURL, bytes.NewReader(doc)); err != nil {
+		return nil, err
+	}
+	return c.Compile(schemaURL)
+}
+
+// Validate checks the config data against s and returns an error for every
+// invalid value, with paths relative to the "data" field.
+func Validate(s *jsonschema.Schema, data interface{}) []Models.FieldError {
+	err := s.Validate(data)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Models.FieldError{{Path: "data", Message: err.Error()}}
+	}
+
+	var fields []Models.FieldError
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			fields = append(fields, Models.FieldError{Path: pointerToPath(e.InstanceLocation, data), Message: e.Message})
+		}
+		for _, c := range e.Causes {
+			collect(c)
+		}
+	}
+	collect(ve)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields
+}
+
+// pointerToPath turns a JSON pointer into a path like data.key5[1].E, using the
+// instance to tell array indexes from object keys.
+func pointerToPath(ptr string, v interface{}) string {
+	path := "data"
+	if len(ptr) == 0 {
+		return path
+	}
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		switch t := v.(type) {
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err == nil && i >= 0 && i < len(t) {
+				path += "[" + tok + "]"
+				v = t[i]
+				continue
+			}
+			v = nil
+		case map[string]interface{}:
+			v = t[tok]
+		default:
+			v = nil
+		}
+		path += "." + tok
+	}
+	return path
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string"},
+		"db": {"type": "object", "required": ["host"], "properties": {
+			"host": {"type": "string"},
+			"port": {"type": "integer", "minimum": 1}
+		}},
+		"key5": {"type": "array", "items": {"type": "object", "properties": {"E": {"type": "number", "maximum": 10}}}},
+		"a/b~c": {"type": "string"},
+		"labels": {"type": "object", "additionalProperties": {"type": "string"}}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(testSchema))
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		data string
+		want []Models.FieldError
+	}{
+		{"valid", `{"name":"svc","db":{"host":"h","port":5432},"key5":[{"E":1}],"labels":{"a":"b"}}`, nil},
+		{"missing required property", `{"db":{"host":"h"}}`,
+			[]Models.FieldError{{Path: "data", Message: "missing properties: 'name'"}}},
+		{"nested object", `{"name":"svc","db":{"port":0}}`, []Models.FieldError{
+			{Path: "data.db", Message: "missing properties: 'host'"},
+			{Path: "data.db.port", Message: "must be >= 1 but found 0"},
+		}},
+		{"array element", `{"name":"svc","key5":[{"E":1},{"E":12}]}`,
+			[]Models.FieldError{{Path: "data.key5[1].E", Message: "must be <= 10 but found 12"}}},
+		{"escaped key", `{"name":"svc","a/b~c":1}`,
+			[]Models.FieldError{{Path: "data.a/b~c", Message: "expected string, but got number"}}},
+		{"numeric object key", `{"name":"svc","labels":{"0":1}}`,
+			[]Models.FieldError{{Path: "data.labels.0", Message: "expected string, but got number"}}},
+		{"unknown property", `{"name":"svc","x":1}`,
+			[]Models.FieldError{{Path: "data", Message: "additionalProperties 'x' not allowed"}}},
+		{"wrong top-level type", `[1]`,
+			[]Models.FieldError{{Path: "data", Message: "expected object, but got array"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &v))
+			require.Equal(t, tt.want, Validate(s, v))
+		})
+	}
+}
+
+func TestPointerToPath(t *testing.T) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":[{"b":1},[2]],"c/d":{"e~f":1},"g":{"0":1}}`), &v))
+	tests := []struct {
+		ptr  string
+		want string
+	}{
+		{"", "data"},
+		{"/a", "data.a"},
+		{"/a/0/b", "data.a[0].b"},
+		{"/a/1/0", "data.a[1][0]"},
+		{"/a/5", "data.a.5"},
+		{"/c~1d/e~0f", "data.c/d.e~f"},
+		{"/g/0", "data.g.0"},
+		{"/missing/0", "data.missing.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ptr, func(t *testing.T) {
+			require.Equal(t, tt.want, pointerToPath(tt.ptr, v))
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile([]byte(`{"type":"object"}`))
+	require.NoError(t, err)
+	_, err = Compile([]byte(`{"type":"object"`))
+	require.Error(t, err)
+	_, err = Compile([]byte(`{"type":"nothing"}`))
+	require.Error(t, err)
+	_, err = Compile([]byte(`{"$ref":"https://example.com/schema.json"}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not allowed")
+}
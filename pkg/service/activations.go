@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// scheduleActivation records that the version is to become the used one of
+// the service in the environment at the given time.
+func scheduleActivation(ctx context.Context, db execer, service, env string, version int, at time.Time) error {
+	_, err := db.ExecContext(ctx, "insert into activations (service, environment, version, activate_at, created_by) values ($1, $2, $3, $4, $5)",
+		service, env, version, at, auth.Subject(ctx))
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return nil
+}
+
+func checkActivateAt(at time.Time) error {
+	if !at.After(time.Now()) {
+		return Models.ResponseError{ErrorDescr: "activate_at must be in the future", Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// scheduleUpdate schedules the activation requested by UpdConfig, which needs
+// the version and used=true. The versions stay locked until it is scheduled so
+// that the version can't be deleted meanwhile, and a version is scheduled at
+// most once at a time.
+func (svc configService) scheduleUpdate(ctx context.Context, r *Models.ConfigRequest) (*Models.ConfigRequest, error) {
+	if !r.Used || r.Version == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "activate_at can only schedule the activation of a version: version and used=true must be specified", Status: http.StatusBadRequest}
+	}
+	if err := checkActivateAt(*r.ActivateAt); err != nil {
+		return nil, err
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	var exists, pending bool
+	err = tx.QueryRowContext(ctx, `select exists (select 1 from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null),
+		exists (select 1 from activations where service = $1 and environment = $2 and version = $3 and status = $4)`,
+		r.Service, r.Environment, r.Version, Models.ActivationPending).Scan(&exists, &pending)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if !exists {
+		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	}
+	if pending {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Activation of version %d is already scheduled, cancel it first", r.Version), Status: http.StatusConflict}
+	}
+	if err := scheduleActivation(ctx, tx, r.Service, r.Environment, r.Version, *r.ActivateAt); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// ListActivations returns the activations scheduled for the service in the
+// environment, pending and past ones, the latest first.
+func (svc configService) ListActivations(_ context.Context, req interface{}) ([]Models.Activation, error) {
+	r := req.(*Models.ConfigRequest)
+	env := environment(r.Environment)
+	rows, err := svc.DB.Query(`select id, version, activate_at, status, coalesce(error, ''), coalesce(created_by, ''), created_at, done_at
+		from activations where service = $1 and environment = $2 order by activate_at desc, id desc`, r.Service, env)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.Activation, 0)
+	for rows.Next() {
+		a := Models.Activation{Service: r.Service, Environment: env}
+		err := rows.Scan(&a.ID, &a.Version, &a.ActivateAt, &a.Status, &a.Error, &a.CreatedBy, &a.CreatedAt, &a.DoneAt)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+// CancelActivation cancels a pending activation of the service.
+func (svc configService) CancelActivation(_ context.Context, req interface{}) (*Models.Activation, error) {
+	r := req.(*Models.Activation)
+	a := Models.Activation{ID: r.ID, Service: r.Service}
+	err := svc.DB.QueryRow(`update activations set status = $3, done_at = now() where id = $1 and service = $2 and status = $4
+		returning environment, version, activate_at, status, coalesce(created_by, ''), created_at, done_at`,
+		r.ID, r.Service, Models.ActivationCancelled, Models.ActivationPending).
+		Scan(&a.Environment, &a.Version, &a.ActivateAt, &a.Status, &a.CreatedBy, &a.CreatedAt, &a.DoneAt)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("No pending activation %d of service %s", r.ID, r.Service), Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &a, nil
+}
+
+// StartScheduler carries out the activations that are due and checks the
+// guarded ones every interval. Activations falling due while no instance was
+// running are carried out on start.
+func StartScheduler(s *configService, interval time.Duration) {
+	go func() {
+		for {
+			if err := s.activateDue(); err != nil {
+				log.Printf("Scheduled activation failed: %v", err)
+			}
+			if err := s.watchGuards(); err != nil {
+				log.Printf("Guarded activation check failed: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func (svc configService) activateDue() error {
+	for {
+		found, err := svc.activateNext()
+		if err != nil || !found {
+			return err
+		}
+	}
+}
+
+// activateNext carries out the earliest due activation, if any. Its row stays
+// locked until the activation is recorded, other instances skip it.
+func (svc configService) activateNext() (bool, error) {
+	ctx := context.Background()
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var a Models.Activation
+	err = tx.QueryRowContext(ctx, `select id, service, environment, version from activations
+		where status = $1 and activate_at <= now() order by activate_at, id limit 1 for update skip locked`, Models.ActivationPending).
+		Scan(&a.ID, &a.Service, &a.Environment, &a.Version)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := lockVersions(ctx, tx, a.Service, a.Environment); err != nil {
+		return false, err
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, "select exists (select 1 from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null)", a.Service, a.Environment, a.Version).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	a.Status = Models.ActivationDone
+	if exists {
+		_, err = tx.ExecContext(ctx, "update configs set used = (version = $3) where service = $1 and environment = $2 and (used = true or version = $3) and deleted_at is null", a.Service, a.Environment, a.Version)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		a.Status, a.Error = Models.ActivationFailed, "version no longer exists"
+	}
+	_, err = tx.ExecContext(ctx, "update activations set status = $2, error = $3, done_at = now() where id = $1", a.ID, a.Status, nullString(a.Error))
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	log.Printf("Scheduled activation %d of service %s environment %s version %d: %s %s", a.ID, a.Service, a.Environment, a.Version, a.Status, a.Error)
+	return true, nil
+}
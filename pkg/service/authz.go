@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// authorizingService checks the grants of the caller before passing a request on:
+// reading needs the reader role, creating versions and switching the active one
+// needs writer, deleting versions and managing the schema needs admin. Revealing
+// secrets additionally needs the reveal role, for inherited secrets also for the
+// services they come from. Admin identities bypass the checks.
+type authorizingService struct {
+	next   ConfigService
+	grants *auth.GrantStore
+}
+
+func NewAuthorizingService(next ConfigService, grants *auth.GrantStore) ConfigService {
+	return authorizingService{next: next, grants: grants}
+}
+
+func (s authorizingService) authorize(ctx context.Context, service string, role auth.Role) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return Models.ResponseError{ErrorDescr: "Authentication required", Status: http.StatusUnauthorized}
+	}
+	if id.Admin {
+		return nil
+	}
+	allowed, err := s.grants.Authorize(id.Principal(), service, role)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Access denied: %s role required for service %s", role, service), Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+// SetConfig additionally needs the reader role for the parent, whose data
+// becomes readable through the config.
+func (s authorizingService) SetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if err := s.authorize(ctx, r.Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	if len(r.Parent) > 0 {
+		if err := s.authorize(ctx, r.Parent, auth.RoleReader); err != nil {
+			return nil, err
+		}
+	}
+	return s.next.SetConfig(ctx, req)
+}
+
+// authorizeInheritedReveal checks the reveal role for every service a config
+// inherits values from, in the variant served to clientID.
+func (s authorizingService) authorizeInheritedReveal(ctx context.Context, service, env string, version int, clientID string) error {
+	if id, ok := auth.FromContext(ctx); ok && id.Admin {
+		return nil
+	}
+	cfg, err := s.next.GetConfig(ctx, &Models.ConfigRequest{Service: service, Environment: env, Version: version, ClientID: clientID})
+	if err != nil {
+		return err
+	}
+	checked := map[string]bool{service: true}
+	for _, layer := range cfg.Provenance {
+		if checked[layer] {
+			continue
+		}
+		checked[layer] = true
+		if err := s.authorize(ctx, layer, auth.RoleReveal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeReferences checks the reader role for every service placeholders
+// took values from.
+func (s authorizingService) authorizeReferences(ctx context.Context, services []string) error {
+	for _, service := range services {
+		if err := s.authorize(ctx, service, auth.RoleReader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetConfig additionally needs the reader role for the services placeholders
+// in the config refer to, their values are only returned to those who can read them.
+func (s authorizingService) GetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if err := s.authorize(ctx, r.Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if r.Reveal {
+		if err := s.authorize(ctx, r.Service, auth.RoleReveal); err != nil {
+			return nil, err
+		}
+		if !r.Raw {
+			if err := s.authorizeInheritedReveal(ctx, r.Service, r.Environment, r.Version, r.ClientID); err != nil {
+				return nil, err
+			}
+		}
+		ctx = auth.WithRevealPermission(ctx)
+	}
+	cfg, err := s.next.GetConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeReferences(ctx, cfg.References); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// GetKey additionally needs the reader role for the services placeholders in
+// the config refer to, checked by reading the config first.
+func (s authorizingService) GetKey(ctx context.Context, req interface{}) (*Models.KeyRequest, error) {
+	r := req.(*Models.KeyRequest)
+	if err := s.authorize(ctx, r.Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if id, _ := auth.FromContext(ctx); !id.Admin {
+		cfg, err := s.next.GetConfig(ctx, &Models.ConfigRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, ClientID: r.ClientID})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.authorizeReferences(ctx, cfg.References); err != nil {
+			return nil, err
+		}
+	}
+	if r.Reveal {
+		if err := s.authorize(ctx, r.Service, auth.RoleReveal); err != nil {
+			return nil, err
+		}
+		if err := s.authorizeInheritedReveal(ctx, r.Service, r.Environment, r.Version, r.ClientID); err != nil {
+			return nil, err
+		}
+		ctx = auth.WithRevealPermission(ctx)
+	}
+	return s.next.GetKey(ctx, req)
+}
+
+func (s authorizingService) UpdConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.UpdConfig(ctx, req)
+}
+
+func (s authorizingService) DelConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.next.DelConfig(ctx, req)
+}
+
+func (s authorizingService) ListDeleted(ctx context.Context, req interface{}) ([]Models.DeletedVersion, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListDeleted(ctx, req)
+}
+
+func (s authorizingService) RestoreConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.RestoreConfig(ctx, req)
+}
+
+func (s authorizingService) PatchConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.PatchRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.PatchConfig(ctx, req)
+}
+
+func (s authorizingService) ValidateConfig(ctx context.Context, req interface{}) (*Models.ValidationResult, error) {
+	r := req.(*Models.ConfigRequest)
+	if err := s.authorize(ctx, r.Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if len(r.Parent) > 0 {
+		if err := s.authorize(ctx, r.Parent, auth.RoleReader); err != nil {
+			return nil, err
+		}
+	}
+	return s.next.ValidateConfig(ctx, req)
+}
+
+// SearchConfigs returns only the services the caller can read.
+func (s authorizingService) SearchConfigs(ctx context.Context, req interface{}) ([]Models.SearchResult, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, Models.ResponseError{ErrorDescr: "Authentication required", Status: http.StatusUnauthorized}
+	}
+	results, err := s.next.SearchConfigs(ctx, req)
+	if err != nil || id.Admin {
+		return results, err
+	}
+	allowed := make([]Models.SearchResult, 0, len(results))
+	for _, res := range results {
+		ok, err := s.grants.Authorize(id.Principal(), res.Service, auth.RoleReader)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			allowed = append(allowed, res)
+		}
+	}
+	return allowed, nil
+}
+
+func (s authorizingService) ListEnvironments(ctx context.Context, req interface{}) ([]Models.Environment, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListEnvironments(ctx, req)
+}
+
+func (s authorizingService) CopyConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.CopyRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.CopyConfig(ctx, req)
+}
+
+// PromoteConfig needs the reader role for the source and writer for the target.
+// Promoted secrets become readable to those who can reveal the target, so a
+// caller who can reveal it must be able to reveal the source as well.
+func (s authorizingService) PromoteConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.PromoteRequest)
+	if err := s.authorize(ctx, r.Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, r.Target, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	if id, _ := auth.FromContext(ctx); !id.Admin {
+		canReveal, err := s.grants.Authorize(id.Principal(), r.Target, auth.RoleReveal)
+		if err != nil {
+			return nil, err
+		}
+		if canReveal {
+			if err := s.authorize(ctx, r.Service, auth.RoleReveal); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.next.PromoteConfig(ctx, req)
+}
+
+func (s authorizingService) ListPromotions(ctx context.Context, req interface{}) ([]Models.Promotion, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListPromotions(ctx, req)
+}
+
+func (s authorizingService) ListActivations(ctx context.Context, req interface{}) ([]Models.Activation, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListActivations(ctx, req)
+}
+
+func (s authorizingService) CancelActivation(ctx context.Context, req interface{}) (*Models.Activation, error) {
+	if err := s.authorize(ctx, req.(*Models.Activation).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.CancelActivation(ctx, req)
+}
+
+func (s authorizingService) ListGuards(ctx context.Context, req interface{}) ([]Models.Guard, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListGuards(ctx, req)
+}
+
+func (s authorizingService) ReportHealth(ctx context.Context, req interface{}) (*Models.Guard, error) {
+	if err := s.authorize(ctx, req.(*Models.HealthReport).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.ReportHealth(ctx, req)
+}
+
+func (s authorizingService) SetFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	if err := s.authorize(ctx, req.(*Models.Flag).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.SetFlag(ctx, req)
+}
+
+func (s authorizingService) ListFlags(ctx context.Context, req interface{}) ([]Models.Flag, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListFlags(ctx, req)
+}
+
+func (s authorizingService) DelFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	if err := s.authorize(ctx, req.(*Models.Flag).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.DelFlag(ctx, req)
+}
+
+func (s authorizingService) EvaluateFlags(ctx context.Context, req interface{}) ([]flags.Result, error) {
+	if err := s.authorize(ctx, req.(*Models.FlagsRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.EvaluateFlags(ctx, req)
+}
+
+func (s authorizingService) SetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	if err := s.authorize(ctx, req.(*Models.Canary).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.SetCanary(ctx, req)
+}
+
+func (s authorizingService) GetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.GetCanary(ctx, req)
+}
+
+func (s authorizingService) PromoteCanary(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.PromoteCanary(ctx, req)
+}
+
+func (s authorizingService) AbortCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.AbortCanary(ctx, req)
+}
+
+func (s authorizingService) PinConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.PinConfig(ctx, req)
+}
+
+func (s authorizingService) SetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	if err := s.authorize(ctx, req.(*Models.RetentionPolicy).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.SetRetention(ctx, req)
+}
+
+func (s authorizingService) GetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.GetRetention(ctx, req)
+}
+
+func (s authorizingService) DelRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleWriter); err != nil {
+		return nil, err
+	}
+	return s.next.DelRetention(ctx, req)
+}
+
+func (s authorizingService) RetentionReport(ctx context.Context, req interface{}) ([]Models.ExpiredVersion, error) {
+	if err := s.authorize(ctx, req.(*Models.ConfigRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.RetentionReport(ctx, req)
+}
+
+func (s authorizingService) SetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.SchemaRequest).Service, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.next.SetSchema(ctx, req)
+}
+
+func (s authorizingService) GetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.SchemaRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.GetSchema(ctx, req)
+}
+
+func (s authorizingService) DelSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.SchemaRequest).Service, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.next.DelSchema(ctx, req)
+}
+
+func (s authorizingService) ListSchemas(ctx context.Context, req interface{}) ([]Models.SchemaRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.SchemaRequest).Service, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	return s.next.ListSchemas(ctx, req)
+}
+
+func (s authorizingService) SetCompatibility(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	if err := s.authorize(ctx, req.(*Models.SchemaRequest).Service, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.next.SetCompatibility(ctx, req)
+}
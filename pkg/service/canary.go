@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// SetCanary makes a version the canary of the service in the environment,
+// served instead of the used version to Percentage percent of the clients.
+// A canary set before is replaced, clients keep their buckets so raising the
+// percentage only adds clients.
+func (svc configService) SetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	r := req.(*Models.Canary)
+	r.Environment = environment(r.Environment)
+	if r.Version == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "version parameter must be specified", Status: http.StatusBadRequest}
+	}
+	if r.Percentage < 0 || r.Percentage > 100 {
+		return nil, Models.ResponseError{ErrorDescr: "percentage must be between 0 and 100", Status: http.StatusBadRequest}
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	var used, hasUsed bool
+	err = tx.QueryRowContext(ctx, `select used, exists (select 1 from configs where service = $1 and environment = $2 and used = true)
+		from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null`, r.Service, r.Environment, r.Version).Scan(&used, &hasUsed)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if used {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Version %d is already used", r.Version), Status: http.StatusConflict}
+	}
+	if !hasUsed {
+		return nil, Models.ResponseError{ErrorDescr: "No used version, a canary is served alongside it", Status: http.StatusConflict}
+	}
+
+	r.CreatedBy = auth.Subject(ctx)
+	err = tx.QueryRowContext(ctx, `insert into canaries (service, environment, version, percentage, created_by) values ($1, $2, $3, $4, $5)
+		on conflict (service, environment) do update set version = excluded.version, percentage = excluded.percentage,
+		created_by = excluded.created_by, created_at = now() returning created_at`,
+		r.Service, r.Environment, r.Version, r.Percentage, nullString(r.CreatedBy)).Scan(&r.CreatedAt)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// GetCanary returns the canary of the service in the environment.
+func (svc configService) GetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	r := req.(*Models.ConfigRequest)
+	c := Models.Canary{Service: r.Service, Environment: environment(r.Environment)}
+	err := svc.DB.QueryRowContext(ctx, "select version, percentage, coalesce(created_by, ''), created_at from canaries where service = $1 and environment = $2",
+		c.Service, c.Environment).Scan(&c.Version, &c.Percentage, &c.CreatedBy, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, noCanary(c.Service, c.Environment)
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &c, nil
+}
+
+// PromoteCanary makes the canary version the used one for all clients.
+func (svc configService) PromoteCanary(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	r.Environment = environment(r.Environment)
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	err = tx.QueryRowContext(ctx, "delete from canaries where service = $1 and environment = $2 returning version", r.Service, r.Environment).Scan(&r.Version)
+	if err == sql.ErrNoRows {
+		return nil, noCanary(r.Service, r.Environment)
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, "select exists (select 1 from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null)",
+		r.Service, r.Environment, r.Version).Scan(&exists)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if !exists {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Canary version %d no longer exists, abort the canary", r.Version), Status: http.StatusConflict}
+	}
+	_, err = tx.ExecContext(ctx, "update configs set used = (version = $3) where service = $1 and environment = $2 and (used = true or version = $3) and deleted_at is null", r.Service, r.Environment, r.Version)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	r.Used = true
+	return r, nil
+}
+
+// AbortCanary stops serving the canary, all clients get the used version again.
+func (svc configService) AbortCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	r := req.(*Models.ConfigRequest)
+	c := Models.Canary{Service: r.Service, Environment: environment(r.Environment)}
+	err := svc.DB.QueryRowContext(ctx, "delete from canaries where service = $1 and environment = $2 returning version, percentage, coalesce(created_by, ''), created_at",
+		c.Service, c.Environment).Scan(&c.Version, &c.Percentage, &c.CreatedBy, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, noCanary(c.Service, c.Environment)
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &c, nil
+}
+
+// canaryVersion returns the canary version the client gets instead of the
+// used one, or 0 if it gets the used one. A canary whose version was deleted
+// or became the used one is not served.
+func (svc configService) canaryVersion(service, env, clientID string) (int, error) {
+	if len(clientID) == 0 {
+		return 0, nil
+	}
+	var version int
+	var percentage float64
+	err := svc.DB.QueryRow(`select c.version, c.percentage from canaries c
+		join configs v on v.service = c.service and v.environment = c.environment and v.version = c.version and v.used = false and v.deleted_at is null
+		where c.service = $1 and c.environment = $2`, service, env).Scan(&version, &percentage)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if inCanary(service, clientID, percentage) {
+		return version, nil
+	}
+	return 0, nil
+}
+
+// inCanary tells whether the client is among the percentage of the clients of
+// the service that get the canary. A client always gets the same variant while
+// the percentage stays, and keeps the canary when it grows.
+func inCanary(service, clientID string, percentage float64) bool {
+	return len(clientID) > 0 && flags.Bucket(service, clientID) < percentage
+}
+
+func noCanary(service, env string) error {
+	return Models.ResponseError{ErrorDescr: fmt.Sprintf("No canary of service %s in environment %s", service, env), Status: http.StatusNotFound}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestInCanary(t *testing.T) {
+	tests := []struct {
+		percentage float64
+		min, max   int
+	}{
+		{0, 0, 0},
+		{1, 5, 20},
+		{10, 80, 120},
+		{50, 450, 550},
+		{100, 1000, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.percentage), func(t *testing.T) {
+			n := 0
+			for i := 0; i < 1000; i++ {
+				if inCanary("payments", fmt.Sprintf("client-%d", i), tt.percentage) {
+					n++
+				}
+			}
+			require.GreaterOrEqual(t, n, tt.min)
+			require.LessOrEqual(t, n, tt.max)
+		})
+	}
+}
+
+func TestInCanarySticky(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		in := inCanary("payments", client, 25)
+		// The same client gets the same variant every time, and clients in
+		// the canary stay in it when the percentage grows.
+		require.Equal(t, in, inCanary("payments", client, 25), client)
+		if in {
+			require.True(t, inCanary("payments", client, 60), client)
+		}
+	}
+
+	// Buckets of the services are independent, so the same clients don't get
+	// the canaries of all services.
+	same := 0
+	for i := 0; i < 1000; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if inCanary("payments", client, 50) == inCanary("billing", client, 50) {
+			same++
+		}
+	}
+	require.Less(t, same, 600)
+}
+
+func TestCanaryVersionWithoutClientID(t *testing.T) {
+	// Clients without an ID always get the used version, the canary isn't
+	// even looked up.
+	require.False(t, inCanary("payments", "", 100))
+	version, err := configService{}.canaryVersion("payments", "prod", "")
+	require.NoError(t, err)
+	require.Zero(t, version)
+}
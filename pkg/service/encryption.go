@@ -0,0 +1,168 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/encryption"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
+	"time"
+)
+
+// payload is a config payload as stored in the configs table: plain JSON in
+// data, or an envelope in encrypted_data, data_key and key_id. secrets_key_id
+// records the key the secrets inside the payload were sealed with. search_data
+// holds the payload as a jsonb object for SearchConfigs, with its secrets
+// redacted, also when the payload itself is encrypted.
+type payload struct {
+	Data          []byte
+	EncryptedData []byte
+	DataKey       []byte
+	KeyID         sql.NullString
+	SecretsKeyID  sql.NullString
+	SearchData    []byte
+	// Size is the length of the JSON data before encryption, limited by tenant quotas.
+	Size int
+}
+
+// args returns the values for the data, encrypted_data, data_key, key_id, secrets_key_id and search_data columns.
+func (p *payload) args() []interface{} {
+	return []interface{}{nullBytes(p.Data), nullBytes(p.EncryptedData), nullBytes(p.DataKey), p.KeyID, p.SecretsKeyID, nullBytes(p.SearchData)}
+}
+
+func nullBytes(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// sealPayload encrypts the JSON payload when a keyring is configured.
+func (svc configService) sealPayload(json []byte) (*payload, error) {
+	search, err := svc.searchData(json)
+	if err != nil {
+		return nil, err
+	}
+	if svc.Keys == nil {
+		return &payload{Data: json, SearchData: search, Size: len(json)}, nil
+	}
+	e, err := svc.Keys.Encrypt(json)
+	if err != nil {
+		return nil, err
+	}
+	return &payload{EncryptedData: e.Ciphertext, DataKey: e.DataKey, KeyID: sql.NullString{String: e.KeyID, Valid: true},
+		SearchData: search, Size: len(json)}, nil
+}
+
+// openPayload returns the JSON payload, decrypting it if needed.
+func (svc configService) openPayload(p *payload) ([]byte, error) {
+	if !p.KeyID.Valid {
+		return p.Data, nil
+	}
+	if svc.Keys == nil {
+		return nil, fmt.Errorf("config is encrypted with key %q but encryption is not configured", p.KeyID.String)
+	}
+	return svc.Keys.Decrypt(&encryption.Envelope{KeyID: p.KeyID.String, DataKey: p.DataKey, Ciphertext: p.EncryptedData})
+}
+
+// StartKeyRotation periodically reloads the key file and re-encrypts, in the
+// background, every stored version that is still plain, whose payload or
+// secrets are sealed with a key other than the active one, or that was
+// encrypted before search_data was kept for encrypted payloads.
+func StartKeyRotation(s *configService, interval time.Duration) {
+	go func() {
+		for {
+			if changed, err := s.Keys.Reload(); err != nil {
+				log.Printf("Can't reload encryption keys: %v", err)
+			} else if changed {
+				log.Printf("Encryption keys reloaded, active key %q", s.Keys.ActiveKeyID())
+			}
+			n, err := s.rotateKeys()
+			if err != nil {
+				log.Printf("Key rotation failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Key rotation: %d config versions re-encrypted with key %q", n, s.Keys.ActiveKeyID())
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+const rotationBatchSize = 100
+
+func (svc configService) resealPayloadSecrets(data []byte) ([]byte, error) {
+	var doc Models.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := svc.resealSecrets(doc.Members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// rotatePayload re-encrypts p, and its secrets if it has any, with the active key.
+func (svc configService) rotatePayload(p *payload) (*payload, error) {
+	data, err := svc.openPayload(p)
+	if err == nil && p.SecretsKeyID.Valid {
+		data, err = svc.resealPayloadSecrets(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := svc.sealPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.SecretsKeyID.Valid {
+		sealed.SecretsKeyID = sql.NullString{String: svc.Keys.ActiveKeyID(), Valid: true}
+	}
+	return sealed, nil
+}
+
+func (svc configService) rotateKeys() (int, error) {
+	active := svc.Keys.ActiveKeyID()
+	var lastID int64
+	var total int
+	for {
+		rows, err := svc.DB.Query(`select id, data, encrypted_data, data_key, key_id, secrets_key_id from configs
+			where (key_id is null or key_id <> $1 or secrets_key_id <> $1 or search_data is null) and id > $2 order by id limit $3`, active, lastID, rotationBatchSize)
+		if err != nil {
+			return total, err
+		}
+		ids := make([]int64, 0, rotationBatchSize)
+		payloads := make([]*payload, 0, rotationBatchSize)
+		for rows.Next() {
+			var id int64
+			var p payload
+			err := rows.Scan(&id, &p.Data, &p.EncryptedData, &p.DataKey, &p.KeyID, &p.SecretsKeyID)
+			if err != nil {
+				rows.Close()
+				return total, err
+			}
+			ids = append(ids, id)
+			payloads = append(payloads, &p)
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		for i, id := range ids {
+			lastID = id
+			sealed, err := svc.rotatePayload(payloads[i])
+			if err != nil {
+				log.Printf("Key rotation: can't re-encrypt config %d: %v", id, err)
+				continue
+			}
+			args := append(sealed.args(), id, payloads[i].KeyID, payloads[i].SecretsKeyID)
+			_, err = svc.DB.Exec(`update configs set data = $1, encrypted_data = $2, data_key = $3, key_id = $4, secrets_key_id = $5, search_data = $6
+				where id = $7 and key_id is not distinct from $8 and secrets_key_id is not distinct from $9`, args...)
+			if err != nil {
+				return total, err
+			}
+			total++
+		}
+	}
+}
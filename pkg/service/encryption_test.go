@@ -0,0 +1,149 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/tonx22/gocloudcamp/pkg/encryption"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKeyring returns a keyring whose active key is active, holding the other
+// keys as well. Keys with the same ID are the same in every keyring.
+func testKeyring(t *testing.T, active string, others ...string) *encryption.Keyring {
+	keys := make(map[string]string)
+	for _, id := range append([]string{active}, others...) {
+		key := sha256.Sum256([]byte(id))
+		keys[id] = base64.StdEncoding.EncodeToString(key[:])
+	}
+	b, err := json.Marshal(map[string]interface{}{"active": active, "keys": keys})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(file, b, 0600))
+	k, err := encryption.LoadKeyring(file)
+	require.NoError(t, err)
+	return k
+}
+
+func TestPayload(t *testing.T) {
+	data := []byte(`{"host":"h","port":5432}`)
+
+	plain, err := configService{}.sealPayload(data)
+	require.NoError(t, err)
+	require.False(t, plain.KeyID.Valid)
+	require.Equal(t, data, plain.Data)
+	require.Equal(t, data, plain.SearchData)
+	require.Equal(t, len(data), plain.Size)
+
+	array, err := configService{}.sealPayload([]byte(`[{"host":"h"},{"port":5432}]`))
+	require.NoError(t, err)
+	require.Equal(t, data, array.SearchData)
+
+	svc := configService{Keys: testKeyring(t, "k1")}
+	sealed, err := svc.sealPayload(data)
+	require.NoError(t, err)
+	require.Nil(t, sealed.Data)
+	require.Equal(t, "k1", sealed.KeyID.String)
+	require.Equal(t, data, sealed.SearchData)
+	require.Equal(t, len(data), sealed.Size)
+	opened, err := svc.openPayload(sealed)
+	require.NoError(t, err)
+	require.Equal(t, data, opened)
+
+	_, err = configService{}.openPayload(sealed)
+	require.EqualError(t, err, `config is encrypted with key "k1" but encryption is not configured`)
+}
+
+// TestEncryptedSearchData checks that encrypted payloads stay searchable by
+// their plain values but never by their secrets.
+func TestEncryptedSearchData(t *testing.T) {
+	old := configService{Keys: testKeyring(t, "k1")}
+	data, err := Models.ParseValue([]byte(`{"db":{"host":"pg-01","password":{"$secret":"qwerty"}},"hosts":[{"$secret":"h"}]}`))
+	require.NoError(t, err)
+	sealedData, _, err := old.sealSecrets(data, "data", nil)
+	require.NoError(t, err)
+	b, err := json.Marshal(sealedData)
+	require.NoError(t, err)
+	want := `{"db":{"host":"pg-01","password":{"$secret":"******"}},"hosts":[{"$secret":"******"}]}`
+
+	p, err := old.sealPayload(b)
+	require.NoError(t, err)
+	require.True(t, p.KeyID.Valid)
+	require.Equal(t, want, string(p.SearchData))
+
+	// Payloads encrypted before search_data was kept for them, secrets sealed
+	// with a retired key, get it back when they are rotated.
+	p.SearchData = nil
+	p.SecretsKeyID.String, p.SecretsKeyID.Valid = "k1", true
+	svc := configService{Keys: testKeyring(t, "k2", "k1")}
+	rotated, err := svc.rotatePayload(p)
+	require.NoError(t, err)
+	require.Equal(t, "k2", rotated.KeyID.String)
+	require.Equal(t, "k2", rotated.SecretsKeyID.String)
+	require.Equal(t, want, string(rotated.SearchData))
+	opened, err := svc.openPayload(rotated)
+	require.NoError(t, err)
+	require.Contains(t, string(opened), `"$encrypted":"k2:`)
+	require.NotContains(t, string(opened), "k1:")
+
+	// Plain payloads are encrypted and keep their search data.
+	plain, err := configService{}.sealPayload([]byte(`{"db":{"host":"pg-01"}}`))
+	require.NoError(t, err)
+	rotated, err = svc.rotatePayload(plain)
+	require.NoError(t, err)
+	require.True(t, rotated.KeyID.Valid)
+	require.Nil(t, rotated.Data)
+	require.Equal(t, `{"db":{"host":"pg-01"}}`, string(rotated.SearchData))
+}
+
+func TestSecretEncryption(t *testing.T) {
+	for _, active := range []string{"k1", "2023:01"} {
+		t.Run(active, func(t *testing.T) {
+			svc := configService{Keys: testKeyring(t, active)}
+			secret, err := Models.ParseValue([]byte(`{"user":"u","password":"p"}`))
+			require.NoError(t, err)
+			enc, err := svc.encryptSecret(secret)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(enc, active+":"))
+			got, err := svc.decryptSecret(enc)
+			require.NoError(t, err)
+			require.Equal(t, secret, got)
+		})
+	}
+
+	svc := configService{Keys: testKeyring(t, "k1")}
+	enc, err := svc.encryptSecret("p")
+	require.NoError(t, err)
+	parts := strings.Split(enc, ":")
+	ct, err := base64.StdEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	ct[len(ct)-1] ^= 1
+
+	tests := []struct {
+		name string
+		svc  configService
+		enc  interface{}
+		err  string
+	}{
+		{"not a string", svc, json.Number("1"), "malformed encrypted secret"},
+		{"one part", svc, "abc", "malformed encrypted secret"},
+		{"two parts", svc, "k1:" + parts[2], "malformed encrypted secret"},
+		{"data key not base64", svc, "k1:!!:" + parts[2], "malformed encrypted secret"},
+		{"ciphertext not base64", svc, "k1:" + parts[1] + ":!!", "malformed encrypted secret"},
+		{"tampered ciphertext", svc, "k1:" + parts[1] + ":" + base64.StdEncoding.EncodeToString(ct), "message authentication failed"},
+		{"other key ID", svc, "k2:" + parts[1] + ":" + parts[2], `encryption key "k2" is not available`},
+		{"encryption not configured", configService{}, enc, "encryption is not configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.svc.decryptSecret(tt.enc)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// ListEnvironments returns the environments the service has configs in.
+func (svc configService) ListEnvironments(_ context.Context, req interface{}) ([]Models.Environment, error) {
+	r := req.(*Models.ConfigRequest)
+	rows, err := svc.DB.Query("select environment, coalesce(max(version) filter (where used), 0), count(*) from configs where service = $1 and deleted_at is null group by environment order by environment", r.Service)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.Environment, 0)
+	for rows.Next() {
+		e := Models.Environment{Service: r.Service}
+		err := rows.Scan(&e.Environment, &e.Version, &e.Versions)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// CopyConfig stores a version of a config as the new used version of another
+// environment of the service, see PromoteConfig.
+func (svc configService) CopyConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.CopyRequest)
+	if len(r.To) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "target environment must be specified", Status: http.StatusBadRequest}
+	}
+	return svc.promote(ctx, &Models.PromoteRequest{Service: r.Service, Environment: r.From, Version: r.Version, Target: r.Service, TargetEnvironment: r.To, Activate: true})
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// SetFlag creates the flag of the service in the environment or replaces its
+// value and rules.
+func (svc configService) SetFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	r := req.(*Models.Flag)
+	r.Environment = environment(r.Environment)
+	if !validEnvironment.MatchString(r.Environment) {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid environment %q, must be letters, digits, '.', '_' and '-' up to 255 characters", r.Environment), Status: http.StatusBadRequest}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error(), Status: http.StatusBadRequest}
+	}
+	if r.Rules == nil {
+		r.Rules = []flags.Rule{}
+	}
+	rules, err := json.Marshal(r.Rules)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	r.UpdatedBy = auth.Subject(ctx)
+	err = svc.DB.QueryRowContext(ctx, `insert into flags (service, environment, name, enabled, default_value, rules, updated_by) values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (service, environment, name) do update set enabled = excluded.enabled, default_value = excluded.default_value,
+		rules = excluded.rules, updated_by = excluded.updated_by, updated_at = now() returning updated_at`,
+		r.Service, r.Environment, r.Name, r.Enabled, string(r.Default), string(rules), nullString(r.UpdatedBy)).Scan(&r.UpdatedAt)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// ListFlags returns the flags of the service in the environment by name. The
+// Go client evaluates them locally from this list.
+func (svc configService) ListFlags(ctx context.Context, req interface{}) ([]Models.Flag, error) {
+	r := req.(*Models.ConfigRequest)
+	list, err := svc.loadFlags(ctx, r.Service, environment(r.Environment))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return list, nil
+}
+
+// DelFlag deletes the flag of the service in the environment.
+func (svc configService) DelFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	r := req.(*Models.Flag)
+	r.Environment = environment(r.Environment)
+	res, err := svc.DB.ExecContext(ctx, "delete from flags where service = $1 and environment = $2 and name = $3", r.Service, r.Environment, r.Name)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("No flag %s of service %s in environment %s", r.Name, r.Service, r.Environment), Status: http.StatusNotFound}
+	}
+	return r, nil
+}
+
+// EvaluateFlags returns the values of the flags of the service for the
+// client described by the request.
+func (svc configService) EvaluateFlags(ctx context.Context, req interface{}) ([]flags.Result, error) {
+	r := req.(*Models.FlagsRequest)
+	list, err := svc.loadFlags(ctx, r.Service, environment(r.Environment))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	ruleset := make([]flags.Flag, len(list))
+	for i, f := range list {
+		ruleset[i] = f.Flag
+	}
+	return flags.EvaluateAll(ruleset, r.Context, r.Flags), nil
+}
+
+func (svc configService) loadFlags(ctx context.Context, service, env string) ([]Models.Flag, error) {
+	rows, err := svc.DB.QueryContext(ctx, `select name, enabled, default_value, rules, coalesce(updated_by, ''), updated_at
+		from flags where service = $1 and environment = $2 order by name`, service, env)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Models.Flag, 0)
+	for rows.Next() {
+		f := Models.Flag{Service: service, Environment: env}
+		var def, rules string
+		if err := rows.Scan(&f.Name, &f.Enabled, &def, &rules, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		f.Default = json.RawMessage(def)
+		if err := json.Unmarshal([]byte(rules), &f.Rules); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var healthClient = &http.Client{
+	Timeout: 5 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// guardedUpdate makes the version the used one and watches it for r.Watch,
+// remembering the version used before. A guard still watching a previous
+// activation of the service in the environment is superseded.
+func (svc configService) guardedUpdate(ctx context.Context, r *Models.ConfigRequest) (*Models.ConfigRequest, error) {
+	if !r.Used || r.Version == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "watch can only guard the activation of a version: version and used=true must be specified", Status: http.StatusBadRequest}
+	}
+	if r.ActivateAt != nil {
+		return nil, Models.ResponseError{ErrorDescr: "activate_at and watch can't be combined", Status: http.StatusBadRequest}
+	}
+	if r.Watch < 0 {
+		return nil, Models.ResponseError{ErrorDescr: "watch must be positive", Status: http.StatusBadRequest}
+	}
+	if len(r.HealthURL) > 0 {
+		if err := svc.checkHealthURL(r.HealthURL); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "select exists (select 1 from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null)", r.Service, r.Environment, r.Version).Scan(&exists)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if !exists {
+		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	}
+	var previous int
+	err = tx.QueryRowContext(ctx, "select version from configs where service = $1 and environment = $2 and used = true limit 1", r.Service, r.Environment).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "No used version to roll back to, a guarded activation needs one", Status: http.StatusConflict}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if previous == r.Version {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Version %d is already used", r.Version), Status: http.StatusConflict}
+	}
+
+	_, err = tx.ExecContext(ctx, "update configs set used = (version = $3) where service = $1 and environment = $2 and (used = true or version = $3) and deleted_at is null", r.Service, r.Environment, r.Version)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	_, err = tx.ExecContext(ctx, "update guards set status = $3, done_at = now() where service = $1 and environment = $2 and status = $4",
+		r.Service, r.Environment, Models.GuardSuperseded, Models.GuardWatching)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	_, err = tx.ExecContext(ctx, `insert into guards (service, environment, version, previous_version, health_url, watch_until, created_by)
+		values ($1, $2, $3, $4, $5, $6, $7)`, r.Service, r.Environment, r.Version, previous, nullString(r.HealthURL), time.Now().Add(r.Watch), auth.Subject(ctx))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// checkHealthURL accepts http and https URLs of the hosts listed in HealthCheckHosts,
+// the server must not be made to reach anything else.
+func (svc configService) checkHealthURL(healthURL string) error {
+	if len(svc.HealthCheckHosts) == 0 {
+		return Models.ResponseError{ErrorDescr: "Health checks are disabled, no hosts are allowed for them", Status: http.StatusBadRequest}
+	}
+	u, err := url.Parse(healthURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return Models.ResponseError{ErrorDescr: "health_url incorrect, must be an http or https URL", Status: http.StatusBadRequest}
+	}
+	for _, host := range svc.HealthCheckHosts {
+		if u.Hostname() == host {
+			return nil
+		}
+	}
+	return Models.ResponseError{ErrorDescr: fmt.Sprintf("Health checks may not reach host %s", u.Hostname()), Status: http.StatusBadRequest}
+}
+
+// ReportHealth ends the guard watching the service in the environment: a
+// healthy report lets the activation pass, a failure rolls it back.
+func (svc configService) ReportHealth(ctx context.Context, req interface{}) (*Models.Guard, error) {
+	r := req.(*Models.HealthReport)
+	env := environment(r.Environment)
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+
+	g, err := loadGuard(ctx, tx, "service = $1 and environment = $2 and status = $3 for update", r.Service, env, Models.GuardWatching)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if g == nil {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("No guarded activation of service %s in environment %s is watched", r.Service, env), Status: http.StatusNotFound}
+	}
+	reason := "reported by " + auth.Subject(ctx)
+	if len(r.Reason) > 0 {
+		reason += ": " + r.Reason
+	}
+	if r.Healthy {
+		err = g.finish(ctx, tx, Models.GuardPassed, reason)
+	} else {
+		err = g.rollback(ctx, tx, reason)
+	}
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	log.Printf("Guarded activation %d of service %s environment %s version %d: %s, %s", g.ID, g.Service, g.Environment, g.Version, g.Status, g.Reason)
+	return (*Models.Guard)(g), nil
+}
+
+// ListGuards returns the guarded activations of the service in the
+// environment, the latest first.
+func (svc configService) ListGuards(ctx context.Context, req interface{}) ([]Models.Guard, error) {
+	r := req.(*Models.ConfigRequest)
+	rows, err := svc.DB.QueryContext(ctx, "select "+guardColumns+" from guards where service = $1 and environment = $2 order by id desc", r.Service, environment(r.Environment))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.Guard, 0)
+	for rows.Next() {
+		var g guard
+		if err := g.scan(rows); err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		list = append(list, Models.Guard(g))
+	}
+	return list, nil
+}
+
+// watchGuards checks the guards still watching: those whose window is over
+// pass, those whose health check fails HealthCheckFailures times in a row are
+// rolled back.
+func (svc configService) watchGuards() error {
+	rows, err := svc.DB.Query("select id from guards where status = $1", Models.GuardWatching)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := svc.checkGuard(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGuard checks one guard. Its row stays locked during the health check,
+// other instances skip it.
+func (svc configService) checkGuard(id int64) error {
+	ctx := context.Background()
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	g, err := loadGuard(ctx, tx, "id = $1 and status = $2 for update skip locked", id, Models.GuardWatching)
+	if err != nil || g == nil {
+		return err
+	}
+	if time.Now().After(g.WatchUntil) {
+		err = g.finish(ctx, tx, Models.GuardPassed, "watch window ended")
+	} else if len(g.HealthURL) > 0 {
+		failure := checkHealth(g.HealthURL)
+		if len(failure) == 0 {
+			_, err = tx.ExecContext(ctx, "update guards set failures = 0 where id = $1", g.ID)
+		} else {
+			var failures int
+			err = tx.QueryRowContext(ctx, "update guards set failures = failures + 1 where id = $1 returning failures", g.ID).Scan(&failures)
+			if err == nil && failures >= svc.healthCheckFailures() {
+				err = g.rollback(ctx, tx, failure)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if g.Status != Models.GuardWatching {
+		log.Printf("Guarded activation %d of service %s environment %s version %d: %s, %s", g.ID, g.Service, g.Environment, g.Version, g.Status, g.Reason)
+	}
+	return nil
+}
+
+func (svc configService) healthCheckFailures() int {
+	if svc.HealthCheckFailures < 1 {
+		return 1
+	}
+	return svc.HealthCheckFailures
+}
+
+// checkHealth returns why the health check at healthURL failed, or "" if it passed.
+func checkHealth(healthURL string) string {
+	resp, err := healthClient.Get(healthURL)
+	if err != nil {
+		return "health check failed: " + err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "health check returned " + resp.Status
+	}
+	return ""
+}
+
+type guard Models.Guard
+
+const guardColumns = "id, service, environment, version, previous_version, coalesce(health_url, ''), watch_until, status, coalesce(reason, ''), coalesce(created_by, ''), created_at, done_at"
+
+func (g *guard) scan(row interface{ Scan(...interface{}) error }) error {
+	return row.Scan(&g.ID, &g.Service, &g.Environment, &g.Version, &g.PreviousVersion, &g.HealthURL, &g.WatchUntil, &g.Status, &g.Reason, &g.CreatedBy, &g.CreatedAt, &g.DoneAt)
+}
+
+// loadGuard returns the guard matching the condition, or nil if there is none.
+func loadGuard(ctx context.Context, tx *sql.Tx, cond string, args ...interface{}) (*guard, error) {
+	var g guard
+	err := g.scan(tx.QueryRowContext(ctx, "select "+guardColumns+" from guards where "+cond, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// rollback makes the previous version the used one again, unless the guarded
+// version was replaced meanwhile. If the previous version was deleted, no
+// version is used afterwards.
+func (g *guard) rollback(ctx context.Context, tx *sql.Tx, reason string) error {
+	if err := lockVersions(ctx, tx, g.Service, g.Environment); err != nil {
+		return err
+	}
+	var used bool
+	err := tx.QueryRowContext(ctx, "select used from configs where service = $1 and environment = $2 and version = $3", g.Service, g.Environment, g.Version).Scan(&used)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if !used {
+		return g.finish(ctx, tx, Models.GuardSuperseded, "version no longer used when it failed: "+reason)
+	}
+
+	res, err := tx.ExecContext(ctx, "update configs set used = (version = $3) where service = $1 and environment = $2 and (used = true or version = $3) and deleted_at is null", g.Service, g.Environment, g.PreviousVersion)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n < 2 {
+		reason += fmt.Sprintf(", previous version %d no longer exists, no version is used", g.PreviousVersion)
+	}
+	return g.finish(ctx, tx, Models.GuardRolledBack, reason)
+}
+
+func (g *guard) finish(ctx context.Context, tx *sql.Tx, status, reason string) error {
+	g.Status, g.Reason = status, reason
+	return tx.QueryRowContext(ctx, "update guards set status = $2, reason = $3, done_at = now() where id = $1 returning done_at", g.ID, status, reason).Scan(&g.DoneAt)
+}
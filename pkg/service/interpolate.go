@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/jsonpath"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxReferences limits the depth of placeholders resolving to other placeholders.
+const maxReferences = 16
+
+// placeholder matches ${env:NAME}, ${self:path} and ${ref:service/path}, and
+// the escaped form $${...} which stands for the literal text.
+var placeholder = regexp.MustCompile(`\$?\$\{(env|self|ref):([^}]*)\}`)
+
+// interpolation resolves the placeholders of a config read in an environment.
+type interpolation struct {
+	svc configService
+	env string
+	// configs caches the effective data of the services placeholders refer to.
+	configs map[string]Models.Object
+	// stack holds the keys being resolved, one leading back to itself is a cycle.
+	stack []string
+	refs  map[string]bool
+}
+
+// interpolate returns data of service with its placeholders resolved and the
+// other services values were taken from. Strings consisting of a single
+// placeholder are replaced by the value it refers to, whatever its type,
+// placeholders within longer strings need scalar values. Secrets can't be
+// referred to, their values would end up outside of them.
+func (svc configService) interpolate(service, env string, data Models.Object) (Models.Object, []string, error) {
+	in := interpolation{svc: svc, env: env, configs: map[string]Models.Object{service: data}, refs: make(map[string]bool)}
+	v, err := in.value(service, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := make([]string, 0, len(in.refs))
+	for s := range in.refs {
+		if s != service {
+			refs = append(refs, s)
+		}
+	}
+	sort.Strings(refs)
+	return v.(Models.Object), refs, nil
+}
+
+func (in *interpolation) value(service string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return in.string(service, v)
+	case Models.Object:
+		if isSecret(v) {
+			return v, nil
+		}
+		o := make(Models.Object, len(v))
+		for i, m := range v {
+			value, err := in.value(service, m.Value)
+			if err != nil {
+				return nil, err
+			}
+			o[i] = Models.Member{Key: m.Key, Value: value}
+		}
+		return o, nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			value, err := in.value(service, e)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = value
+		}
+		return a, nil
+	}
+	return v, nil
+}
+
+func (in *interpolation) string(service, s string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	matches := placeholder.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) && s[1] == '{' {
+		m := matches[0]
+		return in.resolve(service, s, s[m[2]:m[3]], s[m[4]:m[5]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		last = m[1]
+		if s[m[0]+1] == '$' {
+			b.WriteString(s[m[0]+1 : m[1]])
+			continue
+		}
+		text := s[m[0]:m[1]]
+		v, err := in.resolve(service, text, s[m[2]:m[3]], s[m[4]:m[5]])
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case nil:
+		case string:
+			b.WriteString(v)
+		case json.Number:
+			b.WriteString(v.String())
+		case bool:
+			b.WriteString(strconv.FormatBool(v))
+		default:
+			return nil, unresolvable("Placeholder %s in %s refers to an object or array, only scalars can be embedded in strings", text, service)
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// resolve returns the value the placeholder text of the given kind and
+// argument stands for in the config of service, its own placeholders resolved.
+func (in *interpolation) resolve(service, text, kind, arg string) (interface{}, error) {
+	if kind == "env" {
+		v, ok := in.svc.Environ[arg]
+		if !ok {
+			return nil, unresolvable("Placeholder %s in %s refers to an environment variable that is not available for interpolation", text, service)
+		}
+		return v, nil
+	}
+
+	target, path := service, arg
+	if kind == "ref" {
+		name, p, ok := strings.Cut(arg, "/")
+		if !ok || len(name) == 0 || len(p) == 0 {
+			return nil, unresolvable("Placeholder %s in %s must refer to service/key", text, service)
+		}
+		target, path = in.svc.sibling(service, name), p
+		in.refs[target] = true
+	}
+
+	key := target + ":" + path
+	for i, k := range in.stack {
+		if k == key {
+			return nil, unresolvable("Placeholders form a cycle: %s -> %s", strings.Join(in.stack[i:], " -> "), key)
+		}
+	}
+	if len(in.stack) == maxReferences {
+		return nil, unresolvable("Placeholders are nested deeper than %d at %s", maxReferences, key)
+	}
+
+	p, err := jsonpath.Parse(path)
+	if err != nil || !p.Definite() {
+		return nil, unresolvable("Placeholder %s in %s must refer to a single key", text, service)
+	}
+	data, err := in.config(target)
+	if err != nil {
+		return nil, err
+	}
+	values := p.Eval(data)
+	if len(values) == 0 {
+		return nil, unresolvable("Placeholder %s in %s refers to a missing key", text, service)
+	}
+	if containsSecret(values[0]) {
+		return nil, unresolvable("Placeholder %s in %s refers to a secret, secrets can't be referenced", text, service)
+	}
+
+	in.stack = append(in.stack, key)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+	return in.value(target, values[0])
+}
+
+// config returns the effective data of the used version of service.
+func (in *interpolation) config(service string) (Models.Object, error) {
+	if data, ok := in.configs[service]; ok {
+		return data, nil
+	}
+	l, err := in.svc.loadLayer(service, in.env, 0)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, unresolvable("Config %s referenced by a placeholder has no used version in environment %s", service, in.env)
+	}
+	data := l.Data.Members
+	if len(l.Parent) > 0 {
+		inherited, provenance, err := in.svc.inheritedData(service, in.env, l.Parent)
+		if err != nil {
+			return nil, err
+		}
+		data = mergeLayer(inherited, data, service, "", provenance)
+	}
+	in.configs[service] = data
+	return data, nil
+}
+
+// sibling returns the stored name of service name as seen from service: a
+// service of a tenant can only refer to services of the same tenant.
+func (svc configService) sibling(service, name string) string {
+	if tenant, _, ok := strings.Cut(service, "/"); svc.Tenancy && ok {
+		return tenant + "/" + name
+	}
+	return name
+}
+
+func containsSecret(v interface{}) bool {
+	switch v := v.(type) {
+	case Models.Object:
+		if isSecret(v) {
+			return true
+		}
+		for _, m := range v {
+			if containsSecret(m.Value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if containsSecret(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unresolvable(format string, a ...interface{}) error {
+	return Models.ResponseError{ErrorDescr: fmt.Sprintf(format, a...), Status: http.StatusUnprocessableEntity}
+}
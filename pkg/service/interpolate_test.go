@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"strings"
+	"testing"
+)
+
+// chain returns a config whose key k0 refers to k1 and so on up to kn.
+func chain(n int) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `"k%d":"${self:k%d}",`, i, i+1)
+	}
+	fmt.Fprintf(&b, `"k%d":"end"}`, n)
+	return b.String()
+}
+
+func TestInterpolate(t *testing.T) {
+	svc := configService{Environ: map[string]string{"HOST": "db.local", "EMPTY": ""}}
+	others := map[string]string{
+		"db":     `{"host":"h","port":5432,"tls":true,"url":"${ref:app/name}:${self:port}","password":{"$secret":"x"},"nested":{"p":{"$encrypted":"y"}}}`,
+		"common": `{"region":"eu","tags":["a","b"]}`,
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+		refs []string
+		err  string
+	}{
+		{"no placeholders", `{"a":"x","b":[1,{"c":null}]}`, `{"a":"x","b":[1,{"c":null}]}`, []string{}, ""},
+		{"env", `{"a":"${env:HOST}","b":"[${env:EMPTY}]"}`, `{"a":"db.local","b":"[]"}`, []string{}, ""},
+		{"env not in the allowlist", `{"a":"${env:PATH}"}`, "", nil, "environment variable that is not available"},
+		{"self keeps the type", `{"a":{"b":[1,2]},"c":"${self:a.b}","d":"${self:a.b[1]}"}`, `{"a":{"b":[1,2]},"c":[1,2],"d":2}`, []string{}, ""},
+		{"self embedded", `{"port":8080,"on":false,"url":"http://${env:HOST}:${self:port}/?tls=${self:on}"}`,
+			`{"port":8080,"on":false,"url":"http://db.local:8080/?tls=false"}`, []string{}, ""},
+		{"escaped", `{"a":"$${env:HOST}","b":"x $${self:a} y"}`, `{"a":"${env:HOST}","b":"x ${self:a} y"}`, []string{}, ""},
+		{"ref", `{"name":"app","db":"${ref:db/host}","region":"${ref:common/region}"}`,
+			`{"name":"app","db":"h","region":"eu"}`, []string{"common", "db"}, ""},
+		{"ref resolves placeholders of the target", `{"name":"svc","url":"${ref:db/url}"}`, `{"name":"svc","url":"svc:5432"}`, []string{"db"}, ""},
+		{"ref without a key", `{"a":"${ref:db}"}`, "", nil, "must refer to service/key"},
+		{"missing key", `{"a":"${self:b}"}`, "", nil, "refers to a missing key"},
+		{"indefinite path", `{"a":"${ref:common/tags[*]}"}`, "", nil, "must refer to a single key"},
+		{"object embedded in a string", `{"a":"x${ref:common/tags}"}`, "", nil, "only scalars can be embedded"},
+		{"secret", `{"a":"${ref:db/password}"}`, "", nil, "secrets can't be referenced"},
+		{"object containing a secret", `{"a":"${ref:db/nested}"}`, "", nil, "secrets can't be referenced"},
+		{"own secret", `{"p":{"$secret":"x"},"a":"${self:p}"}`, "", nil, "secrets can't be referenced"},
+		{"secrets are left alone", `{"p":{"$secret":"${env:HOST}"}}`, `{"p":{"$secret":"${env:HOST}"}}`, []string{}, ""},
+		{"cycle", `{"a":"${self:b}","b":"x${self:c}","c":"${self:a}"}`, "", nil, "Placeholders form a cycle: app:b -> app:c -> app:a -> app:b"},
+		{"self reference", `{"a":"${self:a}"}`, "", nil, "Placeholders form a cycle: app:a -> app:a"},
+		{"cycle across services", `{"name":"${ref:db/url}"}`, "", nil, "Placeholders form a cycle: db:url -> app:name -> db:url"},
+		{"maximum depth", chain(maxReferences), "", []string{}, ""},
+		{"too deep", chain(maxReferences + 1), "", nil, fmt.Sprintf("nested deeper than %d", maxReferences)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := interpolation{svc: svc, configs: make(map[string]Models.Object), refs: make(map[string]bool)}
+			for name, data := range others {
+				v, err := Models.ParseValue([]byte(data))
+				require.NoError(t, err)
+				in.configs[name] = v.(Models.Object)
+			}
+			v, err := Models.ParseValue([]byte(tt.data))
+			require.NoError(t, err)
+			in.configs["app"] = v.(Models.Object)
+
+			got, err := in.value("app", v)
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Equal(t, 422, err.(Models.ResponseError).Status)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if len(tt.want) > 0 {
+				b, err := json.Marshal(got)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(b))
+			}
+			refs := []string{}
+			for s := range in.refs {
+				if s != "app" {
+					refs = append(refs, s)
+				}
+			}
+			require.ElementsMatch(t, tt.refs, refs)
+		})
+	}
+}
+
+func TestSibling(t *testing.T) {
+	require.Equal(t, "db", configService{}.sibling("acme/app", "db"))
+	require.Equal(t, "acme/db", configService{Tenancy: true}.sibling("acme/app", "db"))
+	require.Equal(t, "db", configService{Tenancy: true}.sibling("app", "db"))
+}
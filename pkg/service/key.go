@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/jsonpath"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// GetKey returns the part of the used, or the requested, version of a config
+// addressed by a dotted path or JSONPath.
+func (svc configService) GetKey(ctx context.Context, req interface{}) (*Models.KeyRequest, error) {
+	r := req.(*Models.KeyRequest)
+	path, err := jsonpath.Parse(r.Path)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error(), Status: http.StatusBadRequest}
+	}
+
+	cfg, err := svc.GetConfig(ctx, &Models.ConfigRequest{Service: r.Service, Environment: r.Environment, Version: r.Version, Reveal: r.Reveal, ClientID: r.ClientID})
+	if err != nil {
+		return nil, err
+	}
+	r.Environment = cfg.Environment
+	r.Version = cfg.Version
+	r.Variant = cfg.Variant
+
+	values := path.Eval(cfg.Data.Members)
+	if !path.Definite() {
+		if values == nil {
+			values = []interface{}{}
+		}
+		r.Value = values
+		return r, nil
+	}
+	if len(values) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Key %s not found", r.Path), Status: http.StatusNotFound}
+	}
+	r.Value = values[0]
+	return r, nil
+}
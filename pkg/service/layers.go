@@ -0,0 +1,168 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// maxLayers limits the length of a chain of parents.
+const maxLayers = 8
+
+// layer is a stored config version with its secrets still sealed.
+type layer struct {
+	Version       int
+	Used          bool
+	SchemaVersion int
+	Parent        string
+	Data          Models.Document
+}
+
+// loadLayer returns the given version of the config of the service in the
+// environment, the used one if version is 0, or nil if there is none.
+func (svc configService) loadLayer(service, env string, version int) (*layer, error) {
+	var row *sql.Row
+	if version == 0 {
+		row = svc.DB.QueryRow("select version, used, coalesce(schema_version, 0), coalesce(parent, ''), data, encrypted_data, data_key, key_id from configs where service = $1 and environment = $2 and used = true and deleted_at is null limit 1", service, env)
+	} else {
+		row = svc.DB.QueryRow("select version, used, coalesce(schema_version, 0), coalesce(parent, ''), data, encrypted_data, data_key, key_id from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null limit 1", service, env, version)
+	}
+	var l layer
+	var p payload
+	err := row.Scan(&l.Version, &l.Used, &l.SchemaVersion, &l.Parent, &p.Data, &p.EncryptedData, &p.DataKey, &p.KeyID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	data, err := svc.openPayload(&p)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := json.Unmarshal(data, &l.Data); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &l, nil
+}
+
+// inheritedData returns the effective data of the used version of parent in
+// the environment, its own layer merged over those of its ancestors, and the
+// provenance of every value. child is the service inheriting it, a chain
+// leading back to it is an error.
+func (svc configService) inheritedData(child, env, parent string) (Models.Object, map[string]string, error) {
+	return inherit(child, env, parent, func(service string) (*layer, error) {
+		return svc.loadLayer(service, env, 0)
+	})
+}
+
+// inherit walks the chain of parents starting at parent, loading the used
+// version of each with load, and merges their layers.
+func inherit(child, env, parent string, load func(service string) (*layer, error)) (Models.Object, map[string]string, error) {
+	var chain []string
+	var layers []Models.Object
+	for service := parent; len(service) > 0; {
+		if service == child {
+			return nil, nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Config %s can't inherit from %s, the chain of parents loops back to it", child, parent), Status: http.StatusConflict}
+		}
+		for _, s := range chain {
+			if s == service {
+				return nil, nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Chain of parents of %s loops: %s -> %s", child, strings.Join(chain, " -> "), service), Status: http.StatusConflict}
+			}
+		}
+		if len(chain) == maxLayers {
+			return nil, nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Chain of parents of %s is longer than %d", child, maxLayers), Status: http.StatusConflict}
+		}
+		l, err := load(service)
+		if err != nil {
+			return nil, nil, err
+		}
+		if l == nil {
+			return nil, nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Parent config %s has no used version in environment %s", service, env), Status: http.StatusConflict}
+		}
+		chain = append(chain, service)
+		layers = append(layers, l.Data.Members)
+		service = l.Parent
+	}
+
+	data := Models.Object{}
+	provenance := make(map[string]string)
+	for i := len(layers) - 1; i >= 0; i-- {
+		data = mergeLayer(data, layers[i], chain[i], "", provenance)
+	}
+	return data, provenance, nil
+}
+
+// effectiveData returns own merged with the data inherited from parent, with
+// inherited secrets redacted, or own itself if there is no parent.
+func (svc configService) effectiveData(service, env, parent string, own Models.Object) (Models.Object, error) {
+	if len(parent) == 0 {
+		return own, nil
+	}
+	inherited, provenance, err := svc.inheritedData(service, env, parent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := svc.openSecrets(inherited, "data", false, nil); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return mergeLayer(inherited, own, service, "", provenance), nil
+}
+
+// mergeLayer merges own over base like a merge patch: objects are merged key by
+// key, other values and secrets replace the inherited ones, null removes them.
+// Inherited keys keep their place, new ones are appended. The paths of values
+// set by own are recorded in provenance as coming from service.
+func mergeLayer(base, own Models.Object, service, path string, provenance map[string]string) Models.Object {
+	merged := append(Models.Object{}, base...)
+	for _, m := range own {
+		p := layerPath(path, m.Key)
+		if m.Value == nil {
+			merged = merged.Delete(m.Key)
+			forgetProvenance(provenance, p)
+			continue
+		}
+		current, _ := merged.Get(m.Key)
+		b, bok := current.(Models.Object)
+		o, ook := m.Value.(Models.Object)
+		if bok && ook && !isSecret(b) && !isSecret(o) {
+			merged = merged.Set(m.Key, mergeLayer(b, o, service, p, provenance))
+			continue
+		}
+		merged = merged.Set(m.Key, m.Value)
+		forgetProvenance(provenance, p)
+		recordProvenance(m.Value, service, p, provenance)
+	}
+	return merged
+}
+
+func recordProvenance(v interface{}, service, path string, provenance map[string]string) {
+	if o, ok := v.(Models.Object); ok && len(o) > 0 && !isSecret(o) {
+		for _, m := range o {
+			recordProvenance(m.Value, service, layerPath(path, m.Key), provenance)
+		}
+		return
+	}
+	provenance[path] = service
+}
+
+func forgetProvenance(provenance map[string]string, path string) {
+	for p := range provenance {
+		if p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+			delete(provenance, p)
+		}
+	}
+}
+
+// layerPath appends key to a path in the syntax accepted by GetKey.
+func layerPath(path, key string) string {
+	if len(key) > 0 && key != "*" && !strings.ContainsAny(key, ".[] =!<>&|)$'\"\\") {
+		if len(path) == 0 {
+			return key
+		}
+		return path + "." + key
+	}
+	return path + "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']"
+}
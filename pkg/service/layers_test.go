@@ -0,0 +1,163 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"testing"
+)
+
+func parseObject(t *testing.T, s string) Models.Object {
+	return parseJSON(t, s).(Models.Object)
+}
+
+func TestMergeLayer(t *testing.T) {
+	base := `{"db":{"host":"h","port":5432},"log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`
+	tests := []struct {
+		name       string
+		own        string
+		want       string
+		provenance map[string]string
+	}{
+		{"nothing own", `{}`, base,
+			map[string]string{"db.host": "base", "db.port": "base", "log": "base", "hosts": "base", "p": "base"}},
+		{"override and append", `{"log":"debug","new":1}`,
+			`{"db":{"host":"h","port":5432},"log":"debug","hosts":["a"],"p":{"$encrypted":"k1:x:y"},"new":1}`,
+			map[string]string{"db.host": "base", "db.port": "base", "log": "own", "hosts": "base", "p": "base", "new": "own"}},
+		{"nested merge", `{"db":{"port":6432,"user":"u"}}`,
+			`{"db":{"host":"h","port":6432,"user":"u"},"log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db.host": "base", "db.port": "own", "db.user": "own", "log": "base", "hosts": "base", "p": "base"}},
+		{"null removes", `{"db":null,"log":null,"missing":null}`, `{"hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"hosts": "base", "p": "base"}},
+		{"null removes a nested key", `{"db":{"host":null}}`,
+			`{"db":{"port":5432},"log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db.port": "base", "log": "base", "hosts": "base", "p": "base"}},
+		{"scalar replaces an object", `{"db":"sqlite"}`,
+			`{"db":"sqlite","log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db": "own", "log": "base", "hosts": "base", "p": "base"}},
+		{"object replaces a scalar", `{"log":{"level":"info","format":{}}}`,
+			`{"db":{"host":"h","port":5432},"log":{"level":"info","format":{}},"hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db.host": "base", "db.port": "base", "log.level": "own", "log.format": "own", "hosts": "base", "p": "base"}},
+		{"arrays are replaced", `{"hosts":["b","c"]}`,
+			`{"db":{"host":"h","port":5432},"log":"info","hosts":["b","c"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db.host": "base", "db.port": "base", "log": "base", "hosts": "own", "p": "base"}},
+		{"secret replaces an object", `{"db":{"$secret":"dsn"}}`,
+			`{"db":{"$secret":"dsn"},"log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"}}`,
+			map[string]string{"db": "own", "log": "base", "hosts": "base", "p": "base"}},
+		{"object replaces a secret", `{"p":{"user":"u"}}`,
+			`{"db":{"host":"h","port":5432},"log":"info","hosts":["a"],"p":{"user":"u"}}`,
+			map[string]string{"db.host": "base", "db.port": "base", "log": "base", "hosts": "base", "p.user": "own"}},
+		{"quoted keys", `{"a.b":1,"db":{"x y":2}}`,
+			`{"db":{"host":"h","port":5432,"x y":2},"log":"info","hosts":["a"],"p":{"$encrypted":"k1:x:y"},"a.b":1}`,
+			map[string]string{"db.host": "base", "db.port": "base", "db['x y']": "own", "log": "base", "hosts": "base", "p": "base", "['a.b']": "own"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provenance := make(map[string]string)
+			b := mergeLayer(Models.Object{}, parseObject(t, base), "base", "", provenance)
+			merged := mergeLayer(b, parseObject(t, tt.own), "own", "", provenance)
+			got, err := json.Marshal(merged)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+			require.Equal(t, tt.provenance, provenance)
+
+			// The base layer is left as it was.
+			got, err = json.Marshal(b)
+			require.NoError(t, err)
+			require.Equal(t, base, string(got))
+		})
+	}
+}
+
+func TestLayerPath(t *testing.T) {
+	tests := []struct {
+		path, key, want string
+	}{
+		{"", "a", "a"},
+		{"a", "b", "a.b"},
+		{"", "a.b", "['a.b']"},
+		{"a", "it's", `a['it\'s']`},
+		{"a", `back\slash`, `a['back\\slash']`},
+		{"a", "*", "a['*']"},
+		{"a", "", "a['']"},
+		{"a", "$ref", "a['$ref']"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, layerPath(tt.path, tt.key), tt.key)
+	}
+}
+
+func TestInherit(t *testing.T) {
+	layers := map[string]string{
+		"base":  `{"parent":"","data":{"db":{"host":"h","port":5432},"log":"info"}}`,
+		"team":  `{"parent":"base","data":{"db":{"port":6432},"team":"x"}}`,
+		"loopA": `{"parent":"loopB","data":{}}`,
+		"loopB": `{"parent":"loopA","data":{}}`,
+		"toApp": `{"parent":"app","data":{}}`,
+		"orph":  `{"parent":"gone","data":{}}`,
+		"fails": `{"parent":"broken","data":{}}`,
+	}
+	// deep2 has maxLayers layers down to deep<maxLayers+1>, deep1 one more.
+	for i := 1; i <= maxLayers; i++ {
+		layers[fmt.Sprintf("deep%d", i)] = fmt.Sprintf(`{"parent":"deep%d","data":{"level":%d}}`, i+1, i)
+	}
+	layers[fmt.Sprintf("deep%d", maxLayers+1)] = `{"parent":"","data":{}}`
+	load := func(service string) (*layer, error) {
+		if service == "broken" {
+			return nil, errors.New("connection refused")
+		}
+		s, ok := layers[service]
+		if !ok {
+			return nil, nil
+		}
+		var l struct {
+			Parent string
+			Data   Models.Document
+		}
+		if err := json.Unmarshal([]byte(s), &l); err != nil {
+			return nil, err
+		}
+		return &layer{Parent: l.Parent, Data: l.Data}, nil
+	}
+
+	tests := []struct {
+		name       string
+		parent     string
+		want       string
+		provenance map[string]string
+		err        string
+	}{
+		{"single parent", "base", `{"db":{"host":"h","port":5432},"log":"info"}`,
+			map[string]string{"db.host": "base", "db.port": "base", "log": "base"}, ""},
+		{"chain of parents", "team", `{"db":{"host":"h","port":6432},"log":"info","team":"x"}`,
+			map[string]string{"db.host": "base", "db.port": "team", "log": "base", "team": "team"}, ""},
+		{"longest chain", "deep2", `{"level":2}`, map[string]string{"level": "deep2"}, ""},
+		{"chain too long", "deep1", "", nil, fmt.Sprintf("Chain of parents of app is longer than %d", maxLayers)},
+		{"loop among parents", "loopA", "", nil, "Chain of parents of app loops: loopA -> loopB -> loopA"},
+		{"loop back to the child", "toApp", "", nil, "Config app can't inherit from toApp, the chain of parents loops back to it"},
+		{"parent is the child", "app", "", nil, "Config app can't inherit from app"},
+		{"missing parent", "orph", "", nil, "Parent config gone has no used version in environment prod"},
+		{"load error", "fails", "", nil, "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, provenance, err := inherit("app", "prod", tt.parent, load)
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				if re, ok := err.(Models.ResponseError); ok {
+					require.Equal(t, http.StatusConflict, re.Status)
+				}
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(data)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+			require.Equal(t, tt.provenance, provenance)
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PatchConfig applies a patch to the used version of the service, or to the
+// latest one if none is used, and stores the result as a new used version. If
+// Version is set it must be the version the patch applies to.
+func (svc configService) PatchConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.PatchRequest)
+	patch, err := Models.ParseValue(r.Patch)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid patch: " + err.Error(), Status: http.StatusBadRequest}
+	}
+	var ops []patchOperation
+	switch r.Type {
+	case Models.MergePatch:
+		if _, ok := patch.(Models.Object); !ok {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid patch: merge patch must be an object", Status: http.StatusBadRequest}
+		}
+	case Models.JSONPatch:
+		err = json.Unmarshal(r.Patch, &ops)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid patch: " + err.Error(), Status: http.StatusBadRequest}
+		}
+	default:
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Unknown patch type %q", r.Type), Status: http.StatusBadRequest}
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+
+	env := environment(r.Environment)
+	err = lockVersions(ctx, tx, r.Service, env)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	var version int
+	var parent string
+	var p payload
+	row := tx.QueryRowContext(ctx, "select version, coalesce(parent, ''), data, encrypted_data, data_key, key_id from configs where service = $1 and environment = $2 and deleted_at is null order by used desc, version desc limit 1", r.Service, env)
+	err = row.Scan(&version, &parent, &p.Data, &p.EncryptedData, &p.DataKey, &p.KeyID)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if r.Version != 0 && r.Version != version {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Config was changed, current version is %d", version), Status: http.StatusConflict}
+	}
+
+	data, err := svc.openPayload(&p)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	var doc Models.Document
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	stored := make(map[string]bool)
+	storedSecrets(doc.Members, stored)
+
+	if r.Type == Models.MergePatch {
+		doc.Members = mergePatch(doc.Members, patch).(Models.Object)
+	} else {
+		doc.Members, err = applyJSONPatch(doc.Members, ops)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sealed, schemaVersion, err := svc.prepareData(r.Service, env, parent, doc, stored)
+	if err != nil {
+		return nil, err
+	}
+	version, err = svc.insertVersion(ctx, tx, newVersion{Service: r.Service, Environment: env, Parent: parent, SchemaVersion: schemaVersion, Payload: sealed})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &Models.ConfigRequest{Service: r.Service, Environment: env, Version: version, Used: true, SchemaVersion: schemaVersion, Parent: parent}, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch. Secrets are replaced as a whole,
+// so {"password": {"$secret": "new"}} replaces the stored secret instead of
+// being merged into it.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(Models.Object)
+	if !ok || isSecret(p) {
+		return patch
+	}
+	t, ok := target.(Models.Object)
+	if !ok || isSecret(t) {
+		t = Models.Object{}
+	} else {
+		t = append(Models.Object{}, t...)
+	}
+	for _, m := range p {
+		if m.Value == nil {
+			t = t.Delete(m.Key)
+			continue
+		}
+		v, _ := t.Get(m.Key)
+		t = t.Set(m.Key, mergePatch(v, m.Value))
+	}
+	return t
+}
+
+func isSecret(o Models.Object) bool {
+	return len(o) == 1 && (o[0].Key == secretMarker || o[0].Key == encryptedMarker)
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies RFC 6902 operations. Either all of them succeed or
+// the error of the first failing one is returned.
+func applyJSONPatch(doc Models.Object, ops []patchOperation) (Models.Object, error) {
+	var root interface{} = doc
+	for i, op := range ops {
+		fail := func(format string, a ...interface{}) error {
+			return Models.ResponseError{ErrorDescr: fmt.Sprintf("Patch operation %d (%s) failed: %s", i, op.Op, fmt.Sprintf(format, a...)), Status: http.StatusUnprocessableEntity}
+		}
+		if op.Path == nil {
+			return nil, fail("path is missing")
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		var value interface{}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fail("value is missing")
+			}
+			value, err = Models.ParseValue(op.Value)
+			if err != nil {
+				return nil, fail("invalid value: %v", err)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fail("from is missing")
+			}
+			from, err := parsePointer(*op.From)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			value, err = getPointer(root, from)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			if op.Op == "move" {
+				if strings.HasPrefix(*op.Path, *op.From+"/") {
+					return nil, fail("can't move a value into itself")
+				}
+				root, err = removePointer(root, from)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+			} else {
+				value = deepCopy(value)
+			}
+		}
+
+		switch op.Op {
+		case "add", "move", "copy":
+			root, err = addPointer(root, path, value)
+		case "remove":
+			root, err = removePointer(root, path)
+		case "replace":
+			if _, err = getPointer(root, path); err == nil {
+				root, err = setPointer(root, path, value)
+			}
+		case "test":
+			var current interface{}
+			current, err = getPointer(root, path)
+			if err == nil && !jsonEqual(current, value) {
+				err = fmt.Errorf("value at %s differs", *op.Path)
+			}
+		default:
+			return nil, fail("unknown operation")
+		}
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+	}
+	obj, ok := root.(Models.Object)
+	if !ok {
+		return nil, Models.ResponseError{ErrorDescr: "Patch result must be an object", Status: http.StatusUnprocessableEntity}
+	}
+	return obj, nil
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, n int, appendable bool) (int, error) {
+	if appendable && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || strings.Trim(token, "0123456789") != "" || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := n - 1
+	if appendable {
+		max = n
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getPointer(v interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch t := v.(type) {
+		case Models.Object:
+			e, ok := t.Get(token)
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", token)
+			}
+			v = e
+		case []interface{}:
+			i, err := arrayIndex(token, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			v = t[i]
+		default:
+			return nil, fmt.Errorf("key %q not found", token)
+		}
+	}
+	return v, nil
+}
+
+// update replaces the value at path with the result of fn applied to its
+// parent container and the last token, and returns the new root.
+func update(v interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(v, path[0])
+	}
+	switch t := v.(type) {
+	case Models.Object:
+		e, ok := t.Get(path[0])
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", path[0])
+		}
+		n, err := update(e, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		return t.Set(path[0], n), nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(t), false)
+		if err != nil {
+			return nil, err
+		}
+		n, err := update(t[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		t[i] = n
+		return t, nil
+	}
+	return nil, fmt.Errorf("key %q not found", path[0])
+}
+
+func addPointer(v interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(v, path, func(parent interface{}, token string) (interface{}, error) {
+		switch t := parent.(type) {
+		case Models.Object:
+			return t.Set(token, value), nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(t), true)
+			if err != nil {
+				return nil, err
+			}
+			t = append(t, nil)
+			copy(t[i+1:], t[i:])
+			t[i] = value
+			return t, nil
+		}
+		return nil, fmt.Errorf("parent of %q is not a container", token)
+	})
+}
+
+func setPointer(v interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(v, path, func(parent interface{}, token string) (interface{}, error) {
+		switch t := parent.(type) {
+		case Models.Object:
+			return t.Set(token, value), nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = value
+			return t, nil
+		}
+		return nil, fmt.Errorf("parent of %q is not a container", token)
+	})
+}
+
+func removePointer(v interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("can't remove the whole document")
+	}
+	return update(v, path, func(parent interface{}, token string) (interface{}, error) {
+		switch t := parent.(type) {
+		case Models.Object:
+			if _, ok := t.Get(token); !ok {
+				return nil, fmt.Errorf("key %q not found", token)
+			}
+			return t.Delete(token), nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(t[:i:i], t[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("key %q not found", token)
+	})
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Models.Object:
+		o := make(Models.Object, len(t))
+		for i, m := range t {
+			o[i] = Models.Member{Key: m.Key, Value: deepCopy(m.Value)}
+		}
+		return o
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = deepCopy(e)
+		}
+		return a
+	}
+	return v
+}
+
+// jsonEqual compares values as RFC 6902 test does: numbers by value, objects
+// regardless of the order of their keys.
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case Models.Object:
+		y, ok := b.(Models.Object)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for _, m := range x {
+			v, ok := y.Get(m.Key)
+			if !ok || !jsonEqual(m.Value, v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, _, err1 := big.ParseFloat(string(x), 10, 256, big.ToNearestEven)
+		fy, _, err2 := big.ParseFloat(string(y), 10, 256, big.ToNearestEven)
+		return err1 == nil && err2 == nil && fx.Cmp(fy) == 0
+	}
+	return a == b
+}
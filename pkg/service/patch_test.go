@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"testing"
+)
+
+func parseJSON(t *testing.T, s string) interface{} {
+	v, err := Models.ParseValue([]byte(s))
+	require.NoError(t, err)
+	return v
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replaces and adds keys in order", `{"a":1,"b":2}`, `{"b":3,"c":4}`, `{"a":1,"b":3,"c":4}`},
+		{"null removes a key", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null for a missing key", `{"a":1}`, `{"x":null}`, `{"a":1}`},
+		{"merges nested objects", `{"db":{"host":"h","port":1}}`, `{"db":{"port":2,"user":null}}`, `{"db":{"host":"h","port":2}}`},
+		{"arrays are replaced", `{"a":[1,2,3]}`, `{"a":[4]}`, `{"a":[4]}`},
+		{"object replaces a scalar", `{"a":1}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"secrets are replaced whole", `{"p":{"$secret":"old"}}`, `{"p":{"$secret":"new"}}`, `{"p":{"$secret":"new"}}`},
+		{"object replaces a secret", `{"p":{"$secret":"old"}}`, `{"p":{"user":"u"}}`, `{"p":{"user":"u"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(mergePatch(parseJSON(t, tt.doc), parseJSON(t, tt.patch)))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := `{"a":{"b":1,"c":[1,2,3]},"d":"x","e~f":{"g/h":true}}`
+	tests := []struct {
+		name string
+		ops  string
+		want string
+		err  string
+	}{
+		{"add key", `[{"op":"add","path":"/a/z","value":{"n":null}}]`, `{"a":{"b":1,"c":[1,2,3],"z":{"n":null}},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"add replaces existing key", `[{"op":"add","path":"/d","value":"y"}]`, `{"a":{"b":1,"c":[1,2,3]},"d":"y","e~f":{"g/h":true}}`, ""},
+		{"add inserts into array", `[{"op":"add","path":"/a/c/1","value":9}]`, `{"a":{"b":1,"c":[1,9,2,3]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"add appends with -", `[{"op":"add","path":"/a/c/-","value":4}]`, `{"a":{"b":1,"c":[1,2,3,4]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"add at array length", `[{"op":"add","path":"/a/c/3","value":4}]`, `{"a":{"b":1,"c":[1,2,3,4]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"add past array length", `[{"op":"add","path":"/a/c/4","value":4}]`, "", "array index 4 out of range"},
+		{"add to missing parent", `[{"op":"add","path":"/x/y","value":1}]`, "", `key "x" not found`},
+		{"remove key", `[{"op":"remove","path":"/d"}]`, `{"a":{"b":1,"c":[1,2,3]},"e~f":{"g/h":true}}`, ""},
+		{"remove array element", `[{"op":"remove","path":"/a/c/0"}]`, `{"a":{"b":1,"c":[2,3]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"remove with - index", `[{"op":"remove","path":"/a/c/-"}]`, "", `invalid array index "-"`},
+		{"remove out of range", `[{"op":"remove","path":"/a/c/3"}]`, "", "array index 3 out of range"},
+		{"remove missing key", `[{"op":"remove","path":"/zz"}]`, "", `key "zz" not found`},
+		{"remove leading zero index", `[{"op":"remove","path":"/a/c/01"}]`, "", `invalid array index "01"`},
+		{"replace", `[{"op":"replace","path":"/a/b","value":[true]}]`, `{"a":{"b":[true],"c":[1,2,3]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"replace missing key", `[{"op":"replace","path":"/a/zz","value":1}]`, "", `key "zz" not found`},
+		{"escaped pointer", `[{"op":"replace","path":"/e~0f/g~1h","value":false}]`, `{"a":{"b":1,"c":[1,2,3]},"d":"x","e~f":{"g/h":false}}`, ""},
+		{"move", `[{"op":"move","from":"/a/b","path":"/b"}]`, `{"a":{"c":[1,2,3]},"d":"x","e~f":{"g/h":true},"b":1}`, ""},
+		{"move array element", `[{"op":"move","from":"/a/c/0","path":"/a/c/-"}]`, `{"a":{"b":1,"c":[2,3,1]},"d":"x","e~f":{"g/h":true}}`, ""},
+		{"move into itself", `[{"op":"move","from":"/a","path":"/a/x"}]`, "", "can't move a value into itself"},
+		{"move missing from", `[{"op":"move","from":"/zz","path":"/y"}]`, "", `key "zz" not found`},
+		{"copy is deep", `[{"op":"copy","from":"/a","path":"/k"},{"op":"add","path":"/k/c/-","value":4}]`,
+			`{"a":{"b":1,"c":[1,2,3]},"d":"x","e~f":{"g/h":true},"k":{"b":1,"c":[1,2,3,4]}}`, ""},
+		{"test passes", `[{"op":"test","path":"/a","value":{"c":[1,2,3],"b":1.0}},{"op":"remove","path":"/d"}]`, `{"a":{"b":1,"c":[1,2,3]},"e~f":{"g/h":true}}`, ""},
+		{"test fails", `[{"op":"remove","path":"/d"},{"op":"test","path":"/a/b","value":"1"}]`, "", "Patch operation 1 (test) failed: value at /a/b differs"},
+		{"test missing path", `[{"op":"test","path":"/zz","value":1}]`, "", `key "zz" not found`},
+		{"missing value", `[{"op":"add","path":"/x"}]`, "", "value is missing"},
+		{"missing path", `[{"op":"remove"}]`, "", "path is missing"},
+		{"invalid pointer", `[{"op":"remove","path":"a"}]`, "", `invalid pointer "a"`},
+		{"unknown operation", `[{"op":"frobnicate","path":"/a"}]`, "", "unknown operation"},
+		{"remove whole document", `[{"op":"remove","path":""}]`, "", "can't remove the whole document"},
+		{"result must be an object", `[{"op":"replace","path":"","value":[1]}]`, "", "Patch result must be an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []patchOperation
+			require.NoError(t, json.Unmarshal([]byte(tt.ops), &ops))
+			got, err := applyJSONPatch(parseJSON(t, doc).(Models.Object), ops)
+			if len(tt.err) > 0 {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			b, err := json.Marshal(got)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(b))
+		})
+	}
+}
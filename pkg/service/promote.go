@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+)
+
+// PromoteConfig copies a version of a config into another service as its new
+// version. The copy keeps the parent and is validated against the schema of
+// the target like any new version, its source is recorded for ListPromotions.
+func (svc configService) PromoteConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.PromoteRequest)
+	if len(r.Target) == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "target service must be specified", Status: http.StatusBadRequest}
+	}
+	return svc.promote(ctx, r)
+}
+
+func (svc configService) promote(ctx context.Context, r *Models.PromoteRequest) (*Models.ConfigRequest, error) {
+	from, to := environment(r.Environment), environment(r.TargetEnvironment)
+	if !validEnvironment.MatchString(to) {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid environment %q, must be letters, digits, '.', '_' and '-' up to 255 characters", to), Status: http.StatusBadRequest}
+	}
+	if r.Target == r.Service && to == from {
+		return nil, Models.ResponseError{ErrorDescr: "source and target must differ", Status: http.StatusBadRequest}
+	}
+
+	l, err := svc.loadLayer(r.Service, from, r.Version)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("No config of service %s in environment %s on request parameters", r.Service, from), Status: http.StatusNotFound}
+	}
+	// Secrets are copied sealed, the caller never sees them.
+	stored := make(map[string]bool)
+	storedSecrets(l.Data.Members, stored)
+	p, schemaVersion, err := svc.prepareData(r.Target, to, l.Parent, l.Data, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+
+	source := &Models.Promotion{SourceService: r.Service, SourceEnvironment: from, SourceVersion: l.Version, PromotedBy: auth.Subject(ctx)}
+	version, err := svc.insertVersion(ctx, tx, newVersion{Service: r.Target, Environment: to, Parent: l.Parent, SchemaVersion: schemaVersion, Payload: p, Inactive: !r.Activate, Source: source})
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return &Models.ConfigRequest{Service: r.Target, Environment: to, Version: version, Used: r.Activate, SchemaVersion: schemaVersion, Parent: l.Parent}, nil
+}
+
+// ListPromotions returns the versions of the service in the environment that
+// were promoted or copied from another config, newest first.
+func (svc configService) ListPromotions(_ context.Context, req interface{}) ([]Models.Promotion, error) {
+	r := req.(*Models.ConfigRequest)
+	env := environment(r.Environment)
+	rows, err := svc.DB.Query(`select version, used, source_service, source_environment, source_version, coalesce(promoted_by, ''), promoted_at
+		from configs where service = $1 and environment = $2 and source_service is not null and deleted_at is null order by version desc`, r.Service, env)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.Promotion, 0)
+	for rows.Next() {
+		p := Models.Promotion{Service: r.Service, Environment: env}
+		err := rows.Scan(&p.Version, &p.Used, &p.SourceService, &p.SourceEnvironment, &p.SourceVersion, &p.PromotedBy, &p.PromotedAt)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
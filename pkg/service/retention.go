@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// SetRetention sets the retention policy of the service, which then no longer
+// follows the global one.
+func (svc configService) SetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	r := req.(*Models.RetentionPolicy)
+	if r.KeepLast < 0 || r.KeepDays < 0 {
+		return nil, Models.ResponseError{ErrorDescr: "keep_last and keep_days must not be negative", Status: http.StatusBadRequest}
+	}
+	r.Global, r.UpdatedBy = false, auth.Subject(ctx)
+	var updatedAt time.Time
+	err := svc.DB.QueryRowContext(ctx, `insert into retention_policies (service, keep_last, keep_days, updated_by) values ($1, $2, $3, $4)
+		on conflict (service) do update set keep_last = excluded.keep_last, keep_days = excluded.keep_days,
+		updated_by = excluded.updated_by, updated_at = now() returning updated_at`,
+		r.Service, r.KeepLast, r.KeepDays, nullString(r.UpdatedBy)).Scan(&updatedAt)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	r.UpdatedAt = &updatedAt
+	return r, nil
+}
+
+// GetRetention returns the retention policy applying to the service.
+func (svc configService) GetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	return svc.retention(ctx, req.(*Models.ConfigRequest).Service)
+}
+
+// DelRetention deletes the retention policy of the service, the global one
+// applies to it again.
+func (svc configService) DelRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	r := req.(*Models.ConfigRequest)
+	res, err := svc.DB.ExecContext(ctx, "delete from retention_policies where service = $1", r.Service)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Service %s has no retention policy of its own", r.Service), Status: http.StatusNotFound}
+	}
+	return svc.retention(ctx, r.Service)
+}
+
+// RetentionReport returns the versions of the service the retention policy
+// would remove now, without removing them.
+func (svc configService) RetentionReport(ctx context.Context, req interface{}) ([]Models.ExpiredVersion, error) {
+	r := req.(*Models.ConfigRequest)
+	p, err := svc.retention(ctx, r.Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := expiredVersions(ctx, svc.DB, p, "")
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return list, nil
+}
+
+// PinConfig pins the version of the config, or unpins it if Pinned is false.
+// Retention never removes pinned versions.
+func (svc configService) PinConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if r.Version == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "version parameter must be specified", Status: http.StatusBadRequest}
+	}
+	r.Environment = environment(r.Environment)
+	err := svc.DB.QueryRowContext(ctx, "update configs set pinned = $4 where service = $1 and environment = $2 and version = $3 and deleted_at is null returning used",
+		r.Service, r.Environment, r.Version, r.Pinned).Scan(&r.Used)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// retention returns the policy of the service, or the global one.
+func (svc configService) retention(ctx context.Context, service string) (*Models.RetentionPolicy, error) {
+	p := Models.RetentionPolicy{Service: service}
+	var updatedAt time.Time
+	err := svc.DB.QueryRowContext(ctx, "select keep_last, keep_days, coalesce(updated_by, ''), updated_at from retention_policies where service = $1", service).
+		Scan(&p.KeepLast, &p.KeepDays, &p.UpdatedBy, &updatedAt)
+	if err == sql.ErrNoRows {
+		p.KeepLast, p.KeepDays, p.Global = svc.Retention.KeepLast, svc.Retention.KeepDays, true
+		return &p, nil
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	p.UpdatedAt = &updatedAt
+	return &p, nil
+}
+
+// expiredVersions returns the versions of p.Service that p removes, only
+// those of env if it is set.
+func expiredVersions(ctx context.Context, db queryer, p *Models.RetentionPolicy, env string) ([]Models.ExpiredVersion, error) {
+	list := make([]Models.ExpiredVersion, 0)
+	if p.KeepLast == 0 && p.KeepDays == 0 {
+		return list, nil
+	}
+	rows, err := db.QueryContext(ctx, `select c.environment, c.version, c.created_at from (
+			select service, environment, version, used, pinned, created_at,
+				row_number() over (partition by environment order by version desc) as n
+			from configs where service = $1 and ($2 = '' or environment = $2) and deleted_at is null
+		) c
+		where c.used = false and c.pinned = false
+			and ($3 = 0 or c.n > $3)
+			and ($4 = 0 or c.created_at < now() - make_interval(days => $4))
+			and not exists (select 1 from canaries k where k.service = c.service and k.environment = c.environment and k.version = c.version)
+			and not exists (select 1 from activations a where a.service = c.service and a.environment = c.environment and a.version = c.version and a.status = $5)
+			and not exists (select 1 from guards g where g.service = c.service and g.environment = c.environment and g.previous_version = c.version and g.status = $6)
+		order by c.environment, c.version`,
+		p.Service, env, p.KeepLast, p.KeepDays, Models.ActivationPending, Models.GuardWatching)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := Models.ExpiredVersion{Service: p.Service}
+		if err := rows.Scan(&v.Environment, &v.Version, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// StartRetention moves the versions retention policies expire to the trash
+// and purges the trash every interval.
+func StartRetention(s *configService, interval time.Duration) {
+	go func() {
+		for {
+			if err := s.collect(); err != nil {
+				log.Printf("Retention failed: %v", err)
+			}
+			if err := s.purge(); err != nil {
+				log.Printf("Purging deleted versions failed: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// collect applies the retention policy of every service, one environment at
+// a time. The expired versions are looked up again once the versions of the
+// environment are locked, so that nothing used or pinned meanwhile is removed.
+func (svc configService) collect() error {
+	ctx := context.Background()
+	rows, err := svc.DB.QueryContext(ctx, "select distinct service, environment from configs where used = false and pinned = false and deleted_at is null order by service, environment")
+	if err != nil {
+		return err
+	}
+	type target struct{ service, env string }
+	var targets []target
+	for rows.Next() {
+		var e target
+		if err := rows.Scan(&e.service, &e.env); err != nil {
+			rows.Close()
+			return err
+		}
+		targets = append(targets, e)
+	}
+	rows.Close()
+
+	policies := make(map[string]*Models.RetentionPolicy)
+	for _, e := range targets {
+		p, ok := policies[e.service]
+		if !ok {
+			if p, err = svc.retention(ctx, e.service); err != nil {
+				return err
+			}
+			policies[e.service] = p
+		}
+		if err := svc.collectEnvironment(ctx, p, e.env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (svc configService) collectEnvironment(ctx context.Context, p *Models.RetentionPolicy, env string) error {
+	if p.KeepLast == 0 && p.KeepDays == 0 {
+		return nil
+	}
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, p.Service, env); err != nil {
+		return err
+	}
+	list, err := expiredVersions(ctx, tx, p, env)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	for _, v := range list {
+		_, err := tx.ExecContext(ctx, "update configs set deleted_at = now(), deleted_by = $4 where service = $1 and environment = $2 and version = $3",
+			v.Service, v.Environment, v.Version, retentionSubject)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, v := range list {
+		log.Printf("AUDIT: retention deleted service %s environment %s version %d created %s, policy keep_last %d keep_days %d",
+			v.Service, v.Environment, v.Version, v.CreatedAt.Format(time.RFC3339), p.KeepLast, p.KeepDays)
+	}
+	return nil
+}
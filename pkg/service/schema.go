@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"github.com/tonx22/gocloudcamp/pkg/schema"
+	"net/http"
+)
+
+// SetSchema registers a new schema version for the service after checking it
+// against the previous versions in the compatibility mode of the service.
+// Versions are numbered one after another, the numbers of deleted versions
+// are not given again.
+func (svc configService) SetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	r := req.(*Models.SchemaRequest)
+	if _, err := schema.Compile(r.Schema); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid schema: " + err.Error(), Status: http.StatusBadRequest}
+	}
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockSchemas(ctx, tx, r.Service); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	mode, err := svc.compatibility(r.Service)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := svc.previousSchemas(r.Service, schema.Transitive(mode))
+	if err != nil {
+		return nil, err
+	}
+	fields, err := schema.CheckCompatibility(mode, r.Schema, previous)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Invalid schema: " + err.Error(), Status: http.StatusBadRequest}
+	}
+	if len(fields) > 0 {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Schema is incompatible in %s mode", mode), Status: http.StatusConflict, Fields: fields}
+	}
+
+	row := tx.QueryRowContext(ctx, `insert into schema_counters (service, last_version)
+		select $1, coalesce(max(version), 0) + 1 from schema_versions where service = $1
+		on conflict (service) do update set last_version = schema_counters.last_version + 1
+		returning last_version`, r.Service)
+	err = row.Scan(&r.Version)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	_, err = tx.ExecContext(ctx, "insert into schema_versions (service, version, schema) values ($1, $2, $3)", r.Service, r.Version, []byte(r.Schema))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	r.Compatibility = mode
+	return r, nil
+}
+
+// lockSchemas serializes the registration and deletion of schema versions of
+// a service, so that compatibility is checked against the latest versions.
+func lockSchemas(ctx context.Context, tx *sql.Tx, service string) error {
+	_, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('schema:' || $1))", service)
+	return err
+}
+
+// GetSchema returns the requested schema version, the latest one if Version is 0.
+func (svc configService) GetSchema(_ context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	r := req.(*Models.SchemaRequest)
+	version, doc, err := svc.loadSchema(r.Service, r.Version)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, Models.ResponseError{ErrorDescr: "No schema registered for the service", Status: http.StatusNotFound}
+	}
+	r.Version, r.Schema = version, doc
+	r.Compatibility, err = svc.compatibility(r.Service)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ListSchemas returns all schema versions of the service, oldest first.
+func (svc configService) ListSchemas(_ context.Context, req interface{}) ([]Models.SchemaRequest, error) {
+	r := req.(*Models.SchemaRequest)
+	rows, err := svc.DB.Query("select version, schema from schema_versions where service = $1 order by version", r.Service)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.SchemaRequest, 0)
+	for rows.Next() {
+		s := Models.SchemaRequest{Service: r.Service}
+		err := rows.Scan(&s.Version, &s.Schema)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// DelSchema deletes a schema version, or every version and the compatibility
+// setting if Version is 0. Versions config versions were validated against,
+// deleted ones in the trash included, are kept.
+func (svc configService) DelSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	r := req.(*Models.SchemaRequest)
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := lockSchemas(ctx, tx, r.Service); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	var referenced int
+	err = tx.QueryRowContext(ctx, "select count(*) from configs where service = $1 and ($2 = 0 or schema_version = $2) and schema_version is not null",
+		r.Service, r.Version).Scan(&referenced)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if referenced > 0 {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("%d config versions were validated against the schema, delete them first", referenced), Status: http.StatusConflict}
+	}
+
+	var res sql.Result
+	if r.Version == 0 {
+		res, err = tx.ExecContext(ctx, "delete from schema_versions where service = $1", r.Service)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "delete from schema_settings where service = $1", r.Service)
+		}
+	} else {
+		res, err = tx.ExecContext(ctx, "delete from schema_versions where service = $1 and version = $2", r.Service, r.Version)
+	}
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "No schema registered for the service", Status: http.StatusNotFound}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// SetCompatibility sets the compatibility mode checked when the service registers new schema versions.
+func (svc configService) SetCompatibility(_ context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	r := req.(*Models.SchemaRequest)
+	if !schema.ValidMode(r.Compatibility) {
+		return nil, Models.ResponseError{ErrorDescr: "compatibility must be one of none, backward, backward_transitive, forward, forward_transitive, full, full_transitive", Status: http.StatusBadRequest}
+	}
+	_, err := svc.DB.Exec(`insert into schema_settings (service, compatibility) values ($1, $2)
+		on conflict (service) do update set compatibility = excluded.compatibility`, r.Service, r.Compatibility)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// ValidateConfig checks the data of a SetConfig request against the latest
+// schema of the service without storing anything.
+func (svc configService) ValidateConfig(_ context.Context, req interface{}) (*Models.ValidationResult, error) {
+	r := req.(*Models.ConfigRequest)
+	data, err := svc.effectiveData(r.Service, environment(r.Environment), r.Parent, r.Data.Members)
+	if err != nil {
+		return nil, err
+	}
+	_, fields, err := svc.validateData(r.Service, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Models.ValidationResult{Valid: len(fields) == 0, Errors: fields}, nil
+}
+
+// validateData returns the violations of data against the latest schema of the
+// service and the version of that schema, 0 if the service has no schema.
+func (svc configService) validateData(service string, data interface{}) (int, []Models.FieldError, error) {
+	version, doc, err := svc.loadSchema(service, 0)
+	if err != nil || doc == nil {
+		return 0, nil, err
+	}
+	fields, err := validateAgainst(service, doc, data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, fields, nil
+}
+
+// validateAgainst returns the violations of data against the schema document.
+// Secrets are validated by their value, not by the {"$secret": ...} wrapper.
+func validateAgainst(service string, doc []byte, data interface{}) ([]Models.FieldError, error) {
+	s, err := schema.Compile(doc)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Stored schema of service %s is invalid: %v", service, err)}
+	}
+
+	// The validator expects values as decoded by encoding/json.
+	b, err := json.Marshal(UnwrapSecrets(data))
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Data marshaling failed"}
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return schema.Validate(s, v), nil
+}
+
+// loadSchema returns the given schema version, the latest one if version is 0.
+func (svc configService) loadSchema(service string, version int) (int, []byte, error) {
+	var doc []byte
+	var row *sql.Row
+	if version == 0 {
+		row = svc.DB.QueryRow("select version, schema from schema_versions where service = $1 order by version desc limit 1", service)
+	} else {
+		row = svc.DB.QueryRow("select version, schema from schema_versions where service = $1 and version = $2", service, version)
+	}
+	err := row.Scan(&version, &doc)
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	} else if err != nil {
+		return 0, nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return version, doc, nil
+}
+
+// previousSchemas returns the latest schema version, or all of them when all is set.
+func (svc configService) previousSchemas(service string, all bool) (map[int][]byte, error) {
+	query := "select version, schema from schema_versions where service = $1 order by version desc limit 1"
+	if all {
+		query = "select version, schema from schema_versions where service = $1"
+	}
+	rows, err := svc.DB.Query(query, service)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	previous := make(map[int][]byte)
+	for rows.Next() {
+		var version int
+		var doc []byte
+		err := rows.Scan(&version, &doc)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		previous[version] = doc
+	}
+	return previous, nil
+}
+
+// compatibility returns the compatibility mode of the service, or the default one.
+func (svc configService) compatibility(service string) (string, error) {
+	var mode string
+	err := svc.DB.QueryRow("select compatibility from schema_settings where service = $1", service).Scan(&mode)
+	if err == sql.ErrNoRows {
+		if len(svc.SchemaCompatibility) > 0 {
+			return svc.SchemaCompatibility, nil
+		}
+		return schema.Backward, nil
+	} else if err != nil {
+		return "", Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return mode, nil
+}
+
+// nullInt stores a missing number like a schema version as NULL.
+func nullInt(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/stretchr/testify/require"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"testing"
+)
+
+func TestValidateAgainst(t *testing.T) {
+	doc := []byte(`{"type":"object","properties":{
+		"password":{"type":"string","minLength":8},
+		"db":{"type":"object","properties":{"port":{"type":"integer"}}},
+		"hosts":{"type":"array","items":{"type":"string"}}
+	}}`)
+	tests := []struct {
+		name string
+		data string
+		want []Models.FieldError
+	}{
+		{"secret validated by its value", `{"password":{"$secret":"long enough"}}`, nil},
+		{"invalid secret value", `{"password":{"$secret":"short"}}`,
+			[]Models.FieldError{{Path: "data.password", Message: "length must be >= 8, but got 5"}}},
+		{"nested secret", `{"db":{"port":{"$secret":"x"}},"hosts":["a",{"$secret":1}]}`, []Models.FieldError{
+			{Path: "data.db.port", Message: "expected integer, but got string"},
+			{Path: "data.hosts[1]", Message: "expected string, but got number"},
+		}},
+		{"object with more keys is not a secret", `{"password":{"$secret":"long enough","x":1}}`,
+			[]Models.FieldError{{Path: "data.password", Message: "expected string, but got object"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Models.ParseValue([]byte(tt.data))
+			require.NoError(t, err)
+			fields, err := validateAgainst("svc", doc, data)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, fields)
+		})
+	}
+
+	_, err := validateAgainst("svc", []byte(`{"type":1}`), Models.Object{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Stored schema of service svc is invalid")
+}
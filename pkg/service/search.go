@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// SearchConfigs finds the used config versions that contain the key given by
+// a dotted path, or whose value at the key equals Value if it is set, in all
+// environments or only in Environment if it is set. Configs encrypted at rest
+// are searched through their search_data, sealed secrets never match a value.
+func (svc configService) SearchConfigs(_ context.Context, req interface{}) ([]Models.SearchResult, error) {
+	r := req.(*Models.SearchRequest)
+	keys := strings.Split(r.Path, ".")
+	for _, k := range keys {
+		if len(k) == 0 {
+			return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid path %q", r.Path), Status: http.StatusBadRequest}
+		}
+	}
+
+	query := "select service, environment, version, search_data #> $1 from configs where used = true and "
+	args := []interface{}{pq.Array(keys)}
+	if len(r.Environment) > 0 {
+		args = append(args, r.Environment)
+		query += fmt.Sprintf("environment = $%d and ", len(args))
+	}
+	if r.Value == nil {
+		quoted := make([]string, len(keys))
+		for i, k := range keys {
+			b, _ := json.Marshal(k)
+			quoted[i] = string(b)
+		}
+		args = append(args, "$."+strings.Join(quoted, "."))
+		query += fmt.Sprintf("search_data @? $%d::jsonpath", len(args))
+	} else {
+		value, err := Models.ParseValue(r.Value)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid value: " + err.Error(), Status: http.StatusBadRequest}
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			value = Models.Object{{Key: keys[i], Value: value}}
+		}
+		doc, _ := json.Marshal(value)
+		args = append(args, string(doc))
+		query += fmt.Sprintf("search_data @> $%d::jsonb", len(args))
+	}
+	rows, err := svc.DB.Query(query+" order by service, environment", args...)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	results := make([]Models.SearchResult, 0)
+	for rows.Next() {
+		var res Models.SearchResult
+		var value []byte
+		err := rows.Scan(&res.Service, &res.Environment, &res.Version, &value)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		v, err := Models.ParseValue(value)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		// Sealed secrets are returned redacted, as by GetConfig.
+		res.Value, err = svc.openSecrets(v, "data."+r.Path, false, nil)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// searchData returns the object form of a stored document for the search_data
+// column, with its sealed secrets redacted. search_data is not encrypted even
+// when the document is, secrets are the values that are never kept in clear.
+func (svc configService) searchData(data []byte) ([]byte, error) {
+	var doc Models.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	redacted, err := svc.openSecrets(doc.Members, "data", false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(redacted)
+}
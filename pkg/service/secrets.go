@@ -0,0 +1,226 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/encryption"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// A value wrapped as {"$secret": <value>} is a secret. It is stored encrypted
+// on its own as {"$encrypted": "<key id>:<data key>:<ciphertext>"} and returned
+// as {"$secret": "******"} unless the caller asked for it to be revealed.
+const (
+	secretMarker    = "$secret"
+	encryptedMarker = "$encrypted"
+	redactedSecret  = "******"
+)
+
+// sealSecrets returns a copy of v with every secret encrypted. found reports
+// whether v contained secrets at all. Already sealed secrets are kept only if
+// they are listed in stored, i.e. come from the config being patched.
+func (svc configService) sealSecrets(v interface{}, path string, stored map[string]bool) (sealed interface{}, found bool, err error) {
+	switch t := v.(type) {
+	case Models.Object:
+		if enc, ok := singleKey(t, encryptedMarker); ok {
+			if s, _ := enc.(string); stored[s] {
+				return t, true, nil
+			}
+			// Accepting sealed secrets from callers would let them copy a secret
+			// of another service into one they are allowed to reveal.
+			return nil, false, Models.ResponseError{ErrorDescr: fmt.Sprintf("%s at %s is reserved for stored secrets", encryptedMarker, path), Status: http.StatusBadRequest}
+		}
+		if secret, ok := singleKey(t, secretMarker); ok {
+			if secret == redactedSecret {
+				return nil, false, Models.ResponseError{ErrorDescr: fmt.Sprintf("secret at %s is redacted, the actual value must be sent", path), Status: http.StatusBadRequest}
+			}
+			if svc.Keys == nil {
+				return nil, false, Models.ResponseError{ErrorDescr: "secret values require encryption to be configured", Status: http.StatusBadRequest}
+			}
+			enc, err := svc.encryptSecret(secret)
+			if err != nil {
+				return nil, false, Models.ResponseError{ErrorDescr: "Secret encryption failed: " + err.Error()}
+			}
+			return Models.Object{{Key: encryptedMarker, Value: enc}}, true, nil
+		}
+		m := make(Models.Object, len(t))
+		for i, e := range t {
+			s, f, err := svc.sealSecrets(e.Value, path+"."+e.Key, stored)
+			if err != nil {
+				return nil, false, err
+			}
+			m[i] = Models.Member{Key: e.Key, Value: s}
+			found = found || f
+		}
+		return m, found, nil
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			s, f, err := svc.sealSecrets(e, fmt.Sprintf("%s[%d]", path, i), stored)
+			if err != nil {
+				return nil, false, err
+			}
+			a[i] = s
+			found = found || f
+		}
+		return a, found, nil
+	}
+	return v, false, nil
+}
+
+// openSecrets replaces every encrypted secret in v with its value when reveal is
+// set, or with a redacted placeholder otherwise. The paths of revealed secrets
+// are appended to revealed.
+func (svc configService) openSecrets(v interface{}, path string, reveal bool, revealed *[]string) (interface{}, error) {
+	switch t := v.(type) {
+	case Models.Object:
+		if enc, ok := singleKey(t, encryptedMarker); ok {
+			if !reveal {
+				return Models.Object{{Key: secretMarker, Value: redactedSecret}}, nil
+			}
+			secret, err := svc.decryptSecret(enc)
+			if err != nil {
+				return nil, fmt.Errorf("can't decrypt secret at %s: %v", path, err)
+			}
+			*revealed = append(*revealed, path)
+			return Models.Object{{Key: secretMarker, Value: secret}}, nil
+		}
+		for i, e := range t {
+			o, err := svc.openSecrets(e.Value, path+"."+e.Key, reveal, revealed)
+			if err != nil {
+				return nil, err
+			}
+			t[i].Value = o
+		}
+	case []interface{}:
+		for i, e := range t {
+			o, err := svc.openSecrets(e, fmt.Sprintf("%s[%d]", path, i), reveal, revealed)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = o
+		}
+	}
+	return v, nil
+}
+
+// resealSecrets re-encrypts in place every secret not sealed with the active key.
+func (svc configService) resealSecrets(v interface{}) error {
+	switch t := v.(type) {
+	case Models.Object:
+		if enc, ok := singleKey(t, encryptedMarker); ok {
+			s, _ := enc.(string)
+			if strings.HasPrefix(s, svc.Keys.ActiveKeyID()+":") {
+				return nil
+			}
+			secret, err := svc.decryptSecret(enc)
+			if err != nil {
+				return err
+			}
+			t[0].Value, err = svc.encryptSecret(secret)
+			return err
+		}
+		for _, e := range t {
+			if err := svc.resealSecrets(e.Value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if err := svc.resealSecrets(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (svc configService) encryptSecret(secret interface{}) (string, error) {
+	plain, err := json.Marshal(secret)
+	if err != nil {
+		return "", err
+	}
+	e, err := svc.Keys.Encrypt(plain)
+	if err != nil {
+		return "", err
+	}
+	return e.KeyID + ":" + base64.StdEncoding.EncodeToString(e.DataKey) + ":" + base64.StdEncoding.EncodeToString(e.Ciphertext), nil
+}
+
+func (svc configService) decryptSecret(enc interface{}) (interface{}, error) {
+	s, _ := enc.(string)
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("malformed encrypted secret")
+	}
+	if svc.Keys == nil {
+		return nil, fmt.Errorf("encryption is not configured")
+	}
+	n := len(parts)
+	e := encryption.Envelope{KeyID: strings.Join(parts[:n-2], ":")}
+	var err error
+	if e.DataKey, err = base64.StdEncoding.DecodeString(parts[n-2]); err != nil {
+		return nil, fmt.Errorf("malformed encrypted secret")
+	}
+	if e.Ciphertext, err = base64.StdEncoding.DecodeString(parts[n-1]); err != nil {
+		return nil, fmt.Errorf("malformed encrypted secret")
+	}
+	plain, err := svc.Keys.Decrypt(&e)
+	if err != nil {
+		return nil, err
+	}
+	return Models.ParseValue(plain)
+}
+
+// storedSecrets collects the sealed secrets of v into stored.
+func storedSecrets(v interface{}, stored map[string]bool) {
+	switch t := v.(type) {
+	case Models.Object:
+		if enc, ok := singleKey(t, encryptedMarker); ok {
+			if s, ok := enc.(string); ok {
+				stored[s] = true
+			}
+			return
+		}
+		for _, e := range t {
+			storedSecrets(e.Value, stored)
+		}
+	case []interface{}:
+		for _, e := range t {
+			storedSecrets(e, stored)
+		}
+	}
+}
+
+// UnwrapSecrets returns a copy of v with every {"$secret": <value>} replaced by the value.
+func UnwrapSecrets(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Models.Object:
+		if secret, ok := singleKey(t, secretMarker); ok {
+			return secret
+		}
+		m := make(Models.Object, len(t))
+		for i, e := range t {
+			m[i] = Models.Member{Key: e.Key, Value: UnwrapSecrets(e.Value)}
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = UnwrapSecrets(e)
+		}
+		return a
+	}
+	return v
+}
+
+// singleKey returns the value of m if key is its only key.
+func singleKey(m Models.Object, key string) (interface{}, bool) {
+	if len(m) != 1 || m[0].Key != key {
+		return nil, false
+	}
+	return m[0].Value, true
+}
@@ -6,53 +6,94 @@ import (
 	"encoding/json"
 	"fmt"
 	_ "github.com/lib/pq"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/encryption"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
 	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type ConfigService interface {
 	SetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
 	GetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	GetKey(ctx context.Context, req interface{}) (*Models.KeyRequest, error)
 	UpdConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
 	DelConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	ListDeleted(ctx context.Context, req interface{}) ([]Models.DeletedVersion, error)
+	RestoreConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	PatchConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	ValidateConfig(ctx context.Context, req interface{}) (*Models.ValidationResult, error)
+	SearchConfigs(ctx context.Context, req interface{}) ([]Models.SearchResult, error)
+	ListEnvironments(ctx context.Context, req interface{}) ([]Models.Environment, error)
+	CopyConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	PromoteConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	ListPromotions(ctx context.Context, req interface{}) ([]Models.Promotion, error)
+	ListActivations(ctx context.Context, req interface{}) ([]Models.Activation, error)
+	CancelActivation(ctx context.Context, req interface{}) (*Models.Activation, error)
+	ListGuards(ctx context.Context, req interface{}) ([]Models.Guard, error)
+	ReportHealth(ctx context.Context, req interface{}) (*Models.Guard, error)
+
+	SetFlag(ctx context.Context, req interface{}) (*Models.Flag, error)
+	ListFlags(ctx context.Context, req interface{}) ([]Models.Flag, error)
+	DelFlag(ctx context.Context, req interface{}) (*Models.Flag, error)
+	EvaluateFlags(ctx context.Context, req interface{}) ([]flags.Result, error)
+
+	SetCanary(ctx context.Context, req interface{}) (*Models.Canary, error)
+	GetCanary(ctx context.Context, req interface{}) (*Models.Canary, error)
+	PromoteCanary(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+	AbortCanary(ctx context.Context, req interface{}) (*Models.Canary, error)
+	PinConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error)
+
+	SetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error)
+	GetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error)
+	DelRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error)
+	RetentionReport(ctx context.Context, req interface{}) ([]Models.ExpiredVersion, error)
+
+	SetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error)
+	GetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error)
+	DelSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error)
+	ListSchemas(ctx context.Context, req interface{}) ([]Models.SchemaRequest, error)
+	SetCompatibility(ctx context.Context, req interface{}) (*Models.SchemaRequest, error)
 }
 
-func (svc configService) SetConfig(_ context.Context, req interface{}) (*Models.ConfigRequest, error) {
+// SetConfig stores a new version of the config and makes it the used one, or
+// schedules that for ActivateAt if it is set.
+func (svc configService) SetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
 	r := req.(*Models.ConfigRequest)
-	json, err := json.Marshal(r.Data)
+	r.Environment = environment(r.Environment)
+	if !validEnvironment.MatchString(r.Environment) {
+		return nil, Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid environment %q, must be letters, digits, '.', '_' and '-' up to 255 characters", r.Environment), Status: http.StatusBadRequest}
+	}
+	if r.ActivateAt != nil {
+		if err := checkActivateAt(*r.ActivateAt); err != nil {
+			return nil, err
+		}
+	}
+	p, schemaVersion, err := svc.prepareData(r.Service, r.Environment, r.Parent, r.Data, nil)
 	if err != nil {
-		return nil, Models.ResponseError{ErrorDescr: "Data marshaling failed"}
+		return nil, err
 	}
 
-	ctx := context.Background()
 	tx, err := svc.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
 
-	row := tx.QueryRow("select coalesce(max(version), 0) from configs where service = $1", r.Service)
-	var version int
-	err = row.Scan(&version)
+	version, err := svc.insertVersion(ctx, tx, newVersion{Service: r.Service, Environment: r.Environment, Parent: r.Parent, SchemaVersion: schemaVersion, Payload: p, Inactive: r.ActivateAt != nil})
 	if err != nil {
 		tx.Rollback()
-		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		return nil, err
 	}
-
-	if version > 0 {
-		_, err = tx.ExecContext(ctx, "update configs set used=false where service = $1 and used = true", r.Service)
-		if err != nil {
+	if r.ActivateAt != nil {
+		if err := scheduleActivation(ctx, tx, r.Service, r.Environment, version, *r.ActivateAt); err != nil {
 			tx.Rollback()
-			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+			return nil, err
 		}
 	}
-	version++
-
-	_, err = tx.ExecContext(ctx, "insert into configs (service, version, data) values ($1, $2, $3)", r.Service, version, json)
-	if err != nil {
-		tx.Rollback()
-		return nil, Models.ResponseError{ErrorDescr: err.Error()}
-	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -61,145 +102,335 @@ func (svc configService) SetConfig(_ context.Context, req interface{}) (*Models.
 	}
 
 	r.Version = version
+	r.SchemaVersion = schemaVersion
 	return r, nil
 }
 
-func (svc configService) GetConfig(_ context.Context, req interface{}) (*Models.ConfigRequest, error) {
-	r := req.(*Models.ConfigRequest)
-	var rows *sql.Rows
-	var err error
-	if r.Version == 0 {
-		rows, err = svc.DB.Query("select coalesce(id, 0), coalesce(version, 0), used, data from configs where service = $1 and used = true limit 1", r.Service)
-	} else {
-		rows, err = svc.DB.Query("select coalesce(id, 0), coalesce(version, 0), used, data from configs where service = $1 and version = $2 limit 1", r.Service, r.Version)
+// prepareData validates doc, merged with the data inherited from parent in the
+// environment if any, against the schema of the service, seals its secrets and returns the
+// payload to store with the version of the schema. Sealed secrets are accepted
+// only if they are listed in stored.
+func (svc configService) prepareData(service, env, parent string, doc Models.Document, stored map[string]bool) (*payload, int, error) {
+	effective, err := svc.effectiveData(service, env, parent, doc.Members)
+	if err != nil {
+		return nil, 0, err
 	}
+	schemaVersion, fields, err := svc.validateData(service, effective)
 	if err != nil {
-		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		return nil, 0, err
+	}
+	if len(fields) > 0 {
+		return nil, 0, Models.ResponseError{ErrorDescr: "Config does not match the schema of the service", Status: http.StatusBadRequest, Fields: fields}
 	}
-	defer rows.Close()
 
-	var id, version int
-	var used bool
-	var jsonData []byte
-	for rows.Next() {
-		err := rows.Scan(&id, &version, &used, &jsonData)
+	data, hasSecrets, err := svc.sealSecrets(doc.Members, "data", stored)
+	if err != nil {
+		return nil, 0, err
+	}
+	if hasSecrets && len(stored) > 0 {
+		// Kept secrets may be sealed with an older key, the version is recorded as sealed with the active one.
+		if err := svc.resealSecrets(data); err != nil {
+			return nil, 0, Models.ResponseError{ErrorDescr: "Secret encryption failed: " + err.Error()}
+		}
+	}
+	json, err := json.Marshal(Models.Document{Members: data.(Models.Object), Array: doc.Array})
+	if err != nil {
+		return nil, 0, Models.ResponseError{ErrorDescr: "Data marshaling failed"}
+	}
+	p, err := svc.sealPayload(json)
+	if err != nil {
+		return nil, 0, Models.ResponseError{ErrorDescr: "Data encryption failed"}
+	}
+	if hasSecrets {
+		p.SecretsKeyID = sql.NullString{String: svc.Keys.ActiveKeyID(), Valid: true}
+	}
+	return p, schemaVersion, nil
+}
+
+// newVersion is a config version to be stored by insertVersion.
+type newVersion struct {
+	Service       string
+	Environment   string
+	Parent        string
+	SchemaVersion int
+	Payload       *payload
+	// Inactive versions are stored without replacing the used one.
+	Inactive bool
+	// Source is the version a promoted or copied version was taken from.
+	Source *Models.Promotion
+}
+
+// insertVersion stores v as the next version of its service and environment
+// and, unless it is inactive, makes it the used one. The quotas of the tenant
+// owning the service are checked first.
+func (svc configService) insertVersion(ctx context.Context, tx *sql.Tx, v newVersion) (int, error) {
+	if err := svc.checkQuota(ctx, tx, v.Service, v.Payload.Size); err != nil {
+		return 0, err
+	}
+	version, err := v.insert(ctx, tx)
+	if err != nil {
+		return 0, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return version, nil
+}
+
+func (v newVersion) insert(ctx context.Context, tx *sql.Tx) (int, error) {
+	err := lockVersions(ctx, tx, v.Service, v.Environment)
+	if err != nil {
+		return 0, err
+	}
+
+	// Numbers come from a counter rather than the versions left, so that the
+	// number of a purged version is never given to another one: activations,
+	// guards and promotions may still refer to it.
+	row := tx.QueryRowContext(ctx, `insert into config_versions (service, environment, last_version)
+		select $1, $2, coalesce(max(version), 0) + 1 from configs where service = $1 and environment = $2
+		on conflict (service, environment) do update set last_version = config_versions.last_version + 1
+		returning last_version`, v.Service, v.Environment)
+	var version int
+	err = row.Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	if !v.Inactive {
+		_, err = tx.ExecContext(ctx, "update configs set used=false where service = $1 and environment = $2 and used = true", v.Service, v.Environment)
 		if err != nil {
-			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+			return 0, err
 		}
 	}
-	if id == 0 {
+
+	var source Models.Promotion
+	if v.Source != nil {
+		source = *v.Source
+	}
+	args := []interface{}{v.Service, v.Environment, version, !v.Inactive, nullInt(v.SchemaVersion), nullString(v.Parent),
+		nullString(source.SourceService), nullString(source.SourceEnvironment), nullInt(source.SourceVersion), nullString(source.PromotedBy)}
+	args = append(args, v.Payload.args()...)
+	_, err = tx.ExecContext(ctx, `insert into configs (service, environment, version, used, schema_version, parent,
+		source_service, source_environment, source_version, promoted_by, promoted_at,
+		data, encrypted_data, data_key, key_id, secrets_key_id, search_data)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, case when $7::text is null then null else now() end, $11, $12, $13, $14, $15, $16)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// lockVersions serializes writers of an environment of a service: its versions
+// are numbered one after another, concurrent writers wait here.
+func lockVersions(ctx context.Context, tx *sql.Tx, service, env string) error {
+	_, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext($1 || '/' || $2))", service, env)
+	return err
+}
+
+var validEnvironment = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+// environment returns the environment of a request, the default one if it has none.
+func environment(env string) string {
+	if len(env) == 0 {
+		return Models.DefaultEnvironment
+	}
+	return env
+}
+
+// GetConfig returns the requested version of the config, or the used one. A
+// client with a ClientID may get the canary version instead, Variant tells.
+func (svc configService) GetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if r.Reveal && !auth.CanReveal(ctx) {
+		return nil, Models.ResponseError{ErrorDescr: "Permission to reveal secrets required", Status: http.StatusForbidden}
+	}
+	r.Environment = environment(r.Environment)
+	version, variant := r.Version, ""
+	if r.Version == 0 {
+		canary, err := svc.canaryVersion(r.Service, r.Environment, r.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		version, variant = canary, Models.VariantStable
+		if canary > 0 {
+			variant = Models.VariantCanary
+		}
+	}
+	l, err := svc.loadLayer(r.Service, r.Environment, version)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
 		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
 	}
+	r.Variant = variant
+	r.Data = l.Data
+	r.Parent = l.Parent
+	r.Provenance = nil
+	if len(l.Parent) > 0 && !r.Raw {
+		inherited, provenance, err := svc.inheritedData(r.Service, r.Environment, l.Parent)
+		if err != nil {
+			return nil, err
+		}
+		r.Data.Members = mergeLayer(inherited, l.Data.Members, r.Service, "", provenance)
+		r.Provenance = provenance
+	}
+	r.References = nil
+	if !r.Raw && !r.Unresolved {
+		r.Data.Members, r.References, err = svc.interpolate(r.Service, r.Environment, r.Data.Members)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	err = json.Unmarshal(jsonData, &r.Data)
+	var revealed []string
+	_, err = svc.openSecrets(r.Data.Members, "data", r.Reveal, &revealed)
 	if err != nil {
 		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
-	r.Version = version
-	r.Used = used
+	if len(revealed) > 0 {
+		log.Printf("AUDIT: %s revealed secrets %s of service %s environment %s version %d", auth.Subject(ctx), strings.Join(revealed, ", "), r.Service, r.Environment, l.Version)
+	}
+	r.Version = l.Version
+	r.Used = l.Used
+	r.SchemaVersion = l.SchemaVersion
 	return r, nil
 }
 
-func (svc configService) UpdConfig(_ context.Context, req interface{}) (*Models.ConfigRequest, error) {
+// UpdConfig makes the version the used one, or unsets the used version if Used
+// is false. With ActivateAt the version becomes the used one at that time,
+// with Watch it is rolled back if it fails within that time, see ReportHealth.
+func (svc configService) UpdConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
 	r := req.(*Models.ConfigRequest)
-	var rows *sql.Rows
-	var err error
-	if r.Version == 0 {
-		rows, err = svc.DB.Query("select coalesce(id, 0), used from configs where service = $1 and used = true limit 1", r.Service)
-	} else {
-		rows, err = svc.DB.Query("select coalesce(id, 0), used from configs where service = $1 and version = $2 limit 1", r.Service, r.Version)
+	r.Environment = environment(r.Environment)
+	if r.Watch != 0 {
+		return svc.guardedUpdate(ctx, r)
+	}
+	if r.ActivateAt != nil {
+		return svc.scheduleUpdate(ctx, r)
 	}
+	tx, err := svc.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
-	defer rows.Close()
+	defer tx.Rollback()
+	// The version is looked up under the lock so that it can't be deleted
+	// before it becomes the used one.
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
 
 	var id int
 	var used bool
-	for rows.Next() {
-		err := rows.Scan(&id, &used)
-		if err != nil {
-			return nil, Models.ResponseError{ErrorDescr: err.Error()}
-		}
+	if r.Version == 0 {
+		err = tx.QueryRowContext(ctx, "select id, used from configs where service = $1 and environment = $2 and used = true limit 1", r.Service, r.Environment).Scan(&id, &used)
+	} else {
+		err = tx.QueryRowContext(ctx, "select id, used from configs where service = $1 and environment = $2 and version = $3 and deleted_at is null", r.Service, r.Environment, r.Version).Scan(&id, &used)
 	}
-	if id == 0 {
+	if err == sql.ErrNoRows {
 		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
 
-	if r.Used != used {
-		if used {
-
-			_, err = svc.DB.Exec("update configs set used=false where id = $1", id)
-			if err != nil {
-				return nil, Models.ResponseError{ErrorDescr: err.Error()}
-			}
-
-		} else {
-
-			ctx := context.Background()
-			tx, err := svc.DB.BeginTx(ctx, nil)
-			if err != nil {
-				return nil, Models.ResponseError{ErrorDescr: err.Error()}
-			}
-
-			_, err = tx.ExecContext(ctx, "update configs set used=false where service = $1 and used=true", r.Service)
-			if err != nil {
-				tx.Rollback()
-				return nil, Models.ResponseError{ErrorDescr: err.Error()}
-			}
-
-			_, err = tx.ExecContext(ctx, "update configs set used=true where id = $1", id)
-			if err != nil {
-				tx.Rollback()
-				return nil, Models.ResponseError{ErrorDescr: err.Error()}
-			}
-
-			err = tx.Commit()
-			if err != nil {
-				tx.Rollback()
-				return nil, Models.ResponseError{ErrorDescr: err.Error()}
-			}
+	if r.Used == used {
+		return r, nil
+	}
+	if r.Used {
+		_, err = tx.ExecContext(ctx, "update configs set used=false where service = $1 and environment = $2 and used=true", r.Service, r.Environment)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
 		}
 	}
+	if _, err = tx.ExecContext(ctx, "update configs set used = $2 where id = $1", id, r.Used); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
 	return r, nil
 }
 
-func (svc configService) DelConfig(_ context.Context, req interface{}) (*Models.ConfigRequest, error) {
+// DelConfig moves the version to the trash, RestoreConfig brings it back until
+// it is purged after DeletedGracePeriod. With Purge the version is deleted
+// permanently, also from the trash. Like retention, it keeps the used, pinned
+// and canary versions, versions with a pending activation and the versions
+// guarded activations may roll back to.
+func (svc configService) DelConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
 	r := req.(*Models.ConfigRequest)
 	if r.Version == 0 {
 		return nil, Models.ResponseError{ErrorDescr: "version parameter must be specified", Status: http.StatusBadRequest}
 	}
+	r.Environment = environment(r.Environment)
 
-	rows, err := svc.DB.Query("select coalesce(id, 0), used from configs where service = $1 and version = $2 limit 1", r.Service, r.Version)
+	tx, err := svc.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
-	defer rows.Close()
+	defer tx.Rollback()
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
 
 	var id int
-	var used bool
-	for rows.Next() {
-		err := rows.Scan(&id, &used)
-		if err != nil {
-			return nil, Models.ResponseError{ErrorDescr: err.Error()}
-		}
-	}
-	if id == 0 {
+	var used, pinned, canary, pending, guarded bool
+	err = tx.QueryRowContext(ctx, `select id, used, pinned,
+			exists (select 1 from canaries k where k.service = c.service and k.environment = c.environment and k.version = c.version),
+			exists (select 1 from activations a where a.service = c.service and a.environment = c.environment and a.version = c.version and a.status = $5),
+			exists (select 1 from guards g where g.service = c.service and g.environment = c.environment and g.previous_version = c.version and g.status = $6)
+		from configs c where service = $1 and environment = $2 and version = $3 and (deleted_at is null or $4) limit 1`,
+		r.Service, r.Environment, r.Version, r.Purge, Models.ActivationPending, Models.GuardWatching).Scan(&id, &used, &pinned, &canary, &pending, &guarded)
+	if err == sql.ErrNoRows {
 		return nil, Models.ResponseError{ErrorDescr: "No data on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
 	if used {
 		return nil, Models.ResponseError{ErrorDescr: "Specified config is used", Status: http.StatusForbidden}
 	}
+	switch {
+	case pinned:
+		return nil, Models.ResponseError{ErrorDescr: "Specified config is pinned, unpin it first", Status: http.StatusConflict}
+	case canary:
+		return nil, Models.ResponseError{ErrorDescr: "Specified config is the canary, abort or promote the canary first", Status: http.StatusConflict}
+	case pending:
+		return nil, Models.ResponseError{ErrorDescr: "Specified config has a pending activation, cancel it first", Status: http.StatusConflict}
+	case guarded:
+		return nil, Models.ResponseError{ErrorDescr: "Specified config is the rollback target of a guarded activation", Status: http.StatusConflict}
+	}
 
-	_, err = svc.DB.Exec("delete from configs where id = $1", id)
+	if r.Purge {
+		_, err = tx.ExecContext(ctx, "delete from configs where id = $1", id)
+	} else {
+		_, err = tx.ExecContext(ctx, "update configs set deleted_at = now(), deleted_by = $2 where id = $1", id, nullString(auth.Subject(ctx)))
+	}
 	if err != nil {
 		return nil, Models.ResponseError{ErrorDescr: err.Error()}
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if r.Purge {
+		log.Printf("AUDIT: %s purged service %s environment %s version %d", auth.Subject(ctx), r.Service, r.Environment, r.Version)
+	}
 	return r, nil
 }
 
 type configService struct {
 	DB *sql.DB
+	// Keys encrypts stored payloads when set, see StartKeyRotation.
+	Keys *encryption.Keyring
+	// SchemaCompatibility is the compatibility mode of services that have not set their own.
+	SchemaCompatibility string
+	// Tenancy enforces the quotas of tenants on services named "tenant/service".
+	Tenancy bool
+	// Environ holds the environment variables ${env:NAME} placeholders may read.
+	Environ map[string]string
+	// HealthCheckHosts are the hosts the health checks of guarded activations
+	// may reach, HealthCheckFailures the failed checks in a row that roll back.
+	HealthCheckHosts    []string
+	HealthCheckFailures int
+	// Retention is the global retention policy, for services without their own.
+	Retention Models.RetentionPolicy
+	// DeletedGracePeriod is how long deleted versions stay in the trash.
+	DeletedGracePeriod time.Duration
 }
 
 func NewConfigService(postgresUri string) (*configService, error) {
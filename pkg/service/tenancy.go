@@ -0,0 +1,576 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strings"
+)
+
+// tenantService confines callers belonging to a tenant to its services. Their
+// service names are stored as "tenant/service", requests and responses passing
+// through are translated so that a tenant neither sees nor can address the
+// services of another. Identities without a tenant see the stored names and
+// are only let through if they are admins.
+type tenantService struct {
+	next    ConfigService
+	tenants tenantRegistry
+}
+
+// tenantRegistry tells which tenants exist, it is implemented by auth.TenantStore.
+type tenantRegistry interface {
+	Exists(name string) (bool, error)
+}
+
+func NewTenantService(next ConfigService, tenants *auth.TenantStore) ConfigService {
+	return tenantService{next: next, tenants: tenants}
+}
+
+// tenantScope maps the service names used by the callers of a tenant to the
+// stored ones. The empty scope of global identities leaves names as they are.
+type tenantScope string
+
+// scope returns the scope of the caller and moves the given service names into it.
+func (s tenantService) scope(ctx context.Context, services ...*string) (tenantScope, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", Models.ResponseError{ErrorDescr: "Authentication required", Status: http.StatusUnauthorized}
+	}
+	if len(id.Tenant) == 0 {
+		if !id.Admin {
+			return "", Models.ResponseError{ErrorDescr: "Access denied: the caller belongs to no tenant", Status: http.StatusForbidden}
+		}
+		return "", nil
+	}
+	exists, err := s.tenants.Exists(id.Tenant)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", Models.ResponseError{ErrorDescr: fmt.Sprintf("Access denied: unknown tenant %s", id.Tenant), Status: http.StatusForbidden}
+	}
+
+	t := tenantScope(id.Tenant)
+	for _, service := range services {
+		if len(*service) == 0 {
+			continue
+		}
+		if strings.Contains(*service, "/") {
+			return "", Models.ResponseError{ErrorDescr: fmt.Sprintf("Invalid service name %q, '/' is reserved for tenants", *service), Status: http.StatusBadRequest}
+		}
+		*service = string(t) + "/" + *service
+	}
+	return t, nil
+}
+
+func (t tenantScope) out(service string) string {
+	if len(t) == 0 {
+		return service
+	}
+	return strings.TrimPrefix(service, string(t)+"/")
+}
+
+// err removes the tenant from the service names quoted by an error.
+func (t tenantScope) err(err error) error {
+	if e, ok := err.(Models.ResponseError); ok && len(t) > 0 {
+		e.ErrorDescr = strings.ReplaceAll(e.ErrorDescr, string(t)+"/", "")
+		return e
+	}
+	return err
+}
+
+func (t tenantScope) config(r *Models.ConfigRequest, err error) (*Models.ConfigRequest, error) {
+	if err != nil {
+		return nil, t.err(err)
+	}
+	r.Service, r.Parent = t.out(r.Service), t.out(r.Parent)
+	for path, service := range r.Provenance {
+		r.Provenance[path] = t.out(service)
+	}
+	for i, service := range r.References {
+		r.References[i] = t.out(service)
+	}
+	return r, nil
+}
+
+func (t tenantScope) schema(r *Models.SchemaRequest, err error) (*Models.SchemaRequest, error) {
+	if err != nil {
+		return nil, t.err(err)
+	}
+	r.Service = t.out(r.Service)
+	return r, nil
+}
+
+func (s tenantService) SetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	t, err := s.scope(ctx, &r.Service, &r.Parent)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.SetConfig(ctx, req))
+}
+
+func (s tenantService) GetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.GetConfig(ctx, req))
+}
+
+func (s tenantService) GetKey(ctx context.Context, req interface{}) (*Models.KeyRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.KeyRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.next.GetKey(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	r.Service = t.out(r.Service)
+	return r, nil
+}
+
+func (s tenantService) UpdConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.UpdConfig(ctx, req))
+}
+
+func (s tenantService) DelConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.DelConfig(ctx, req))
+}
+
+func (s tenantService) ListDeleted(ctx context.Context, req interface{}) ([]Models.DeletedVersion, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListDeleted(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) RestoreConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.RestoreConfig(ctx, req))
+}
+
+func (s tenantService) PatchConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.PatchRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.PatchConfig(ctx, req))
+}
+
+func (s tenantService) ValidateConfig(ctx context.Context, req interface{}) (*Models.ValidationResult, error) {
+	r := req.(*Models.ConfigRequest)
+	t, err := s.scope(ctx, &r.Service, &r.Parent)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.next.ValidateConfig(ctx, req)
+	return res, t.err(err)
+}
+
+// SearchConfigs returns only the services of the tenant.
+func (s tenantService) SearchConfigs(ctx context.Context, req interface{}) ([]Models.SearchResult, error) {
+	t, err := s.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.next.SearchConfigs(ctx, req)
+	if err != nil || len(t) == 0 {
+		return results, err
+	}
+	own := make([]Models.SearchResult, 0, len(results))
+	for _, res := range results {
+		if strings.HasPrefix(res.Service, string(t)+"/") {
+			res.Service = t.out(res.Service)
+			own = append(own, res)
+		}
+	}
+	return own, nil
+}
+
+func (s tenantService) ListEnvironments(ctx context.Context, req interface{}) ([]Models.Environment, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListEnvironments(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) CopyConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.CopyRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.CopyConfig(ctx, req))
+}
+
+func (s tenantService) PromoteConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.PromoteRequest)
+	t, err := s.scope(ctx, &r.Service, &r.Target)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.PromoteConfig(ctx, req))
+}
+
+func (s tenantService) ListPromotions(ctx context.Context, req interface{}) ([]Models.Promotion, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListPromotions(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service, list[i].SourceService = t.out(list[i].Service), t.out(list[i].SourceService)
+	}
+	return list, nil
+}
+
+func (s tenantService) ListActivations(ctx context.Context, req interface{}) ([]Models.Activation, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListActivations(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) CancelActivation(ctx context.Context, req interface{}) (*Models.Activation, error) {
+	t, err := s.scope(ctx, &req.(*Models.Activation).Service)
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.next.CancelActivation(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	a.Service = t.out(a.Service)
+	return a, nil
+}
+
+func (s tenantService) ListGuards(ctx context.Context, req interface{}) ([]Models.Guard, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListGuards(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) ReportHealth(ctx context.Context, req interface{}) (*Models.Guard, error) {
+	t, err := s.scope(ctx, &req.(*Models.HealthReport).Service)
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.next.ReportHealth(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	g.Service = t.out(g.Service)
+	return g, nil
+}
+
+func (s tenantService) SetFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	t, err := s.scope(ctx, &req.(*Models.Flag).Service)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.next.SetFlag(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	f.Service = t.out(f.Service)
+	return f, nil
+}
+
+func (s tenantService) ListFlags(ctx context.Context, req interface{}) ([]Models.Flag, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListFlags(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) DelFlag(ctx context.Context, req interface{}) (*Models.Flag, error) {
+	t, err := s.scope(ctx, &req.(*Models.Flag).Service)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.next.DelFlag(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	f.Service = t.out(f.Service)
+	return f, nil
+}
+
+func (s tenantService) EvaluateFlags(ctx context.Context, req interface{}) ([]flags.Result, error) {
+	t, err := s.scope(ctx, &req.(*Models.FlagsRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.next.EvaluateFlags(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	return results, nil
+}
+
+func (s tenantService) SetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	t, err := s.scope(ctx, &req.(*Models.Canary).Service)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.next.SetCanary(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	c.Service = t.out(c.Service)
+	return c, nil
+}
+
+func (s tenantService) GetCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.next.GetCanary(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	c.Service = t.out(c.Service)
+	return c, nil
+}
+
+func (s tenantService) PromoteCanary(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.PromoteCanary(ctx, req))
+}
+
+func (s tenantService) AbortCanary(ctx context.Context, req interface{}) (*Models.Canary, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.next.AbortCanary(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	c.Service = t.out(c.Service)
+	return c, nil
+}
+
+func (s tenantService) PinConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.config(s.next.PinConfig(ctx, req))
+}
+
+func (s tenantService) SetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	t, err := s.scope(ctx, &req.(*Models.RetentionPolicy).Service)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.next.SetRetention(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	p.Service = t.out(p.Service)
+	return p, nil
+}
+
+func (s tenantService) GetRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.next.GetRetention(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	p.Service = t.out(p.Service)
+	return p, nil
+}
+
+func (s tenantService) DelRetention(ctx context.Context, req interface{}) (*Models.RetentionPolicy, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.next.DelRetention(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	p.Service = t.out(p.Service)
+	return p, nil
+}
+
+func (s tenantService) RetentionReport(ctx context.Context, req interface{}) ([]Models.ExpiredVersion, error) {
+	t, err := s.scope(ctx, &req.(*Models.ConfigRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.RetentionReport(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) SetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.SchemaRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema(s.next.SetSchema(ctx, req))
+}
+
+func (s tenantService) GetSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.SchemaRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema(s.next.GetSchema(ctx, req))
+}
+
+func (s tenantService) DelSchema(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.SchemaRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema(s.next.DelSchema(ctx, req))
+}
+
+func (s tenantService) ListSchemas(ctx context.Context, req interface{}) ([]Models.SchemaRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.SchemaRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.next.ListSchemas(ctx, req)
+	if err != nil {
+		return nil, t.err(err)
+	}
+	for i := range list {
+		list[i].Service = t.out(list[i].Service)
+	}
+	return list, nil
+}
+
+func (s tenantService) SetCompatibility(ctx context.Context, req interface{}) (*Models.SchemaRequest, error) {
+	t, err := s.scope(ctx, &req.(*Models.SchemaRequest).Service)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema(s.next.SetCompatibility(ctx, req))
+}
+
+// checkQuota rejects a new version of a service of a tenant that would exceed
+// its quotas. Versions in the trash don't count, restoring one is checked like
+// a new version. The tenant stays locked until tx ends, so that concurrent
+// writers can't exceed them together.
+func (svc configService) checkQuota(ctx context.Context, tx *sql.Tx, service string, size int) error {
+	tenant, _, ok := strings.Cut(service, "/")
+	if !svc.Tenancy || !ok {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('tenant:' || $1))", tenant); err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+
+	var q auth.Tenant
+	err := tx.QueryRowContext(ctx, "select max_services, max_versions, max_payload_bytes from tenants where name = $1", tenant).
+		Scan(&q.MaxServices, &q.MaxVersions, &q.MaxPayloadBytes)
+	if err == sql.ErrNoRows {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Unknown tenant %s", tenant), Status: http.StatusForbidden}
+	} else if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if q.MaxServices == 0 && q.MaxVersions == 0 {
+		return exceedsQuota(q, size, false)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `select count(distinct service), count(*), coalesce(bool_or(service = $2), false)
+		from configs where left(service, length($1) + 1) = $1 || '/' and deleted_at is null`, tenant, service).
+		Scan(&q.Services, &q.Versions, &exists)
+	if err != nil {
+		return Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return exceedsQuota(q, size, exists)
+}
+
+// exceedsQuota tells whether one more version of size bytes fits in the quotas
+// of the tenant given its usage. exists is whether the service already counts
+// toward it.
+func exceedsQuota(t auth.Tenant, size int, exists bool) error {
+	if t.MaxPayloadBytes > 0 && size > t.MaxPayloadBytes {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Config of %d bytes exceeds the quota of %d bytes of the tenant", size, t.MaxPayloadBytes), Status: http.StatusRequestEntityTooLarge}
+	}
+	if t.MaxServices > 0 && !exists && t.Services >= t.MaxServices {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Quota exceeded: the tenant may have at most %d services", t.MaxServices), Status: http.StatusForbidden}
+	}
+	if t.MaxVersions > 0 && t.Versions >= t.MaxVersions {
+		return Models.ResponseError{ErrorDescr: fmt.Sprintf("Quota exceeded: the tenant may store at most %d config versions", t.MaxVersions), Status: http.StatusForbidden}
+	}
+	return nil
+}
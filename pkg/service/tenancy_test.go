@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"testing"
+)
+
+type fakeTenants map[string]bool
+
+func (f fakeTenants) Exists(name string) (bool, error) {
+	if name == "broken" {
+		return false, Models.ResponseError{ErrorDescr: "connection refused"}
+	}
+	return f[name], nil
+}
+
+// fakeConfigService answers SearchConfigs with results and GetConfig with the
+// request it was given, quoting the service name in its errors.
+type fakeConfigService struct {
+	ConfigService
+	results []Models.SearchResult
+	err     bool
+}
+
+func (f fakeConfigService) SearchConfigs(ctx context.Context, req interface{}) ([]Models.SearchResult, error) {
+	return f.results, nil
+}
+
+func (f fakeConfigService) GetConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if f.err {
+		return nil, Models.ResponseError{ErrorDescr: "Config " + r.Service + " inherits from " + r.Parent, Status: http.StatusConflict}
+	}
+	return r, nil
+}
+
+func TestTenantScope(t *testing.T) {
+	s := tenantService{tenants: fakeTenants{"acme": true}}
+	tests := []struct {
+		name    string
+		id      *auth.Identity
+		service string
+		scope   tenantScope
+		want    string
+		err     string
+		status  int
+	}{
+		{"no identity", nil, "app", "", "", "Authentication required", http.StatusUnauthorized},
+		{"global admin", &auth.Identity{Subject: "root", Admin: true}, "acme/app", "", "acme/app", "", 0},
+		{"global non-admin", &auth.Identity{Subject: "bob"}, "app", "", "", "Access denied: the caller belongs to no tenant", http.StatusForbidden},
+		{"tenant caller", &auth.Identity{Subject: "bob", Tenant: "acme"}, "app", "acme", "acme/app", "", 0},
+		{"tenant admin", &auth.Identity{Subject: "root", Tenant: "acme", Admin: true}, "app", "acme", "acme/app", "", 0},
+		{"no service", &auth.Identity{Subject: "bob", Tenant: "acme"}, "", "acme", "", "", 0},
+		{"service of another tenant", &auth.Identity{Subject: "bob", Tenant: "acme"}, "other/app", "", "",
+			`Invalid service name "other/app", '/' is reserved for tenants`, http.StatusBadRequest},
+		{"unknown tenant", &auth.Identity{Subject: "bob", Tenant: "gone"}, "app", "", "", "Access denied: unknown tenant gone", http.StatusForbidden},
+		{"store error", &auth.Identity{Subject: "bob", Tenant: "broken"}, "app", "", "", "connection refused", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.id != nil {
+				ctx = auth.NewContext(ctx, tt.id)
+			}
+			service := tt.service
+			scope, err := s.scope(ctx, &service)
+			if len(tt.err) > 0 {
+				require.Equal(t, Models.ResponseError{ErrorDescr: tt.err, Status: tt.status}, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.scope, scope)
+			require.Equal(t, tt.want, service)
+		})
+	}
+}
+
+func TestTenantTranslation(t *testing.T) {
+	acme := tenantScope("acme")
+	require.Equal(t, "app", acme.out("acme/app"))
+	require.Equal(t, "other/app", acme.out("other/app"))
+	require.Equal(t, "acme/app", tenantScope("").out("acme/app"))
+
+	err := acme.err(Models.ResponseError{ErrorDescr: "Config acme/app can't inherit from acme/base", Status: http.StatusConflict})
+	require.Equal(t, Models.ResponseError{ErrorDescr: "Config app can't inherit from base", Status: http.StatusConflict}, err)
+	require.Equal(t, "Config acme/app", tenantScope("").err(Models.ResponseError{ErrorDescr: "Config acme/app"}).Error())
+	other := errors.New("acme/app")
+	require.Equal(t, other, acme.err(other))
+
+	r, err := acme.config(&Models.ConfigRequest{
+		Service:    "acme/app",
+		Parent:     "acme/base",
+		Provenance: map[string]string{"db.host": "acme/base", "log": "acme/app"},
+		References: []string{"acme/db"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, &Models.ConfigRequest{
+		Service:    "app",
+		Parent:     "base",
+		Provenance: map[string]string{"db.host": "base", "log": "app"},
+		References: []string{"db"},
+	}, r)
+
+	// Requests and errors passing through the service are translated both ways.
+	ctx := auth.NewContext(context.Background(), &auth.Identity{Subject: "bob", Tenant: "acme"})
+	s := tenantService{next: fakeConfigService{}, tenants: fakeTenants{"acme": true}}
+	r, err = s.GetConfig(ctx, &Models.ConfigRequest{Service: "app"})
+	require.NoError(t, err)
+	require.Equal(t, "app", r.Service)
+	s.next = fakeConfigService{err: true}
+	_, err = s.GetConfig(ctx, &Models.ConfigRequest{Service: "app", Parent: "acme/base"})
+	require.EqualError(t, err, "Config app inherits from base")
+}
+
+func TestTenantSearch(t *testing.T) {
+	next := fakeConfigService{results: []Models.SearchResult{
+		{Service: "acme/app", Environment: "prod", Version: 1},
+		{Service: "other/app", Environment: "prod", Version: 2},
+		{Service: "acme/db", Environment: "dev", Version: 3},
+		{Service: "acmecorp/app", Environment: "prod", Version: 4},
+		{Service: "global", Environment: "prod", Version: 5},
+	}}
+	s := tenantService{next: next, tenants: fakeTenants{"acme": true, "other": true}}
+
+	ctx := auth.NewContext(context.Background(), &auth.Identity{Subject: "bob", Tenant: "acme"})
+	results, err := s.SearchConfigs(ctx, &Models.SearchRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []Models.SearchResult{
+		{Service: "app", Environment: "prod", Version: 1},
+		{Service: "db", Environment: "dev", Version: 3},
+	}, results)
+
+	ctx = auth.NewContext(context.Background(), &auth.Identity{Subject: "eve", Tenant: "nobody"})
+	_, err = s.SearchConfigs(ctx, &Models.SearchRequest{})
+	require.Error(t, err)
+
+	// Global admins see every stored name.
+	ctx = auth.NewContext(context.Background(), &auth.Identity{Subject: "root", Admin: true})
+	results, err = s.SearchConfigs(ctx, &Models.SearchRequest{})
+	require.NoError(t, err)
+	require.Equal(t, next.results, results)
+}
+
+func TestExceedsQuota(t *testing.T) {
+	tests := []struct {
+		name   string
+		tenant auth.Tenant
+		size   int
+		exists bool
+		err    string
+		status int
+	}{
+		{"no quotas", auth.Tenant{Services: 100, Versions: 1000}, 1 << 20, false, "", 0},
+		{"payload fits", auth.Tenant{MaxPayloadBytes: 100}, 100, true, "", 0},
+		{"payload too large", auth.Tenant{MaxPayloadBytes: 100}, 101, true,
+			"Config of 101 bytes exceeds the quota of 100 bytes of the tenant", http.StatusRequestEntityTooLarge},
+		{"restore ignores the payload quota", auth.Tenant{MaxPayloadBytes: 100}, 0, true, "", 0},
+		{"new service fits", auth.Tenant{MaxServices: 2, Services: 1}, 10, false, "", 0},
+		{"too many services", auth.Tenant{MaxServices: 2, Services: 2}, 10, false,
+			"Quota exceeded: the tenant may have at most 2 services", http.StatusForbidden},
+		{"new version of an existing service", auth.Tenant{MaxServices: 2, Services: 2}, 10, true, "", 0},
+		{"services lowered below usage", auth.Tenant{MaxServices: 1, Services: 3}, 10, true, "", 0},
+		{"version fits", auth.Tenant{MaxVersions: 3, Versions: 2}, 10, true, "", 0},
+		{"too many versions", auth.Tenant{MaxVersions: 3, Versions: 3}, 10, true,
+			"Quota exceeded: the tenant may store at most 3 config versions", http.StatusForbidden},
+		{"payload checked first", auth.Tenant{MaxPayloadBytes: 1, MaxVersions: 1, Versions: 1}, 2, true,
+			"Config of 2 bytes exceeds the quota of 1 bytes of the tenant", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exceedsQuota(tt.tenant, tt.size, tt.exists)
+			if len(tt.err) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, Models.ResponseError{ErrorDescr: tt.err, Status: tt.status}, err)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// retentionSubject is recorded as deleted_by of the versions retention moves
+// to the trash.
+const retentionSubject = "retention"
+
+// ListDeleted returns the versions of the service in the trash of the
+// environment, the last deleted first.
+func (svc configService) ListDeleted(ctx context.Context, req interface{}) ([]Models.DeletedVersion, error) {
+	r := req.(*Models.ConfigRequest)
+	env := environment(r.Environment)
+	rows, err := svc.DB.QueryContext(ctx, `select version, coalesce(deleted_by, ''), deleted_at from configs
+		where service = $1 and environment = $2 and deleted_at is not null order by deleted_at desc, version desc`, r.Service, env)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer rows.Close()
+
+	list := make([]Models.DeletedVersion, 0)
+	for rows.Next() {
+		v := Models.DeletedVersion{Service: r.Service, Environment: env}
+		if err := rows.Scan(&v.Version, &v.DeletedBy, &v.DeletedAt); err != nil {
+			return nil, Models.ResponseError{ErrorDescr: err.Error()}
+		}
+		v.PurgeAt = v.DeletedAt.Add(svc.DeletedGracePeriod)
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// RestoreConfig takes the version out of the trash. It comes back unused,
+// with its number and data, if the quotas of the tenant allow.
+func (svc configService) RestoreConfig(ctx context.Context, req interface{}) (*Models.ConfigRequest, error) {
+	r := req.(*Models.ConfigRequest)
+	if r.Version == 0 {
+		return nil, Models.ResponseError{ErrorDescr: "version parameter must be specified", Status: http.StatusBadRequest}
+	}
+	r.Environment = environment(r.Environment)
+
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	defer tx.Rollback()
+	if err := svc.checkQuota(ctx, tx, r.Service, 0); err != nil {
+		return nil, err
+	}
+	if err := lockVersions(ctx, tx, r.Service, r.Environment); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	err = tx.QueryRowContext(ctx, `update configs set deleted_at = null, deleted_by = null
+		where service = $1 and environment = $2 and version = $3 and deleted_at is not null returning used`,
+		r.Service, r.Environment, r.Version).Scan(&r.Used)
+	if err == sql.ErrNoRows {
+		return nil, Models.ResponseError{ErrorDescr: "No deleted version on request parameters", Status: http.StatusNotFound}
+	} else if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Models.ResponseError{ErrorDescr: err.Error()}
+	}
+	return r, nil
+}
+
+// purge permanently deletes the versions that have been in the trash for
+// longer than DeletedGracePeriod.
+func (svc configService) purge() error {
+	rows, err := svc.DB.Query(`delete from configs where deleted_at < now() - make_interval(secs => $1)
+		returning service, environment, version, coalesce(deleted_by, ''), deleted_at`, svc.DeletedGracePeriod.Seconds())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v Models.DeletedVersion
+		if err := rows.Scan(&v.Service, &v.Environment, &v.Version, &v.DeletedBy, &v.DeletedAt); err != nil {
+			return err
+		}
+		log.Printf("AUDIT: trash purged service %s environment %s version %d deleted by %s at %s",
+			v.Service, v.Environment, v.Version, v.DeletedBy, v.DeletedAt.Format(time.RFC3339))
+	}
+	return nil
+}
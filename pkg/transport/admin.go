@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"net/http"
+	"strconv"
+)
+
+// requireAdmin rejects callers that neither authenticated with an admin credential
+// nor hold the admin role on all services ("*"). The admin API manages every
+// tenant, identities of a tenant can't use it.
+func requireAdmin(ctx context.Context, grants *auth.GrantStore) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return Models.ResponseError{ErrorDescr: "Authentication required", Status: http.StatusUnauthorized}
+	}
+	if len(id.Tenant) > 0 {
+		return Models.ResponseError{ErrorDescr: "Admin API is not available to tenant identities", Status: http.StatusForbidden}
+	}
+	if id.Admin {
+		return nil
+	}
+	if grants != nil {
+		allowed, err := grants.AuthorizeAll(id.Subject, auth.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	}
+	return Models.ResponseError{ErrorDescr: "Admin privileges required", Status: http.StatusForbidden}
+}
+
+type apiKeysHandler struct {
+	keys   *auth.APIKeyStore
+	grants *auth.GrantStore
+}
+
+func (h apiKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := requireAdmin(r.Context(), h.grants); err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Name   string `json:"name"`
+			Admin  bool   `json:"admin"`
+			Tenant string `json:"tenant"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			returnErrorResponse(Models.ResponseError{ErrorDescr: "Invalid input json", Status: http.StatusBadRequest}, w)
+			return
+		}
+		key, err := h.keys.Create(req.Name, req.Admin, req.Tenant)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(key, w)
+
+	case http.MethodGet:
+		keys, err := h.keys.List()
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(keys, w)
+
+	case http.MethodDelete:
+		err := h.keys.Revoke(r.URL.Query().Get("name"))
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(&jsonResponse{Success: true}, w)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type grantsHandler struct {
+	grants *auth.GrantStore
+}
+
+func (h grantsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := requireAdmin(r.Context(), h.grants); err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var g auth.Grant
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			returnErrorResponse(Models.ResponseError{ErrorDescr: "Invalid input json", Status: http.StatusBadRequest}, w)
+			return
+		}
+		grant, err := h.grants.Create(g)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(grant, w)
+
+	case http.MethodGet:
+		grants, err := h.grants.List(r.URL.Query().Get("subject"))
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(grants, w)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			returnErrorResponse(Models.ResponseError{ErrorDescr: "id parameter incorrect, must be a number", Status: http.StatusBadRequest}, w)
+			return
+		}
+		err = h.grants.Delete(id)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(&jsonResponse{Success: true}, w)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type tenantsHandler struct {
+	tenants *auth.TenantStore
+	grants  *auth.GrantStore
+}
+
+func (h tenantsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := requireAdmin(r.Context(), h.grants); err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		var t auth.Tenant
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			returnErrorResponse(Models.ResponseError{ErrorDescr: "Invalid input json", Status: http.StatusBadRequest}, w)
+			return
+		}
+		var tenant *auth.Tenant
+		var err error
+		if r.Method == http.MethodPost {
+			tenant, err = h.tenants.Create(t)
+		} else {
+			tenant, err = h.tenants.Update(t)
+		}
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(tenant, w)
+
+	case http.MethodGet:
+		if name := r.URL.Query().Get("name"); len(name) > 0 {
+			tenant, err := h.tenants.Get(name)
+			if err != nil {
+				returnErrorResponse(err, w)
+				return
+			}
+			if tenant == nil {
+				returnErrorResponse(Models.ResponseError{ErrorDescr: "No tenant with this name", Status: http.StatusNotFound}, w)
+				return
+			}
+			returnJSON(tenant, w)
+			return
+		}
+		tenants, err := h.tenants.List()
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(tenants, w)
+
+	case http.MethodDelete:
+		err := h.tenants.Delete(r.URL.Query().Get("name"))
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		returnJSON(&jsonResponse{Success: true}, w)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func returnJSON(v interface{}, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	resp, _ := json.Marshal(v)
+	fmt.Fprintln(w, string(resp))
+}
@@ -6,11 +6,15 @@ import (
 	"errors"
 	"fmt"
 	pb "github.com/tonx22/gocloudcamp/pb"
+	"github.com/tonx22/gocloudcamp/pkg/flags"
+	"github.com/tonx22/gocloudcamp/pkg/formats"
 	Models "github.com/tonx22/gocloudcamp/pkg/models"
 	"github.com/tonx22/gocloudcamp/pkg/service"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"log"
 	"net"
+	"net/http"
 	"time"
 )
 
@@ -35,6 +39,19 @@ func (s *server) GetConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.Confi
 	return rsp, nil
 }
 
+func (s *server) GetKey(ctx context.Context, in *pb.KeyRequest) (*pb.KeyRequest, error) {
+	req := &Models.KeyRequest{Service: in.Service, Environment: in.Environment, Version: int(in.Version), Path: in.Path, Reveal: in.Reveal, ClientID: clientID(ctx)}
+	resp, err := s.service.GetKey(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(resp.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.KeyRequest{Service: resp.Service, Environment: resp.Environment, Version: int32(resp.Version), Path: resp.Path, Value: value, Variant: resp.Variant}, nil
+}
+
 func (s *server) UpdConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.ConfigRequest, error) {
 	rsp, err := s.processGRPCRequest(ctx, in, "updConfig")
 	if err != nil {
@@ -51,6 +68,388 @@ func (s *server) DelConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.Confi
 	return rsp, nil
 }
 
+func (s *server) PatchConfig(ctx context.Context, in *pb.PatchRequest) (*pb.ConfigRequest, error) {
+	req := &Models.PatchRequest{Service: in.Service, Environment: in.Environment, Version: int(in.Version), Type: in.Type, Patch: in.Patch}
+	resp, err := s.service.PatchConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGRPCResponse(ctx, resp)
+}
+
+func (s *server) ValidateConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.ValidationResponse, error) {
+	req, err := decodeGRPCRequest(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.service.ValidateConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.ValidationResponse{Valid: res.Valid}
+	for _, f := range res.Errors {
+		rsp.Errors = append(rsp.Errors, &pb.FieldError{Path: f.Path, Message: f.Message})
+	}
+	return &rsp, nil
+}
+
+func (s *server) SearchConfigs(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	req := &Models.SearchRequest{Path: in.Path, Value: in.Value, Environment: in.Environment}
+	res, err := s.service.SearchConfigs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.SearchResponse{}
+	for _, r := range res {
+		value, err := json.Marshal(r.Value)
+		if err != nil {
+			return nil, err
+		}
+		rsp.Results = append(rsp.Results, &pb.SearchResult{Service: r.Service, Environment: r.Environment, Version: int32(r.Version), Value: value})
+	}
+	return &rsp, nil
+}
+
+func (s *server) ListEnvironments(ctx context.Context, in *pb.ConfigRequest) (*pb.EnvironmentList, error) {
+	req := &Models.ConfigRequest{Service: in.Service}
+	list, err := s.service.ListEnvironments(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.EnvironmentList{}
+	for _, e := range list {
+		rsp.Environments = append(rsp.Environments, &pb.Environment{Service: e.Service, Environment: e.Environment, Version: int32(e.Version), Versions: int32(e.Versions)})
+	}
+	return &rsp, nil
+}
+
+func (s *server) CopyConfig(ctx context.Context, in *pb.CopyRequest) (*pb.ConfigRequest, error) {
+	req := &Models.CopyRequest{Service: in.Service, From: in.From, To: in.To, Version: int(in.Version)}
+	resp, err := s.service.CopyConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGRPCResponse(ctx, resp)
+}
+
+func (s *server) PromoteConfig(ctx context.Context, in *pb.PromoteRequest) (*pb.ConfigRequest, error) {
+	req := &Models.PromoteRequest{Service: in.Service, Environment: in.Environment, Version: int(in.Version), Target: in.Target, TargetEnvironment: in.TargetEnvironment, Activate: in.Activate}
+	resp, err := s.service.PromoteConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGRPCResponse(ctx, resp)
+}
+
+func (s *server) ListPromotions(ctx context.Context, in *pb.ConfigRequest) (*pb.PromotionList, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment}
+	list, err := s.service.ListPromotions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.PromotionList{}
+	for _, p := range list {
+		rsp.Promotions = append(rsp.Promotions, &pb.Promotion{Service: p.Service, Environment: p.Environment, Version: int32(p.Version), Used: p.Used,
+			SourceService: p.SourceService, SourceEnvironment: p.SourceEnvironment, SourceVersion: int32(p.SourceVersion),
+			PromotedBy: p.PromotedBy, PromotedAt: p.PromotedAt.Format(time.RFC3339)})
+	}
+	return &rsp, nil
+}
+
+func (s *server) ListActivations(ctx context.Context, in *pb.ConfigRequest) (*pb.ActivationList, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment}
+	list, err := s.service.ListActivations(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.ActivationList{}
+	for _, a := range list {
+		rsp.Activations = append(rsp.Activations, encodeActivation(&a))
+	}
+	return &rsp, nil
+}
+
+func (s *server) CancelActivation(ctx context.Context, in *pb.Activation) (*pb.Activation, error) {
+	req := &Models.Activation{ID: in.Id, Service: in.Service}
+	resp, err := s.service.CancelActivation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeActivation(resp), nil
+}
+
+func encodeActivation(a *Models.Activation) *pb.Activation {
+	rsp := &pb.Activation{Id: a.ID, Service: a.Service, Environment: a.Environment, Version: int32(a.Version), ActivateAt: a.ActivateAt.Format(time.RFC3339),
+		Status: a.Status, Error: a.Error, CreatedBy: a.CreatedBy, CreatedAt: a.CreatedAt.Format(time.RFC3339)}
+	if a.DoneAt != nil {
+		rsp.DoneAt = a.DoneAt.Format(time.RFC3339)
+	}
+	return rsp
+}
+
+func (s *server) ListGuards(ctx context.Context, in *pb.ConfigRequest) (*pb.GuardList, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment}
+	list, err := s.service.ListGuards(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.GuardList{}
+	for _, g := range list {
+		rsp.Guards = append(rsp.Guards, encodeGuard(&g))
+	}
+	return &rsp, nil
+}
+
+func (s *server) ReportHealth(ctx context.Context, in *pb.HealthReport) (*pb.Guard, error) {
+	req := &Models.HealthReport{Service: in.Service, Environment: in.Environment, Healthy: in.Healthy, Reason: in.Reason}
+	resp, err := s.service.ReportHealth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGuard(resp), nil
+}
+
+func encodeGuard(g *Models.Guard) *pb.Guard {
+	rsp := &pb.Guard{Id: g.ID, Service: g.Service, Environment: g.Environment, Version: int32(g.Version), PreviousVersion: int32(g.PreviousVersion),
+		HealthUrl: g.HealthURL, WatchUntil: g.WatchUntil.Format(time.RFC3339), Status: g.Status, Reason: g.Reason,
+		CreatedBy: g.CreatedBy, CreatedAt: g.CreatedAt.Format(time.RFC3339)}
+	if g.DoneAt != nil {
+		rsp.DoneAt = g.DoneAt.Format(time.RFC3339)
+	}
+	return rsp
+}
+
+func (s *server) SetCanary(ctx context.Context, in *pb.Canary) (*pb.Canary, error) {
+	req := &Models.Canary{Service: in.Service, Environment: in.Environment, Version: int(in.Version), Percentage: in.Percentage}
+	resp, err := s.service.SetCanary(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCanary(resp), nil
+}
+
+func (s *server) GetCanary(ctx context.Context, in *pb.ConfigRequest) (*pb.Canary, error) {
+	resp, err := s.service.GetCanary(ctx, &Models.ConfigRequest{Service: in.Service, Environment: in.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return encodeCanary(resp), nil
+}
+
+func (s *server) PromoteCanary(ctx context.Context, in *pb.ConfigRequest) (*pb.ConfigRequest, error) {
+	resp, err := s.service.PromoteCanary(ctx, &Models.ConfigRequest{Service: in.Service, Environment: in.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: int32(resp.Version), Used: resp.Used}, nil
+}
+
+func (s *server) AbortCanary(ctx context.Context, in *pb.ConfigRequest) (*pb.Canary, error) {
+	resp, err := s.service.AbortCanary(ctx, &Models.ConfigRequest{Service: in.Service, Environment: in.Environment})
+	if err != nil {
+		return nil, err
+	}
+	return encodeCanary(resp), nil
+}
+
+func encodeCanary(c *Models.Canary) *pb.Canary {
+	return &pb.Canary{Service: c.Service, Environment: c.Environment, Version: int32(c.Version), Percentage: c.Percentage,
+		CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt.Format(time.RFC3339)}
+}
+
+func (s *server) ListDeleted(ctx context.Context, in *pb.ConfigRequest) (*pb.DeletedVersionList, error) {
+	list, err := s.service.ListDeleted(ctx, &Models.ConfigRequest{Service: in.Service, Environment: in.Environment})
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.DeletedVersionList{}
+	for _, v := range list {
+		rsp.Versions = append(rsp.Versions, &pb.DeletedVersion{Service: v.Service, Environment: v.Environment, Version: int32(v.Version),
+			DeletedBy: v.DeletedBy, DeletedAt: v.DeletedAt.Format(time.RFC3339), PurgeAt: v.PurgeAt.Format(time.RFC3339)})
+	}
+	return &rsp, nil
+}
+
+func (s *server) RestoreConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.ConfigRequest, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment, Version: int(in.Version)}
+	resp, err := s.service.RestoreConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: int32(resp.Version), Used: resp.Used}, nil
+}
+
+func (s *server) PinConfig(ctx context.Context, in *pb.ConfigRequest) (*pb.ConfigRequest, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment, Version: int(in.Version), Pinned: in.Pinned}
+	resp, err := s.service.PinConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ConfigRequest{Service: resp.Service, Environment: resp.Environment, Version: int32(resp.Version), Used: resp.Used, Pinned: resp.Pinned}, nil
+}
+
+func (s *server) SetRetention(ctx context.Context, in *pb.RetentionPolicy) (*pb.RetentionPolicy, error) {
+	req := &Models.RetentionPolicy{Service: in.Service, KeepLast: int(in.KeepLast), KeepDays: int(in.KeepDays)}
+	resp, err := s.service.SetRetention(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeRetention(resp), nil
+}
+
+func (s *server) GetRetention(ctx context.Context, in *pb.ConfigRequest) (*pb.RetentionPolicy, error) {
+	resp, err := s.service.GetRetention(ctx, &Models.ConfigRequest{Service: in.Service})
+	if err != nil {
+		return nil, err
+	}
+	return encodeRetention(resp), nil
+}
+
+func (s *server) DelRetention(ctx context.Context, in *pb.ConfigRequest) (*pb.RetentionPolicy, error) {
+	resp, err := s.service.DelRetention(ctx, &Models.ConfigRequest{Service: in.Service})
+	if err != nil {
+		return nil, err
+	}
+	return encodeRetention(resp), nil
+}
+
+func (s *server) RetentionReport(ctx context.Context, in *pb.ConfigRequest) (*pb.ExpiredVersionList, error) {
+	list, err := s.service.RetentionReport(ctx, &Models.ConfigRequest{Service: in.Service})
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.ExpiredVersionList{}
+	for _, v := range list {
+		rsp.Versions = append(rsp.Versions, &pb.ExpiredVersion{Service: v.Service, Environment: v.Environment, Version: int32(v.Version),
+			CreatedAt: v.CreatedAt.Format(time.RFC3339)})
+	}
+	return &rsp, nil
+}
+
+func encodeRetention(p *Models.RetentionPolicy) *pb.RetentionPolicy {
+	rsp := &pb.RetentionPolicy{Service: p.Service, KeepLast: int32(p.KeepLast), KeepDays: int32(p.KeepDays), Global: p.Global, UpdatedBy: p.UpdatedBy}
+	if p.UpdatedAt != nil {
+		rsp.UpdatedAt = p.UpdatedAt.Format(time.RFC3339)
+	}
+	return rsp
+}
+
+func (s *server) SetFlag(ctx context.Context, in *pb.Flag) (*pb.Flag, error) {
+	req := &Models.Flag{Service: in.Service, Environment: in.Environment, Flag: flags.Flag{Name: in.Name, Enabled: in.Enabled, Default: in.Default}}
+	if len(in.Rules) > 0 {
+		if err := json.Unmarshal(in.Rules, &req.Rules); err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "Invalid rules: " + err.Error(), Status: http.StatusBadRequest}
+		}
+	}
+	resp, err := s.service.SetFlag(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return encodeFlag(resp)
+}
+
+func (s *server) ListFlags(ctx context.Context, in *pb.ConfigRequest) (*pb.FlagList, error) {
+	req := &Models.ConfigRequest{Service: in.Service, Environment: in.Environment}
+	list, err := s.service.ListFlags(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.FlagList{}
+	for _, f := range list {
+		flag, err := encodeFlag(&f)
+		if err != nil {
+			return nil, err
+		}
+		rsp.Flags = append(rsp.Flags, flag)
+	}
+	return &rsp, nil
+}
+
+func (s *server) DelFlag(ctx context.Context, in *pb.Flag) (*pb.Flag, error) {
+	req := &Models.Flag{Service: in.Service, Environment: in.Environment, Flag: flags.Flag{Name: in.Name}}
+	resp, err := s.service.DelFlag(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Flag{Service: resp.Service, Environment: resp.Environment, Name: resp.Name}, nil
+}
+
+func (s *server) EvaluateFlags(ctx context.Context, in *pb.FlagsRequest) (*pb.FlagValues, error) {
+	req := &Models.FlagsRequest{Service: in.Service, Environment: in.Environment, Context: flags.Context{ClientID: in.ClientId, Attributes: in.Attributes}, Flags: in.Flags}
+	results, err := s.service.EvaluateFlags(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.FlagValues{}
+	for _, r := range results {
+		rsp.Values = append(rsp.Values, &pb.FlagValue{Name: r.Name, Value: r.Value, Rule: int32(r.Rule), Reason: r.Reason})
+	}
+	return &rsp, nil
+}
+
+func encodeFlag(f *Models.Flag) (*pb.Flag, error) {
+	rules, err := json.Marshal(f.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Flag{Service: f.Service, Environment: f.Environment, Name: f.Name, Enabled: f.Enabled, Default: f.Default, Rules: rules,
+		UpdatedBy: f.UpdatedBy, UpdatedAt: f.UpdatedAt.Format(time.RFC3339)}, nil
+}
+
+func (s *server) SetSchema(ctx context.Context, in *pb.SchemaRequest) (*pb.SchemaRequest, error) {
+	return s.processSchemaRequest(ctx, in, "setSchema")
+}
+
+func (s *server) GetSchema(ctx context.Context, in *pb.SchemaRequest) (*pb.SchemaRequest, error) {
+	return s.processSchemaRequest(ctx, in, "getSchema")
+}
+
+func (s *server) DelSchema(ctx context.Context, in *pb.SchemaRequest) (*pb.SchemaRequest, error) {
+	return s.processSchemaRequest(ctx, in, "delSchema")
+}
+
+func (s *server) SetCompatibility(ctx context.Context, in *pb.SchemaRequest) (*pb.SchemaRequest, error) {
+	return s.processSchemaRequest(ctx, in, "setCompatibility")
+}
+
+func (s *server) ListSchemas(ctx context.Context, in *pb.SchemaRequest) (*pb.SchemaList, error) {
+	req := &Models.SchemaRequest{Service: in.Service}
+	list, err := s.service.ListSchemas(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := pb.SchemaList{}
+	for _, e := range list {
+		rsp.Schemas = append(rsp.Schemas, &pb.SchemaRequest{Service: e.Service, Schema: e.Schema, Version: int32(e.Version)})
+	}
+	return &rsp, nil
+}
+
+func (s *server) processSchemaRequest(ctx context.Context, in *pb.SchemaRequest, method string) (*pb.SchemaRequest, error) {
+	req := &Models.SchemaRequest{Service: in.Service, Schema: in.Schema, Version: int(in.Version), Compatibility: in.Compatibility}
+
+	var resp *Models.SchemaRequest
+	var err error
+	svc := s.service
+
+	switch method {
+	case "setSchema":
+		resp, err = svc.SetSchema(ctx, req)
+	case "getSchema":
+		resp, err = svc.GetSchema(ctx, req)
+	case "delSchema":
+		resp, err = svc.DelSchema(ctx, req)
+	case "setCompatibility":
+		resp, err = svc.SetCompatibility(ctx, req)
+	default:
+		return nil, errors.New("unknown method")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SchemaRequest{Service: resp.Service, Schema: resp.Schema, Version: int32(resp.Version), Compatibility: resp.Compatibility}, nil
+}
+
 func (s *server) processGRPCRequest(ctx context.Context, in *pb.ConfigRequest, method string) (*pb.ConfigRequest, error) {
 	req, err := decodeGRPCRequest(ctx, in)
 	if err != nil {
@@ -83,20 +482,53 @@ func (s *server) processGRPCRequest(ctx context.Context, in *pb.ConfigRequest, m
 	return rsp, nil
 }
 
-func decodeGRPCRequest(_ context.Context, grpcReq interface{}) (*Models.ConfigRequest, error) {
+// clientID returns the client ID sent in the x-client-id metadata.
+func clientID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-client-id"); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func decodeGRPCRequest(ctx context.Context, grpcReq interface{}) (*Models.ConfigRequest, error) {
 	r := grpcReq.(*pb.ConfigRequest)
-	req := Models.ConfigRequest{Service: r.Service, Version: int(r.Version), Used: r.Used}
+	req := Models.ConfigRequest{Service: r.Service, Version: int(r.Version), Used: r.Used, Reveal: r.Reveal, Format: r.Format, Parent: r.Parent, Raw: r.Raw, Environment: r.Environment, Unresolved: r.Unresolved,
+		ClientID: clientID(ctx), Purge: r.Purge}
+	if _, ok := formats.ContentTypes[req.Format]; len(req.Format) > 0 && !ok {
+		return nil, Models.ResponseError{ErrorDescr: "format incorrect, must be one of json, yaml, toml, properties, dotenv, configmap or secret", Status: http.StatusBadRequest}
+	}
+	if len(r.ActivateAt) > 0 {
+		at, err := time.Parse(time.RFC3339, r.ActivateAt)
+		if err != nil {
+			return nil, Models.ResponseError{ErrorDescr: "activate_at incorrect, must be an RFC 3339 timestamp", Status: http.StatusBadRequest}
+		}
+		req.ActivateAt = &at
+	}
+	if len(r.Watch) > 0 {
+		watch, err := time.ParseDuration(r.Watch)
+		if err != nil || watch <= 0 {
+			return nil, Models.ResponseError{ErrorDescr: "watch incorrect, must be a positive duration like 10m", Status: http.StatusBadRequest}
+		}
+		req.Watch = watch
+	}
+	req.HealthURL = r.HealthUrl
 	err := json.Unmarshal(r.Data, &req.Data)
 	if err != nil {
-		return nil, err
+		return nil, Models.ResponseError{ErrorDescr: "Invalid data: " + err.Error(), Status: http.StatusBadRequest}
 	}
 	return &req, nil
 }
 
 func encodeGRPCResponse(_ context.Context, response interface{}) (*pb.ConfigRequest, error) {
 	r := response.(*Models.ConfigRequest)
-	resp := pb.ConfigRequest{Service: r.Service, Version: int32(r.Version), Used: r.Used}
-	data, err := json.Marshal(r.Data)
+	resp := pb.ConfigRequest{Service: r.Service, Version: int32(r.Version), Used: r.Used, SchemaVersion: int32(r.SchemaVersion), Format: r.Format, Parent: r.Parent, Provenance: r.Provenance, Environment: r.Environment,
+		Variant: r.Variant}
+	if r.ActivateAt != nil {
+		resp.ActivateAt = r.ActivateAt.Format(time.RFC3339)
+	}
+	data, err := encodeData(r)
 	if err != nil {
 		return nil, err
 	}
@@ -104,16 +536,16 @@ func encodeGRPCResponse(_ context.Context, response interface{}) (*pb.ConfigRequ
 	return &resp, nil
 }
 
-func StartNewGRPCServer(s interface{}, grpcPort int) error {
+func StartNewGRPCServer(s interface{}, grpcPort int, opt ...Option) error {
 	svc := s.(service.ConfigService)
+	o := newOptions(opt)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
 
-	var opts []grpc.ServerOption
-	grpcServer := grpc.NewServer(opts...)
+	grpcServer := grpc.NewServer(o.grpcServerOptions()...)
 	pb.RegisterConfigSvcServer(grpcServer, &server{service: svc})
 
 	ch := make(chan error)
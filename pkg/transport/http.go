@@ -1,26 +1,64 @@
 package transport
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/tonx22/gocloudcamp/pkg/adapters"
+	"github.com/tonx22/gocloudcamp/pkg/formats"
 	Models "github.com/tonx22/gocloudcamp/pkg/models"
 	"github.com/tonx22/gocloudcamp/pkg/service"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-func StartNewHTTPServer(s interface{}, httpPort int) error {
+func StartNewHTTPServer(s interface{}, httpPort int, opt ...Option) error {
 	svc := s.(service.ConfigService)
+	o := newOptions(opt)
 
 	r := http.NewServeMux()
 	r.Handle("/config", configHandler{service: svc})
+	r.Handle("/config/validate", validateHandler{service: svc})
+	r.Handle("/config/key", keyHandler{service: svc})
+	r.Handle("/config/search", searchHandler{service: svc})
+	r.Handle("/config/environments", environmentsHandler{service: svc})
+	r.Handle("/config/copy", copyHandler{service: svc})
+	r.Handle("/config/promote", promoteHandler{service: svc})
+	r.Handle("/config/promotions", promotionsHandler{service: svc})
+	r.Handle("/config/activations", activationsHandler{service: svc})
+	r.Handle("/config/guards", guardsHandler{service: svc})
+	r.Handle("/config/health", healthHandler{service: svc})
+	r.Handle("/config/canary", canaryHandler{service: svc})
+	r.Handle("/config/canary/promote", promoteCanaryHandler{service: svc})
+	r.Handle("/config/pin", pinHandler{service: svc})
+	r.Handle("/config/deleted", deletedHandler{service: svc})
+	r.Handle("/config/restore", restoreHandler{service: svc})
+	r.Handle("/retention", retentionHandler{service: svc})
+	r.Handle("/retention/report", retentionReportHandler{service: svc})
+	r.Handle("/flags", flagsHandler{service: svc})
+	r.Handle("/flags/evaluate", evaluateHandler{service: svc})
+	r.Handle("/schema", schemaHandler{service: svc})
+	r.Handle("/schema/versions", schemaVersionsHandler{service: svc})
+	r.Handle("/schema/compatibility", compatibilityHandler{service: svc})
+	if o.apiKeys != nil {
+		r.Handle("/admin/apikeys", apiKeysHandler{keys: o.apiKeys, grants: o.grants})
+	}
+	if o.grants != nil {
+		r.Handle("/admin/grants", grantsHandler{grants: o.grants})
+	}
+	if o.tenants != nil {
+		r.Handle("/admin/tenants", tenantsHandler{tenants: o.tenants, grants: o.grants})
+	}
 
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", httpPort), Handler: o.httpHandler(r), TLSConfig: o.tlsConfig}
 	ch := make(chan error)
 	go func() {
-		ch <- http.ListenAndServe(fmt.Sprintf(":%d", httpPort), r)
+		if srv.TLSConfig != nil {
+			ch <- srv.ListenAndServeTLS("", "")
+		} else {
+			ch <- srv.ListenAndServe()
+		}
 	}()
 
 	var e error
@@ -42,12 +80,12 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodPost:
-		req, err := adapters.DecodeSetRequest(context.TODO(), r)
+		req, err := adapters.DecodeSetRequest(r.Context(), r)
 		if err != nil {
 			returnErrorResponse(err, w)
 			return
 		}
-		resp, err := svc.SetConfig(context.TODO(), req)
+		resp, err := svc.SetConfig(r.Context(), req)
 		if err != nil {
 			returnErrorResponse(err, w)
 		} else {
@@ -55,12 +93,12 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case http.MethodGet:
-		req, err := adapters.DecodeGetRequest(context.TODO(), r)
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
 		if err != nil {
 			returnErrorResponse(err, w)
 			return
 		}
-		resp, err := svc.GetConfig(context.TODO(), req)
+		resp, err := svc.GetConfig(r.Context(), req)
 		if err != nil {
 			returnErrorResponse(err, w)
 		} else {
@@ -68,12 +106,12 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case http.MethodPut:
-		req, err := adapters.DecodeGetRequest(context.TODO(), r)
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
 		if err != nil {
 			returnErrorResponse(err, w)
 			return
 		}
-		resp, err := svc.UpdConfig(context.TODO(), req)
+		resp, err := svc.UpdConfig(r.Context(), req)
 		if err != nil {
 			returnErrorResponse(err, w)
 		} else {
@@ -82,12 +120,12 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case http.MethodDelete:
-		req, err := adapters.DecodeGetRequest(context.TODO(), r)
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
 		if err != nil {
 			returnErrorResponse(err, w)
 			return
 		}
-		resp, err := svc.DelConfig(context.TODO(), req)
+		resp, err := svc.DelConfig(r.Context(), req)
 		if err != nil {
 			returnErrorResponse(err, w)
 		} else {
@@ -95,9 +133,668 @@ func (h configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			returnSetResponse(resp, w)
 		}
 
+	case http.MethodPatch:
+		req, err := adapters.DecodePatchRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := svc.PatchConfig(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnSetResponse(resp, w)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE, PATCH")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type validateHandler struct {
+	service service.ConfigService
+}
+
+func (h validateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeSetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ValidateConfig(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type keyHandler struct {
+	service service.ConfigService
+}
+
+func (h keyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeKeyRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.GetKey(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		w.Header().Set("Config-Version", strconv.Itoa(resp.Version))
+		if len(resp.Variant) > 0 {
+			w.Header().Set("Config-Variant", resp.Variant)
+		}
+		returnJSON(resp.Value, w)
+	}
+}
+
+type searchHandler struct {
+	service service.ConfigService
+}
+
+func (h searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeSearchRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.SearchConfigs(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type environmentsHandler struct {
+	service service.ConfigService
+}
+
+func (h environmentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ListEnvironments(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type copyHandler struct {
+	service service.ConfigService
+}
+
+func (h copyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeCopyRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.CopyConfig(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnSetResponse(resp, w)
+	}
+}
+
+type promoteHandler struct {
+	service service.ConfigService
+}
+
+func (h promoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodePromoteRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.PromoteConfig(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnSetResponse(resp, w)
+	}
+}
+
+type promotionsHandler struct {
+	service service.ConfigService
+}
+
+func (h promotionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ListPromotions(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type activationsHandler struct {
+	service service.ConfigService
+}
+
+func (h activationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.ListActivations(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodDelete:
+		req, err := adapters.DecodeActivationRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.CancelActivation(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type guardsHandler struct {
+	service service.ConfigService
+}
+
+func (h guardsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ListGuards(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type healthHandler struct {
+	service service.ConfigService
+}
+
+func (h healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeHealthRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ReportHealth(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type canaryHandler struct {
+	service service.ConfigService
+}
+
+func (h canaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		req, err := adapters.DecodeCanaryRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.SetCanary(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodGet:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.GetCanary(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodDelete:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.AbortCanary(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
 	default:
-		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type promoteCanaryHandler struct {
+	service service.ConfigService
+}
+
+func (h promoteCanaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.PromoteCanary(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnSetResponse(resp, w)
+	}
+}
+
+type pinHandler struct {
+	service service.ConfigService
+}
+
+func (h pinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodePinRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.PinConfig(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnSetResponse(resp, w)
+	}
+}
+
+type deletedHandler struct {
+	service service.ConfigService
+}
+
+func (h deletedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ListDeleted(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type restoreHandler struct {
+	service service.ConfigService
+}
+
+func (h restoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.RestoreConfig(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnSetResponse(resp, w)
+	}
+}
+
+type retentionHandler struct {
+	service service.ConfigService
+}
+
+func (h retentionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		req, err := adapters.DecodeRetentionRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.SetRetention(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodGet:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.GetRetention(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodDelete:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.DelRetention(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type retentionReportHandler struct {
+	service service.ConfigService
+}
+
+func (h retentionReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeGetRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.RetentionReport(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type flagsHandler struct {
+	service service.ConfigService
+}
+
+func (h flagsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		req, err := adapters.DecodeFlagRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.SetFlag(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodGet:
+		req, err := adapters.DecodeGetRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.ListFlags(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	case http.MethodDelete:
+		req, err := adapters.DecodeFlagRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := h.service.DelFlag(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(resp, w)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type evaluateHandler struct {
+	service service.ConfigService
+}
+
+func (h evaluateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeFlagsRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.EvaluateFlags(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type schemaHandler struct {
+	service service.ConfigService
+}
+
+func (h schemaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	svc := h.service
+
+	switch r.Method {
+	case http.MethodPut:
+		req, err := adapters.DecodeSchemaRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := svc.SetSchema(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(&jsonResponse{Success: true, Version: resp.Version}, w)
+		}
+
+	case http.MethodGet:
+		req, err := adapters.DecodeSchemaRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		resp, err := svc.GetSchema(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			w.Header().Set("Content-Type", "application/schema+json")
+			w.Header().Set("Schema-Version", strconv.Itoa(resp.Version))
+			w.Header().Set("Schema-Compatibility", resp.Compatibility)
+			fmt.Fprintln(w, string(resp.Schema))
+		}
+
+	case http.MethodDelete:
+		req, err := adapters.DecodeSchemaRequest(r.Context(), r)
+		if err != nil {
+			returnErrorResponse(err, w)
+			return
+		}
+		_, err = svc.DelSchema(r.Context(), req)
+		if err != nil {
+			returnErrorResponse(err, w)
+		} else {
+			returnJSON(&jsonResponse{Success: true}, w)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type schemaVersionsHandler struct {
+	service service.ConfigService
+}
+
+func (h schemaVersionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := adapters.DecodeSchemaRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	resp, err := h.service.ListSchemas(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(resp, w)
+	}
+}
+
+type compatibilityHandler struct {
+	service service.ConfigService
+}
+
+func (h compatibilityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(r.URL.Query().Get("mode")) == 0 {
+		returnErrorResponse(Models.ResponseError{ErrorDescr: "mode parameter must be specified", Status: http.StatusBadRequest}, w)
+		return
+	}
+	req, err := adapters.DecodeSchemaRequest(r.Context(), r)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	_, err = h.service.SetCompatibility(r.Context(), req)
+	if err != nil {
+		returnErrorResponse(err, w)
+	} else {
+		returnJSON(&jsonResponse{Success: true}, w)
 	}
 }
 
@@ -108,7 +805,7 @@ func returnErrorResponse(e interface{}, w http.ResponseWriter) {
 		status = re.Status
 	}
 	w.Header().Set("Content-Type", "application/json")
-	respStruct := &jsonResponse{Success: false, Message: re.ErrorDescr}
+	respStruct := &jsonResponse{Success: false, Message: re.ErrorDescr, Errors: re.Fields}
 	resp, _ := json.Marshal(respStruct)
 	http.Error(w, string(resp), status)
 }
@@ -116,25 +813,56 @@ func returnErrorResponse(e interface{}, w http.ResponseWriter) {
 func returnSetResponse(e interface{}, w http.ResponseWriter) {
 	re := e.(*Models.ConfigRequest)
 	w.Header().Set("Content-Type", "application/json")
-	respStruct := &jsonResponse{Success: true, Version: re.Version}
+	respStruct := &jsonResponse{Success: true, Version: re.Version, ActivateAt: re.ActivateAt}
 	resp, _ := json.Marshal(respStruct)
 	fmt.Fprintln(w, string(resp))
 }
 
 func returnGetResponse(e interface{}, w http.ResponseWriter) {
 	re := e.(*Models.ConfigRequest)
-	w.Header().Set("Content-Type", "application/json")
+	if len(re.Variant) > 0 {
+		w.Header().Set("Config-Variant", re.Variant)
+		w.Header().Add("Vary", adapters.ClientIDHeader)
+	}
 	if re.Extended {
+		w.Header().Set("Content-Type", "application/json")
 		resp, _ := json.Marshal(re)
 		fmt.Fprintln(w, string(resp))
-	} else {
-		resp, _ := json.Marshal(re.Data)
+		return
+	}
+	resp, err := encodeData(re)
+	if err != nil {
+		returnErrorResponse(err, w)
+		return
+	}
+	if len(re.Format) == 0 || re.Format == formats.JSON {
+		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintln(w, string(resp))
+		return
+	}
+	w.Header().Set("Content-Type", formats.ContentTypes[re.Format])
+	w.Write(resp)
+}
+
+// encodeData renders the config data in the requested format. Formats other
+// than JSON get secret values in place of their {"$secret": ...} wrappers.
+func encodeData(r *Models.ConfigRequest) ([]byte, error) {
+	if len(r.Format) == 0 || r.Format == formats.JSON {
+		return json.Marshal(r.Data)
+	}
+	obj := service.UnwrapSecrets(r.Data.Members).(Models.Object)
+	b, err := formats.Encode(r.Format, obj, r.Service)
+	if err != nil {
+		return nil, Models.ResponseError{ErrorDescr: "Config cannot be exported as " + r.Format + ": " + err.Error(), Status: http.StatusUnprocessableEntity}
 	}
+	return b, nil
 }
 
 type jsonResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Version int    `json:"version,omitempty"`
+	Success bool                `json:"success"`
+	Message string              `json:"message,omitempty"`
+	Version int                 `json:"version,omitempty"`
+	Errors  []Models.FieldError `json:"errors,omitempty"`
+	// ActivateAt is the time a version is scheduled to become the used one.
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
 }
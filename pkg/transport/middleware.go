@@ -0,0 +1,470 @@
+package transport
+
+import (
+	"context"
+	"crypto/x509"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	Models "github.com/tonx22/gocloudcamp/pkg/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallInfo describes an incoming call independently of the protocol it arrived on,
+// so that the same policies can be applied to HTTP and gRPC requests.
+type CallInfo struct {
+	Protocol   string
+	Method     string
+	RemoteAddr string
+	Metadata   map[string][]string
+	// PeerCertificates holds the verified client certificate chain when mutual TLS is used.
+	PeerCertificates []*x509.Certificate
+}
+
+// Get returns the first metadata value (HTTP header or gRPC metadata) for the key.
+func (c *CallInfo) Get(key string) string {
+	v := c.Metadata[strings.ToLower(key)]
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+// Policy is a cross-cutting check applied to every call. It may reject the call
+// or return a derived context, e.g. carrying the caller identity.
+type Policy func(ctx context.Context, call *CallInfo) (context.Context, error)
+
+func applyPolicies(ctx context.Context, call *CallInfo, policies []Policy) (context.Context, error) {
+	for _, p := range policies {
+		var err error
+		ctx, err = p(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// TokenAuthenticator resolves the token presented by a caller into an identity.
+type TokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*auth.Identity, error)
+}
+
+// Authentication requires every call to carry a token accepted by a, either as
+// "Authorization: Bearer <token>" or as "X-API-Key: <token>", and stores the
+// resulting identity in the context. Calls already identified by PeerIdentity
+// may omit the token.
+func Authentication(a TokenAuthenticator) Policy {
+	return func(ctx context.Context, call *CallInfo) (context.Context, error) {
+		token := bearerToken(call)
+		if _, ok := auth.FromContext(ctx); ok && len(token) == 0 {
+			return ctx, nil
+		}
+		id, err := a.Authenticate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewContext(ctx, id), nil
+	}
+}
+
+// PeerIdentity identifies callers by the common name of their verified mTLS
+// certificate or, when trustedHeader is set, by the value of that header. The
+// header must only be trusted behind a proxy that sets it and strips it from
+// client requests.
+func PeerIdentity(trustedHeader string) Policy {
+	return func(ctx context.Context, call *CallInfo) (context.Context, error) {
+		if len(call.PeerCertificates) > 0 && len(call.PeerCertificates[0].Subject.CommonName) > 0 {
+			return auth.NewContext(ctx, &auth.Identity{Subject: call.PeerCertificates[0].Subject.CommonName, Method: "mtls"}), nil
+		}
+		if len(trustedHeader) > 0 {
+			if subject := call.Get(trustedHeader); len(subject) > 0 {
+				return auth.NewContext(ctx, &auth.Identity{Subject: subject, Method: "header"}), nil
+			}
+		}
+		return ctx, nil
+	}
+}
+
+func bearerToken(call *CallInfo) string {
+	if h := call.Get("authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return call.Get("x-api-key")
+}
+
+// RateLimit limits every remote host to rps requests per second with the given burst.
+func RateLimit(rps float64, burst int) Policy {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &rateLimiter{rps: rps, burst: float64(burst), buckets: make(map[string]*bucket)}
+	return func(ctx context.Context, call *CallInfo) (context.Context, error) {
+		host, _, err := net.SplitHostPort(call.RemoteAddr)
+		if err != nil {
+			host = call.RemoteAddr
+		}
+		if !l.allow(host, time.Now()) {
+			return nil, Models.ResponseError{ErrorDescr: "Rate limit exceeded", Status: http.StatusTooManyRequests}
+		}
+		return ctx, nil
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	rps     float64
+	burst   float64
+	buckets map[string]*bucket
+	// sweptAt is when buckets were last swept of the idle ones.
+	sweptAt time.Time
+}
+
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rps
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops the buckets that have been idle long enough to refill: a new
+// bucket would be the same, so memory only grows with the active clients.
+// Buckets are swept at most once per refill time.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rps * float64(time.Second))
+	if now.Sub(l.sweptAt) < refill {
+		return
+	}
+	l.sweptAt = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// gRPC interceptors
+
+func grpcCallInfo(ctx context.Context, fullMethod string) *CallInfo {
+	call := CallInfo{Protocol: "grpc", Method: path.Base(fullMethod), Metadata: make(map[string][]string)}
+	if p, ok := peer.FromContext(ctx); ok {
+		call.RemoteAddr = p.Addr.String()
+		if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			call.PeerCertificates = ti.State.PeerCertificates
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			call.Metadata[k] = v
+		}
+	}
+	return &call
+}
+
+func recoveryUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func loggingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logGRPCCall(ctx, info.FullMethod, err, start)
+	return resp, err
+}
+
+func loggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logGRPCCall(ss.Context(), info.FullMethod, err, start)
+	return err
+}
+
+func logGRPCCall(ctx context.Context, method string, err error, start time.Time) {
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	log.Printf("GRPC %s from %s: %s in %v", method, addr, status.Code(err), time.Since(start))
+}
+
+func errorUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, grpcError(err)
+}
+
+func errorStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return grpcError(handler(srv, ss))
+}
+
+// grpcError converts a ResponseError into a gRPC status keeping its description.
+// Field errors are attached as google.rpc.BadRequest details.
+func grpcError(err error) error {
+	re, ok := err.(Models.ResponseError)
+	if !ok {
+		return err
+	}
+	st := status.New(grpcCode(re.Status), re.ErrorDescr)
+	if len(re.Fields) > 0 {
+		br := errdetails.BadRequest{}
+		for _, f := range re.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Path, Description: f.Message})
+		}
+		if withDetails, err := st.WithDetails(&br); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+func policyUnaryInterceptor(policies []Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := applyPolicies(ctx, grpcCallInfo(ctx, info.FullMethod), policies)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func policyStreamInterceptor(policies []Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := applyPolicies(ss.Context(), grpcCallInfo(ss.Context(), info.FullMethod), policies)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// HTTP middleware
+
+func httpCallInfo(r *http.Request) *CallInfo {
+	call := CallInfo{Protocol: "http", Method: httpMethodName(r), RemoteAddr: r.RemoteAddr, Metadata: make(map[string][]string)}
+	for k, v := range r.Header {
+		call.Metadata[strings.ToLower(k)] = v
+	}
+	if r.TLS != nil {
+		call.PeerCertificates = r.TLS.PeerCertificates
+	}
+	return &call
+}
+
+// httpMethodName maps an HTTP route onto the name of the equivalent RPC so that
+// policies see the same method names on both protocols.
+func httpMethodName(r *http.Request) string {
+	if name, ok := httpRoutes[r.URL.Path][r.Method]; ok {
+		return name
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+var httpRoutes = map[string]map[string]string{
+	"/config": {
+		http.MethodPost:   "SetConfig",
+		http.MethodGet:    "GetConfig",
+		http.MethodPut:    "UpdConfig",
+		http.MethodDelete: "DelConfig",
+		http.MethodPatch:  "PatchConfig",
+	},
+	"/config/validate": {
+		http.MethodPost: "ValidateConfig",
+	},
+	"/config/key": {
+		http.MethodGet: "GetKey",
+	},
+	"/config/search": {
+		http.MethodGet: "SearchConfigs",
+	},
+	"/config/environments": {
+		http.MethodGet: "ListEnvironments",
+	},
+	"/config/copy": {
+		http.MethodPost: "CopyConfig",
+	},
+	"/config/promote": {
+		http.MethodPost: "PromoteConfig",
+	},
+	"/config/promotions": {
+		http.MethodGet: "ListPromotions",
+	},
+	"/config/activations": {
+		http.MethodGet:    "ListActivations",
+		http.MethodDelete: "CancelActivation",
+	},
+	"/config/guards": {
+		http.MethodGet: "ListGuards",
+	},
+	"/config/health": {
+		http.MethodPost: "ReportHealth",
+	},
+	"/config/canary": {
+		http.MethodPut:    "SetCanary",
+		http.MethodGet:    "GetCanary",
+		http.MethodDelete: "AbortCanary",
+	},
+	"/config/canary/promote": {
+		http.MethodPost: "PromoteCanary",
+	},
+	"/config/pin": {
+		http.MethodPut: "PinConfig",
+	},
+	"/config/deleted": {
+		http.MethodGet: "ListDeleted",
+	},
+	"/config/restore": {
+		http.MethodPost: "RestoreConfig",
+	},
+	"/retention": {
+		http.MethodPut:    "SetRetention",
+		http.MethodGet:    "GetRetention",
+		http.MethodDelete: "DelRetention",
+	},
+	"/retention/report": {
+		http.MethodGet: "RetentionReport",
+	},
+	"/flags": {
+		http.MethodPut:    "SetFlag",
+		http.MethodGet:    "ListFlags",
+		http.MethodDelete: "DelFlag",
+	},
+	"/flags/evaluate": {
+		http.MethodPost: "EvaluateFlags",
+	},
+	"/schema": {
+		http.MethodPut:    "SetSchema",
+		http.MethodGet:    "GetSchema",
+		http.MethodDelete: "DelSchema",
+	},
+	"/schema/versions": {
+		http.MethodGet: "ListSchemas",
+	},
+	"/schema/compatibility": {
+		http.MethodPut: "SetCompatibility",
+	},
+}
+
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("panic in %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+				returnErrorResponse(Models.ResponseError{ErrorDescr: "Internal server error"}, w)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("HTTP %s %s from %s: %d in %v", r.Method, r.URL.RequestURI(), r.RemoteAddr, rec.status, time.Since(start))
+	})
+}
+
+func policyMiddleware(policies []Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := applyPolicies(r.Context(), httpCallInfo(r), policies)
+			if err != nil {
+				returnErrorResponse(err, w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
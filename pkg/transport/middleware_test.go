@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := &rateLimiter{rps: 2, burst: 3, buckets: make(map[string]*bucket)}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		require.True(t, l.allow("a", now), "request %d within burst", i)
+	}
+	require.False(t, l.allow("a", now))
+	require.True(t, l.allow("b", now), "hosts have their own buckets")
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, l.allow("a", now), "one token refilled")
+	require.False(t, l.allow("a", now))
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := &rateLimiter{rps: 10, burst: 5, buckets: make(map[string]*bucket)}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		l.allow(fmt.Sprintf("10.0.%d.%d", i/256, i%256), now)
+	}
+	require.Len(t, l.buckets, 1000)
+
+	// Refilling takes burst / rps = 500ms, idle buckets are dropped after it.
+	now = now.Add(400 * time.Millisecond)
+	l.allow("10.0.0.0", now)
+	require.Len(t, l.buckets, 1000)
+	now = now.Add(200 * time.Millisecond)
+	l.allow("10.1.0.0", now)
+	require.Len(t, l.buckets, 2, "only the recently active hosts are kept")
+
+	// A dropped bucket comes back full, as it would have been.
+	for i := 0; i < 5; i++ {
+		require.True(t, l.allow("10.0.0.1", now))
+	}
+	require.False(t, l.allow("10.0.0.1", now))
+}
@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"crypto/tls"
+	"github.com/tonx22/gocloudcamp/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net/http"
+)
+
+type options struct {
+	policies   []Policy
+	unary      []grpc.UnaryServerInterceptor
+	stream     []grpc.StreamServerInterceptor
+	middleware []func(http.Handler) http.Handler
+	tlsConfig  *tls.Config
+	apiKeys    *auth.APIKeyStore
+	grants     *auth.GrantStore
+	tenants    *auth.TenantStore
+}
+
+// Option configures the HTTP and gRPC servers.
+type Option func(*options)
+
+// WithPolicies adds policies applied to every call on both protocols, in the given order.
+func WithPolicies(p ...Policy) Option {
+	return func(o *options) {
+		o.policies = append(o.policies, p...)
+	}
+}
+
+// WithUnaryInterceptors appends gRPC unary interceptors after the built-in ones.
+func WithUnaryInterceptors(i ...grpc.UnaryServerInterceptor) Option {
+	return func(o *options) {
+		o.unary = append(o.unary, i...)
+	}
+}
+
+// WithStreamInterceptors appends gRPC stream interceptors after the built-in ones.
+func WithStreamInterceptors(i ...grpc.StreamServerInterceptor) Option {
+	return func(o *options) {
+		o.stream = append(o.stream, i...)
+	}
+}
+
+// WithHTTPMiddleware appends HTTP middleware after the built-in ones.
+func WithHTTPMiddleware(m ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, m...)
+	}
+}
+
+// WithTLS serves both protocols over TLS using cfg, see NewTLSConfig.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithAPIKeys exposes API key management for admin callers at /admin/apikeys.
+func WithAPIKeys(keys *auth.APIKeyStore) Option {
+	return func(o *options) {
+		o.apiKeys = keys
+	}
+}
+
+// WithGrants exposes access grant management for admin callers at /admin/grants
+// and lets holders of the admin role on "*" use the admin API.
+func WithGrants(grants *auth.GrantStore) Option {
+	return func(o *options) {
+		o.grants = grants
+	}
+}
+
+// WithTenants exposes tenant management for admin callers at /admin/tenants.
+func WithTenants(tenants *auth.TenantStore) Option {
+	return func(o *options) {
+		o.tenants = tenants
+	}
+}
+
+func newOptions(opts []Option) *options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
+
+func (o *options) grpcServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if o.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(o.tlsConfig)))
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(o.unaryInterceptors()...))
+	opts = append(opts, grpc.ChainStreamInterceptor(o.streamInterceptors()...))
+	return opts
+}
+
+// unaryInterceptors returns the unary chain: recovery, logging, error
+// translation and policies first, then the user supplied interceptors.
+func (o *options) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	chain := []grpc.UnaryServerInterceptor{
+		recoveryUnaryInterceptor,
+		loggingUnaryInterceptor,
+		errorUnaryInterceptor,
+		policyUnaryInterceptor(o.policies),
+	}
+	return append(chain, o.unary...)
+}
+
+func (o *options) streamInterceptors() []grpc.StreamServerInterceptor {
+	chain := []grpc.StreamServerInterceptor{
+		recoveryStreamInterceptor,
+		loggingStreamInterceptor,
+		errorStreamInterceptor,
+		policyStreamInterceptor(o.policies),
+	}
+	return append(chain, o.stream...)
+}
+
+// httpHandler wraps h into recovery, logging and policy middleware, then the user supplied middleware.
+func (o *options) httpHandler(h http.Handler) http.Handler {
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		h = o.middleware[i](h)
+	}
+	h = policyMiddleware(o.policies)(h)
+	h = loggingMiddleware(h)
+	return recoveryMiddleware(h)
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewTLSConfig builds a server TLS configuration from PEM files. When clientCAFile
+// is set, clients must present a certificate signed by one of its CAs (mutual TLS).
+// The certificate, key and client CA bundle are re-read whenever the files change,
+// so rotated certificates are picked up without a restart.
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	r := &tlsReloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	// http.Server.ServeTLS only looks at Certificates and GetCertificate to
+	// decide whether to load the certificate files itself.
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.config().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}, nil
+}
+
+// reloadCheckInterval limits how often the files are checked for changes.
+var reloadCheckInterval = 5 * time.Second
+
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.Mutex
+	current   *tls.Config
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func (r *tlsReloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) > reloadCheckInterval {
+		r.checkedAt = time.Now()
+		if r.latestModTime().After(r.modTime) {
+			if err := r.reload(); err != nil {
+				log.Printf("TLS reload failed, keeping previous certificate: %v", err)
+			} else {
+				log.Printf("TLS certificate reloaded from %s", r.certFile)
+			}
+		}
+	}
+	return r.current
+}
+
+func (r *tlsReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkedAt = time.Now()
+	return r.reload()
+}
+
+func (r *tlsReloader) reload() error {
+	modTime := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("can't load certificate: %v", err)
+	}
+	// The per-connection config replaces the server one entirely, so ALPN
+	// protocols for gRPC and HTTP/2 have to be advertised here as well.
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}, NextProtos: []string{"h2", "http/1.1"}}
+
+	if len(r.clientCAFile) > 0 {
+		pool, err := loadCertPool(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.current = cfg
+	r.modTime = modTime
+	return nil
+}
+
+func (r *tlsReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if len(f) == 0 {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("can't read CA bundle: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCertificate(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := x509.Certificate{SerialNumber: big.NewInt(time.Now().UnixNano()), Subject: pkix.Name{CommonName: name},
+		DNSNames: []string{"localhost"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// serveTLS starts an HTTP server the way StartNewHTTPServer does, with no
+// certificate files passed to ServeTLS, and returns its address.
+func serveTLS(t *testing.T, cfg *tls.Config) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), TLSConfig: cfg}
+	go srv.ServeTLS(lis, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + lis.Addr().String()
+}
+
+// get makes a request on a new connection and returns the common name of the server certificate.
+func get(t *testing.T, url string, cfg *tls.Config) (string, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), "config-server")
+	cfg, err := NewTLSConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	// Before Go 1.20 ServeTLS ignores GetConfigForClient and loads the empty
+	// file names unless one of these is set.
+	require.True(t, len(cfg.Certificates) > 0 || cfg.GetCertificate != nil)
+
+	name, err := get(t, serveTLS(t, cfg), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	require.Equal(t, "config-server", name)
+}
+
+func TestTLSReload(t *testing.T) {
+	defer func(d time.Duration) { reloadCheckInterval = d }(reloadCheckInterval)
+	reloadCheckInterval = 0
+
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "v1")
+	cfg, err := NewTLSConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	url := serveTLS(t, cfg)
+	client := &tls.Config{InsecureSkipVerify: true}
+
+	name, err := get(t, url, client)
+	require.NoError(t, err)
+	require.Equal(t, "v1", name)
+
+	// Rotate the files, with a later modification time than the loaded ones.
+	writeCertificate(t, dir, "v2")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	name, err = get(t, url, client)
+	require.NoError(t, err)
+	require.Equal(t, "v2", name)
+	cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "v2", leaf.Subject.CommonName)
+
+	// A broken rotation keeps serving the previous certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	name, err = get(t, url, client)
+	require.NoError(t, err)
+	require.Equal(t, "v2", name)
+}
+
+func TestMutualTLS(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), "config-server")
+	// The self-signed client certificate is its own CA.
+	clientCert, clientKey := writeCertificate(t, t.TempDir(), "client")
+	cfg, err := NewTLSConfig(certFile, keyFile, clientCert)
+	require.NoError(t, err)
+	url := serveTLS(t, cfg)
+
+	_, err = get(t, url, &tls.Config{InsecureSkipVerify: true})
+	require.Error(t, err)
+
+	otherCert, otherKey := writeCertificate(t, t.TempDir(), "other")
+	other, err := tls.LoadX509KeyPair(otherCert, otherKey)
+	require.NoError(t, err)
+	_, err = get(t, url, &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{other}})
+	require.Error(t, err)
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+	name, err := get(t, url, &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	require.Equal(t, "config-server", name)
+}